@@ -93,37 +93,32 @@ class AuthProvider with ChangeNotifier {
     }
   }
 
-  Future<Map<String, dynamic>?> generateQRCode() async {
+  // Returns the details of the device asking to sign in, or null if the
+  // QR login is invalid or expired.
+  Future<Map<String, dynamic>?> scanQRLogin(String id) async {
     if (_user == null) return null;
 
     try {
-      final response = await _apiService.generateQRCode(_user!.id);
-      return response;
+      return await _apiService.scanQRLogin(id);
     } catch (e) {
-      print('QR code generation error: $e');
+      print('QR login scan error: $e');
       return null;
     }
   }
 
-  Future<bool> verifyQRCode(String token) async {
+  Future<bool> approveQRLogin(String id, {required bool confirm}) async {
     _isLoading = true;
     notifyListeners();
 
     try {
-      final response = await _apiService.verifyQRCode(token);
-      
-      _user = User.fromJson(response['user']);
-      _isAuthenticated = true;
-      
-      await _storageService.saveToken(response['access_token']);
-      await _storageService.saveRefreshToken(response['refresh_token']);
-      await _storageService.saveUserData(jsonEncode(_user!.toJson()));
-      
-      _apiService.setToken(response['access_token']);
-      
+      if (confirm) {
+        await _apiService.confirmQRLogin(id);
+      } else {
+        await _apiService.rejectQRLogin(id);
+      }
       return true;
     } catch (e) {
-      print('QR code verification error: $e');
+      print('QR login approval error: $e');
       return false;
     } finally {
       _isLoading = false;
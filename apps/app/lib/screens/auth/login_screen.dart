@@ -45,10 +45,6 @@ class _LoginScreenState extends State<LoginScreen> {
     }
   }
 
-  void _navigateToQRLogin() {
-    context.push('/qr-login');
-  }
-
   @override
   Widget build(BuildContext context) {
     return Scaffold(
@@ -141,13 +137,6 @@ class _LoginScreenState extends State<LoginScreen> {
                 ),
                 const SizedBox(height: 16),
                 
-                // QR Code login button
-                TextButton(
-                  onPressed: _navigateToQRLogin,
-                  child: const Text('使用 QR Code 登入'),
-                ),
-                const SizedBox(height: 16),
-                
                 // Divider
                 const Row(
                   children: [
@@ -3,7 +3,6 @@ import 'package:qr_code_scanner/qr_code_scanner.dart';
 import 'package:provider/provider.dart';
 import 'package:go_router/go_router.dart';
 import 'package:smg_app/providers/auth_provider.dart';
-import 'dart:convert';
 
 class QRLoginScreen extends StatefulWidget {
   const QRLoginScreen({super.key});
@@ -37,34 +36,73 @@ class _QRLoginScreenState extends State<QRLoginScreen> {
       isScanning = false;
     });
 
-    try {
-      final qrData = jsonDecode(qrCode);
-      final token = qrData['token'] as String?;
-      final expires = qrData['expires'] as int?;
-
-      if (token == null || expires == null) {
-        _showError('無效的 QR Code');
-        return;
-      }
+    final uri = Uri.tryParse(qrCode);
+    final id = uri?.queryParameters['id'];
+    if (uri == null || uri.scheme != 'smg' || uri.host != 'qr-login' || id == null) {
+      _showError('無效的 QR Code');
+      return;
+    }
 
-      // Check if QR code has expired
-      final now = DateTime.now().millisecondsSinceEpoch ~/ 1000;
-      if (now > expires) {
-        _showError('QR Code 已過期');
-        return;
-      }
+    final authProvider = Provider.of<AuthProvider>(context, listen: false);
+    final device = await authProvider.scanQRLogin(id);
+    if (!mounted) return;
+    if (device == null) {
+      _showError('QR Code 已過期或已被使用');
+      return;
+    }
 
-      final authProvider = Provider.of<AuthProvider>(context, listen: false);
-      final success = await authProvider.verifyQRCode(token);
+    final confirm = await _askConfirmation(device);
+    if (!mounted) return;
 
-      if (success && mounted) {
-        context.go('/home');
-      } else if (mounted) {
-        _showError('QR Code 驗證失敗');
-      }
-    } catch (e) {
-      _showError('QR Code 格式錯誤');
+    final success = await authProvider.approveQRLogin(id, confirm: confirm);
+    if (!mounted) return;
+    if (!success) {
+      _showError('操作失敗，請重新掃描');
+      return;
     }
+
+    ScaffoldMessenger.of(context).showSnackBar(
+      SnackBar(content: Text(confirm ? '已允許登入' : '已拒絕登入')),
+    );
+    context.pop();
+  }
+
+  // Shows which device is asking to sign in, so the user only approves
+  // logins they started themselves.
+  Future<bool> _askConfirmation(Map<String, dynamic> device) async {
+    final deviceName = (device['device_name'] as String?)?.isNotEmpty == true
+        ? device['device_name'] as String
+        : '未知裝置';
+
+    final result = await showDialog<bool>(
+      context: context,
+      barrierDismissible: false,
+      builder: (context) => AlertDialog(
+        title: const Text('確認登入'),
+        content: Column(
+          mainAxisSize: MainAxisSize.min,
+          crossAxisAlignment: CrossAxisAlignment.start,
+          children: [
+            Text('裝置：$deviceName'),
+            Text('IP：${device['ip_address'] ?? ''}'),
+            Text('瀏覽器：${device['user_agent'] ?? ''}'),
+            const SizedBox(height: 12),
+            const Text('如果這不是您本人發起的登入，請拒絕。'),
+          ],
+        ),
+        actions: [
+          TextButton(
+            onPressed: () => Navigator.of(context).pop(false),
+            child: const Text('拒絕'),
+          ),
+          ElevatedButton(
+            onPressed: () => Navigator.of(context).pop(true),
+            child: const Text('允許登入'),
+          ),
+        ],
+      ),
+    );
+    return result ?? false;
   }
 
   void _showError(String message) {
@@ -110,7 +148,7 @@ class _QRLoginScreenState extends State<QRLoginScreen> {
                         ),
                         const SizedBox(width: 8),
                         const Text(
-                          '如何使用 QR Code 登入',
+                          '如何用手機允許其他裝置登入',
                           style: TextStyle(
                             fontWeight: FontWeight.bold,
                             fontSize: 16,
@@ -119,10 +157,9 @@ class _QRLoginScreenState extends State<QRLoginScreen> {
                       ],
                     ),
                     const SizedBox(height: 12),
-                    const Text('1. 在網頁版後台點擊個人頭像'),
-                    const Text('2. 選擇「QR Code」選項'),
-                    const Text('3. 使用此相機掃描螢幕上的 QR Code'),
-                    const Text('4. 等待驗證完成'),
+                    const Text('1. 在要登入的裝置上選擇「QR Code 登入」'),
+                    const Text('2. 使用此相機掃描螢幕上的 QR Code'),
+                    const Text('3. 核對裝置資訊後點選「允許登入」'),
                   ],
                 ),
               ),
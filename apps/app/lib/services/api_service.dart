@@ -48,18 +48,19 @@ class ApiService {
     return response.data;
   }
 
-  Future<Map<String, dynamic>> generateQRCode(String userId) async {
-    final response = await _dio.post('/auth/qr-generate', data: {
-      'user_id': userId,
-    });
+  // QR login approval: the signed-in app scans the QR code another device
+  // shows on its login page, then confirms or rejects that login.
+  Future<Map<String, dynamic>> scanQRLogin(String id) async {
+    final response = await _dio.post('/auth/qr/sessions/$id/scan');
     return response.data;
   }
 
-  Future<Map<String, dynamic>> verifyQRCode(String token) async {
-    final response = await _dio.post('/auth/qr-verify', data: {
-      'token': token,
-    });
-    return response.data;
+  Future<void> confirmQRLogin(String id) async {
+    await _dio.post('/auth/qr/sessions/$id/confirm');
+  }
+
+  Future<void> rejectQRLogin(String id) async {
+    await _dio.post('/auth/qr/sessions/$id/reject');
   }
 
   // User endpoints
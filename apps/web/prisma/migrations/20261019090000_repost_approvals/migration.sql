@@ -0,0 +1,54 @@
+-- AlterTable
+ALTER TABLE "reposts" ADD COLUMN     "approval_status" TEXT NOT NULL DEFAULT 'not_required',
+ADD COLUMN     "approved_at" TIMESTAMP(3),
+ADD COLUMN     "approved_by" TEXT;
+
+-- CreateTable
+CREATE TABLE "approval_policies" (
+    "id" TEXT NOT NULL,
+    "media_account_id" TEXT,
+    "topic_id" TEXT,
+    "approver_ids" TEXT[],
+    "allow_admins" BOOLEAN NOT NULL DEFAULT true,
+    "user_id" TEXT NOT NULL,
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(3) NOT NULL,
+
+    CONSTRAINT "approval_policies_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "repost_approvals" (
+    "id" TEXT NOT NULL,
+    "repost_id" TEXT NOT NULL,
+    "reviewer_id" TEXT NOT NULL,
+    "decision" TEXT NOT NULL,
+    "comment" TEXT,
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "repost_approvals_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "approval_policies_media_account_id_idx" ON "approval_policies"("media_account_id");
+
+-- CreateIndex
+CREATE INDEX "approval_policies_topic_id_idx" ON "approval_policies"("topic_id");
+
+-- CreateIndex
+CREATE INDEX "repost_approvals_repost_id_idx" ON "repost_approvals"("repost_id");
+
+-- AddForeignKey
+ALTER TABLE "approval_policies" ADD CONSTRAINT "approval_policies_media_account_id_fkey" FOREIGN KEY ("media_account_id") REFERENCES "media_accounts"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "approval_policies" ADD CONSTRAINT "approval_policies_topic_id_fkey" FOREIGN KEY ("topic_id") REFERENCES "topics"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "approval_policies" ADD CONSTRAINT "approval_policies_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "repost_approvals" ADD CONSTRAINT "repost_approvals_repost_id_fkey" FOREIGN KEY ("repost_id") REFERENCES "reposts"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "repost_approvals" ADD CONSTRAINT "repost_approvals_reviewer_id_fkey" FOREIGN KEY ("reviewer_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,10 @@
+-- AlterTable
+ALTER TABLE "sessions" ADD COLUMN     "created_at" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ADD COLUMN     "device_name" TEXT,
+ADD COLUMN     "ip_address" TEXT,
+ADD COLUMN     "last_seen_at" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ADD COLUMN     "login_method" TEXT NOT NULL DEFAULT 'password',
+ADD COLUMN     "user_agent" TEXT;
+
+-- CreateIndex
+CREATE INDEX "sessions_user_id_idx" ON "sessions"("user_id");
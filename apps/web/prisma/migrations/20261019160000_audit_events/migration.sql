@@ -0,0 +1,44 @@
+-- CreateTable
+CREATE TABLE "audit_events" (
+    "id" TEXT NOT NULL,
+    "action" TEXT NOT NULL,
+    "actor_id" TEXT,
+    "actor_email" TEXT,
+    "resource_type" TEXT NOT NULL,
+    "resource_id" TEXT NOT NULL,
+    "before" JSONB,
+    "after" JSONB,
+    "ip_address" TEXT,
+    "request_id" TEXT,
+    "metadata" JSONB,
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "audit_events_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "audit_events_created_at_idx" ON "audit_events"("created_at");
+
+-- CreateIndex
+CREATE INDEX "audit_events_actor_id_created_at_idx" ON "audit_events"("actor_id", "created_at");
+
+-- CreateIndex
+CREATE INDEX "audit_events_resource_type_resource_id_idx" ON "audit_events"("resource_type", "resource_id");
+
+-- CreateIndex
+CREATE INDEX "audit_events_action_created_at_idx" ON "audit_events"("action", "created_at");
+
+-- Audit events are append-only
+CREATE FUNCTION "audit_events_append_only"() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "audit_events_append_only"
+    BEFORE UPDATE OR DELETE ON "audit_events"
+    FOR EACH ROW EXECUTE FUNCTION "audit_events_append_only"();
+
+CREATE TRIGGER "audit_events_no_truncate"
+    BEFORE TRUNCATE ON "audit_events"
+    FOR EACH STATEMENT EXECUTE FUNCTION "audit_events_append_only"();
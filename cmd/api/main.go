@@ -1,11 +1,17 @@
 package main
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -13,8 +19,17 @@ import (
 	"github.com/redis/go-redis/v9"
 	"smg/pkg/config"
 	"smg/pkg/handlers"
+	"smg/pkg/health"
+	"smg/pkg/jwtkeys"
+	"smg/pkg/logging"
+	"smg/pkg/mailer"
+	"smg/pkg/metrics"
 	"smg/pkg/middleware"
+	"smg/pkg/oidc"
+	"smg/pkg/ratelimit"
+	"smg/pkg/repository/postgres"
 	"smg/pkg/services"
+	"smg/pkg/tracing"
 )
 
 func main() {
@@ -23,11 +38,40 @@ func main() {
 		log.Printf("Warning: .env file not found: %v", err)
 	}
 
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	flag.Parse()
+
 	// Initialize configuration
-	cfg := config.New()
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.Logging.Level, cfg.Logging.Format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	}, "smg-api")
+	if err != nil {
+		log.Fatal("Failed to set up tracing:", err)
+	}
 
 	// Connect to PostgreSQL
-	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	db, err := cfg.Database.Open()
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
@@ -39,121 +83,183 @@ func main() {
 	}
 
 	// Connect to Redis
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisURL,
-		Password: "",
-		DB:       0,
+	redisClient := redis.NewClient(cfg.Redis.Options())
+
+	metrics.RegisterAPI()
+	metrics.RegisterDB(db, "postgres")
+	redisClient.AddHook(metrics.RedisHook{})
+	if err := tracing.InstrumentRedis(redisClient); err != nil {
+		log.Fatal("Failed to instrument Redis:", err)
+	}
+
+	// Load token signing keys
+	keySet, err := jwtkeys.Load(jwtkeys.Config{
+		Secret:         cfg.JWT.Secret,
+		PrivateKey:     cfg.JWT.PrivateKey,
+		PrivateKeyFile: cfg.JWT.PrivateKeyFile,
+		PublicKeyFiles: cfg.JWT.PublicKeyFiles,
 	})
+	if err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
 
 	// Initialize services
-	userService := services.NewUserService(db)
-	topicService := services.NewTopicService(db)
-	mediaService := services.NewMediaService(db)
-	articleService := services.NewArticleService(db)
-	systemService := services.NewSystemService(db)
-	authService := services.NewAuthService(db, redisClient)
+	store := postgres.New(db)
+	approvalService := services.NewApprovalService(store)
+	userService := services.NewUserService(store)
+	topicService := services.NewTopicService(store)
+	mediaService := services.NewMediaService(store)
+	articleService := services.NewArticleService(store, approvalService)
+	systemService := services.NewSystemService(store)
+	auditService := services.NewAuditService(db)
+	authService := services.NewAuthService(db, redisClient, keySet)
+	sessionService := services.NewSessionService(db, redisClient)
+
+	var googleProvider *oidc.Provider
+	if cfg.Google.ClientID != "" {
+		googleProvider = oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.Google.Issuer,
+			ClientID:     cfg.Google.ClientID,
+			ClientSecret: cfg.Google.ClientSecret,
+			RedirectURL:  cfg.Google.RedirectURL,
+			HTTPClient:   tracing.HTTPClient(time.Second * 10),
+		})
+	}
+	oauthService := services.NewOAuthService(db, redisClient, authService, googleProvider)
+	mailService := mailer.New(mailer.Config{
+		Host:     cfg.SMTP.Host,
+		Port:     strconv.Itoa(cfg.SMTP.Port),
+		Username: cfg.SMTP.User,
+		Password: cfg.SMTP.Password,
+		From:     cfg.SMTP.From,
+	})
+	mfaService := services.NewMFAService(db, redisClient)
+	apiKeyService := services.NewAPIKeyService(store, redisClient)
+	qrLoginService := services.NewQRLoginService(redisClient, authService)
+	verificationService := services.NewVerificationService(db, mailService, sessionService, cfg.Server.AppURL)
+	rbacService := services.NewRBACService(store, redisClient)
+	workspaceService := services.NewWorkspaceService(db)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authService)
+	authHandler := handlers.NewAuthHandler(authService, sessionService, oauthService, verificationService)
 	userHandler := handlers.NewUserHandler(userService)
 	topicHandler := handlers.NewTopicHandler(topicService)
 	mediaHandler := handlers.NewMediaHandler(mediaService)
 	articleHandler := handlers.NewArticleHandler(articleService)
-	systemHandler := handlers.NewSystemHandler(systemService)
+	systemHandler := handlers.NewSystemHandler(systemService, auditService)
+	approvalHandler := handlers.NewApprovalHandler(approvalService)
+	rbacHandler := handlers.NewRBACHandler(rbacService)
+	workspaceHandler := handlers.NewWorkspaceHandler(workspaceService)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	qrLoginHandler := handlers.NewQRLoginHandler(qrLoginService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
 
 	// Setup Gin router
-	router := gin.Default()
+	router := gin.New()
+	// Only trusted proxies may set the client IP through X-Forwarded-For;
+	// otherwise clients could dodge the per-IP rate limits.
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid trusted proxies:", err)
+	}
 
 	// Middleware
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.RequestIDMiddleware(logger))
+	router.Use(middleware.TracingMiddleware())
 	router.Use(middleware.LoggerMiddleware())
+	router.Use(middleware.MetricsMiddleware())
+	router.Use(gin.Recovery())
+	router.Use(middleware.CORSMiddleware(cfg.Server.CORSOrigins))
+	router.Use(middleware.ErrorMiddleware())
 
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
-	})
+	// Health checks. /livez only tells the process is serving; /readyz
+	// also checks Postgres, Redis and the schema version. /health is kept
+	// for existing monitors and answers like /readyz.
+	liveness := health.NewProbe(cfg.Health.Timeout)
+	readiness := health.NewProbe(cfg.Health.Timeout).
+		Add("postgres", health.Postgres(db)).
+		Add("redis", health.Redis(redisClient)).
+		Add("migrations", health.Migrations(db, health.SchemaVersion))
+	router.GET("/livez", gin.WrapH(liveness))
+	router.GET("/readyz", gin.WrapH(readiness))
+	router.GET("/health", gin.WrapH(readiness))
 
-	// Public routes
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Token verification keys
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
+	// Public routes, rate limited per client IP
+	limiter := ratelimit.New(redisClient)
+	perIP := func(name string, requests int, window time.Duration) gin.HandlerFunc {
+		return middleware.RateLimitMiddleware(limiter, ratelimit.Limit{Name: name, Requests: requests, Window: window})
+	}
 	auth := router.Group("/api/auth")
 	{
-		auth.POST("/login", authHandler.Login)
-		auth.POST("/register", authHandler.Register)
+		auth.POST("/login", perIP("login", 20, time.Minute), authHandler.Login)
+		auth.POST("/mfa/verify", perIP("mfa-verify", 20, time.Minute), authHandler.VerifyMFA)
+		auth.POST("/register", perIP("register", 5, time.Hour), authHandler.Register)
 		auth.POST("/refresh", authHandler.RefreshToken)
 		auth.POST("/logout", authHandler.Logout)
-		auth.POST("/qr-generate", authHandler.GenerateQRCode)
-		auth.POST("/qr-verify", authHandler.VerifyQRCode)
+		auth.POST("/qr/sessions", perIP("qr-create", 20, time.Minute), qrLoginHandler.CreateSession)
+		auth.POST("/qr/sessions/:id/poll", perIP("qr-poll", 120, time.Minute), qrLoginHandler.Poll)
+		auth.GET("/qr/sessions/:id/ws", perIP("qr-poll", 120, time.Minute), qrLoginHandler.Watch)
+		auth.GET("/google", authHandler.GoogleLogin)
+		auth.GET("/google/callback", authHandler.GoogleCallback)
+		auth.POST("/verify-email", perIP("verify-email", 20, time.Minute), authHandler.VerifyEmail)
+		auth.POST("/password/forgot", perIP("password-forgot", 5, time.Hour), authHandler.ForgotPassword)
+		auth.POST("/password/reset", perIP("password-reset", 20, time.Minute), authHandler.ResetPassword)
 	}
 
 	// Protected routes
 	api := router.Group("/api")
-	api.Use(middleware.AuthMiddleware(authService))
-	{
-		// User routes
-		users := api.Group("/users")
-		{
-			users.GET("/profile", userHandler.GetProfile)
-			users.PUT("/profile", userHandler.UpdateProfile)
-			users.GET("/", userHandler.GetUsers) // Admin only
-			users.GET("/:id", userHandler.GetUserByID)
-			users.PUT("/:id", userHandler.UpdateUser)
-			users.DELETE("/:id", userHandler.DeleteUser)
-		}
+	api.Use(middleware.AuthMiddleware(authService, apiKeyService, rbacService))
+	api.Use(middleware.MFAEnrollmentMiddleware(mfaService))
+	api.Use(middleware.WorkspaceMiddleware(workspaceService))
+	protectedRoutes(api, apiHandlers{
+		auth:      authHandler,
+		user:      userHandler,
+		topic:     topicHandler,
+		media:     mediaHandler,
+		article:   articleHandler,
+		system:    systemHandler,
+		approval:  approvalHandler,
+		rbac:      rbacHandler,
+		workspace: workspaceHandler,
+		mfa:       mfaHandler,
+		qrLogin:   qrLoginHandler,
+		apiKey:    apiKeyHandler,
+	})
 
-		// Topic routes
-		topics := api.Group("/topics")
-		{
-			topics.GET("/", topicHandler.GetTopics)
-			topics.POST("/", topicHandler.CreateTopic)
-			topics.GET("/:id", topicHandler.GetTopic)
-			topics.PUT("/:id", topicHandler.UpdateTopic)
-			topics.DELETE("/:id", topicHandler.DeleteTopic)
-		}
+	server := &http.Server{
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
 
-		// Media account routes
-		media := api.Group("/media")
-		{
-			media.GET("/accounts", mediaHandler.GetAccounts)
-			media.POST("/accounts", mediaHandler.CreateAccount)
-			media.GET("/accounts/:id", mediaHandler.GetAccount)
-			media.PUT("/accounts/:id", mediaHandler.UpdateAccount)
-			media.DELETE("/accounts/:id", mediaHandler.DeleteAccount)
-			media.POST("/connect/:platform", mediaHandler.ConnectPlatform)
-			media.POST("/disconnect/:id", mediaHandler.DisconnectAccount)
-		}
+	// Stop on SIGINT or SIGTERM, letting requests in flight finish and
+	// flushing buffered spans.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		// Article routes
-		articles := api.Group("/articles")
-		{
-			articles.GET("/", articleHandler.GetArticles)
-			articles.POST("/", articleHandler.CreateArticle)
-			articles.GET("/:id", articleHandler.GetArticle)
-			articles.PUT("/:id", articleHandler.UpdateArticle)
-			articles.DELETE("/:id", articleHandler.DeleteArticle)
-			articles.POST("/:id/repost", articleHandler.RepostArticle)
-			articles.GET("/reposts", articleHandler.GetReposts)
+	go func() {
+		logger.Info("server starting", "port", cfg.Server.Port, "environment", cfg.Environment)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
 		}
+	}()
 
-		// System settings routes (Admin only)
-		system := api.Group("/system")
-		system.Use(middleware.AdminMiddleware())
-		{
-			system.GET("/settings", systemHandler.GetSettings)
-			system.PUT("/settings", systemHandler.UpdateSettings)
-			system.GET("/stats", systemHandler.GetStats)
-			system.GET("/platforms", systemHandler.GetPlatforms)
-			system.POST("/platforms", systemHandler.CreatePlatform)
-			system.PUT("/platforms/:id", systemHandler.UpdatePlatform)
-			system.DELETE("/platforms/:id", systemHandler.DeletePlatform)
-		}
-	}
+	<-ctx.Done()
+	logger.Info("server stopping")
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("server shutdown failed", "error", err)
 	}
-
-	log.Printf("Server starting on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("flushing spans failed", "error", err)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"smg/pkg/handlers"
+	"smg/pkg/middleware"
+	"smg/pkg/rbac"
+)
+
+// apiHandlers are the handlers behind the protected routes.
+type apiHandlers struct {
+	auth      *handlers.AuthHandler
+	user      *handlers.UserHandler
+	topic     *handlers.TopicHandler
+	media     *handlers.MediaHandler
+	article   *handlers.ArticleHandler
+	system    *handlers.SystemHandler
+	approval  *handlers.ApprovalHandler
+	rbac      *handlers.RBACHandler
+	workspace *handlers.WorkspaceHandler
+	mfa       *handlers.MFAHandler
+	qrLogin   *handlers.QRLoginHandler
+	apiKey    *handlers.APIKeyHandler
+}
+
+// protectedRoutes registers the routes for authenticated users on api.
+// Every route either requires a named permission, which an API key only
+// holds when one of its scopes grants it, or denies API keys outright.
+func protectedRoutes(api *gin.RouterGroup, h apiHandlers) {
+	denyKeys := middleware.DenyAPIKeys()
+
+	// Account routes, not available to API keys
+	account := api.Group("/auth")
+	account.Use(denyKeys)
+	{
+		// Session routes
+		account.GET("/sessions", h.auth.GetSessions)
+		account.DELETE("/sessions/:id", h.auth.RevokeSession)
+		account.POST("/verify-email/resend", h.auth.ResendVerification)
+
+		// QR login approval, from an already signed-in device
+		account.POST("/qr/sessions/:id/scan", h.qrLogin.Scan)
+		account.POST("/qr/sessions/:id/confirm", h.qrLogin.Confirm)
+		account.POST("/qr/sessions/:id/reject", h.qrLogin.Reject)
+
+		// Two-factor authentication routes
+		account.GET("/2fa", h.mfa.GetStatus)
+		account.POST("/2fa/setup", h.mfa.Setup)
+		account.POST("/2fa/enable", h.mfa.Enable)
+		account.POST("/2fa/disable", h.mfa.Disable)
+		account.POST("/2fa/recovery-codes", h.mfa.RegenerateRecoveryCodes)
+	}
+
+	// API key routes
+	apiKeys := api.Group("/api-keys")
+	apiKeys.Use(denyKeys)
+	{
+		apiKeys.GET("/", h.apiKey.GetAPIKeys)
+		apiKeys.POST("/", h.apiKey.CreateAPIKey)
+		apiKeys.DELETE("/:id", h.apiKey.RevokeAPIKey)
+	}
+
+	// Role routes
+	api.GET("/roles", denyKeys, h.rbac.GetRoles)
+	api.GET("/roles/me", denyKeys, h.rbac.GetMyPermissions)
+
+	// Workspace routes, not available to API keys
+	workspaces := api.Group("/workspaces")
+	workspaces.Use(denyKeys)
+	{
+		workspaces.GET("/", h.workspace.GetWorkspaces)
+		workspaces.POST("/", h.workspace.CreateWorkspace)
+		workspaces.POST("/invitations/accept", h.workspace.AcceptInvitation)
+		workspaces.GET("/:id", h.workspace.GetWorkspace)
+		workspaces.PUT("/:id", h.workspace.UpdateWorkspace)
+		workspaces.DELETE("/:id", h.workspace.DeleteWorkspace)
+		workspaces.GET("/:id/members", h.workspace.GetMembers)
+		workspaces.PUT("/:id/members/:userId", h.workspace.SetMemberRole)
+		workspaces.DELETE("/:id/members/:userId", h.workspace.RemoveMember)
+		workspaces.GET("/:id/invitations", h.workspace.GetInvitations)
+		workspaces.POST("/:id/invitations", h.workspace.CreateInvitation)
+		workspaces.DELETE("/:id/invitations/:invitationId", h.workspace.RevokeInvitation)
+	}
+
+	// User routes. Reading or changing another user takes users:read or
+	// users:write; a user's own record needs neither. The routes on a single
+	// user are not available to keys.
+	users := api.Group("/users")
+	readUser := middleware.RequirePermissionOrSelf(rbac.UsersRead)
+	writeUser := middleware.RequirePermissionOrSelf(rbac.UsersWrite)
+	{
+		users.GET("/profile", denyKeys, h.user.GetProfile)
+		users.PUT("/profile", denyKeys, h.user.UpdateProfile)
+		users.GET("/", middleware.RequirePermission(rbac.UsersRead), h.user.GetUsers)
+		users.GET("/:id", denyKeys, readUser, h.user.GetUserByID)
+		users.PUT("/:id", denyKeys, writeUser, h.user.UpdateUser)
+		users.DELETE("/:id", denyKeys, writeUser, h.user.DeleteUser)
+		users.GET("/:id/stats", denyKeys, readUser, h.user.GetUserStats)
+		users.PUT("/:id/role", middleware.RequirePermission(rbac.RolesManage), h.rbac.SetUserRole)
+	}
+
+	// Topic routes
+	topics := api.Group("/topics")
+	topics.Use(middleware.RequirePermission(rbac.TopicsRead))
+	writeTopics := middleware.RequirePermission(rbac.TopicsWrite)
+	{
+		topics.GET("/", h.topic.GetTopics)
+		topics.POST("/", writeTopics, h.topic.CreateTopic)
+		topics.GET("/:id", h.topic.GetTopic)
+		topics.PUT("/:id", writeTopics, h.topic.UpdateTopic)
+		topics.DELETE("/:id", writeTopics, h.topic.DeleteTopic)
+		topics.GET("/:id/articles", h.topic.GetTopicArticles)
+		topics.GET("/:id/stats", h.topic.GetTopicStats)
+	}
+
+	// Media account routes
+	media := api.Group("/media")
+	media.Use(middleware.RequirePermission(rbac.MediaRead))
+	writeMedia := middleware.RequirePermission(rbac.MediaWrite)
+	{
+		media.GET("/accounts", h.media.GetAccounts)
+		media.POST("/accounts", writeMedia, h.media.CreateAccount)
+		media.GET("/accounts/:id", h.media.GetAccount)
+		media.PUT("/accounts/:id", writeMedia, h.media.UpdateAccount)
+		media.DELETE("/accounts/:id", writeMedia, h.media.DeleteAccount)
+		media.POST("/connect/:platform", writeMedia, h.media.ConnectPlatform)
+		media.POST("/disconnect/:id", writeMedia, h.media.DisconnectAccount)
+	}
+
+	// Article routes
+	articles := api.Group("/articles")
+	articles.Use(middleware.RequirePermission(rbac.ArticlesRead))
+	writeArticles := middleware.RequirePermission(rbac.ArticlesWrite)
+	{
+		articles.GET("/", h.article.GetArticles)
+		articles.POST("/", writeArticles, h.article.CreateArticle)
+		articles.POST("/bulk", writeArticles, h.article.BulkUpdateArticles)
+		articles.GET("/:id", h.article.GetArticle)
+		articles.PUT("/:id", writeArticles, h.article.UpdateArticle)
+		articles.DELETE("/:id", writeArticles, h.article.DeleteArticle)
+		articles.POST("/:id/repost", middleware.RequirePermission(rbac.RepostsWrite), h.article.RepostArticle)
+		articles.GET("/reposts", h.article.GetReposts)
+	}
+
+	// Repost approval routes
+	reposts := api.Group("/reposts")
+	reposts.Use(middleware.RequirePermission(rbac.ArticlesRead))
+	reviewReposts := middleware.RequirePermission(rbac.RepostsWrite)
+	{
+		reposts.GET("/approvals", h.approval.GetPendingApprovals)
+		reposts.GET("/:id/approvals", h.approval.GetRepostApprovals)
+		reposts.POST("/:id/approve", reviewReposts, h.approval.ApproveRepost)
+		reposts.POST("/:id/reject", reviewReposts, h.approval.RejectRepost)
+	}
+
+	// Approval policy routes
+	policies := api.Group("/approval-policies")
+	policies.Use(middleware.RequirePermission(rbac.MediaWrite))
+	{
+		policies.GET("/", h.approval.GetPolicies)
+		policies.POST("/", h.approval.CreatePolicy)
+		policies.PUT("/:id", h.approval.UpdatePolicy)
+		policies.DELETE("/:id", h.approval.DeletePolicy)
+	}
+
+	// System routes
+	system := api.Group("/system")
+	{
+		settings := middleware.RequirePermission(rbac.SystemSettings)
+		platforms := middleware.RequirePermission(rbac.SystemPlatforms)
+		audit := middleware.RequirePermission(rbac.SystemAudit)
+
+		system.GET("/settings", settings, h.system.GetSettings)
+		system.PUT("/settings", settings, h.system.UpdateSettings)
+		system.GET("/stats", middleware.RequirePermission(rbac.SystemStats), h.system.GetStats)
+		system.GET("/platforms", platforms, h.system.GetPlatforms)
+		system.POST("/platforms", platforms, h.system.CreatePlatform)
+		system.PUT("/platforms/:id", platforms, h.system.UpdatePlatform)
+		system.DELETE("/platforms/:id", platforms, h.system.DeletePlatform)
+		system.GET("/audit", audit, h.system.GetAuditEvents)
+		system.GET("/audit/export", audit, h.system.ExportAuditEvents)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"smg/pkg/handlers"
+	"smg/pkg/middleware"
+	"smg/pkg/models"
+	"smg/pkg/rbac"
+	"smg/pkg/repository/memory"
+	"smg/pkg/services"
+)
+
+// keyRouter serves the protected routes behind the real API key
+// authentication, on an in-memory store holding one admin. Only the topic
+// handler is backed by a service; requests reaching any other handler fail.
+func keyRouter(t *testing.T) (*gin.Engine, *services.APIKeyService, *models.User) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	// Every Redis command fails, which the services treat as a cold cache.
+	redisClient := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: time.Second})
+	t.Cleanup(func() { redisClient.Close() })
+
+	store := memory.New()
+	now := time.Now()
+	admin := &models.User{ID: "admin", Email: "admin@example.com", Role: rbac.RoleAdmin, IsAdmin: true, CreatedAt: now, UpdatedAt: now}
+	if err := store.Users().Create(admin); err != nil {
+		t.Fatal(err)
+	}
+	apiKeys := services.NewAPIKeyService(store, redisClient)
+
+	router := gin.New()
+	router.Use(gin.Recovery(), middleware.ErrorMiddleware())
+	api := router.Group("/api")
+	api.Use(middleware.AuthMiddleware(nil, apiKeys, services.NewRBACService(store, redisClient)))
+	h := unbackedHandlers()
+	h.topic = handlers.NewTopicHandler(services.NewTopicService(store))
+	protectedRoutes(api, h)
+
+	return router, apiKeys, admin
+}
+
+// unbackedHandlers returns handlers without services, for tests that only
+// reach a few of them.
+func unbackedHandlers() apiHandlers {
+	return apiHandlers{
+		auth:      new(handlers.AuthHandler),
+		user:      new(handlers.UserHandler),
+		topic:     new(handlers.TopicHandler),
+		media:     new(handlers.MediaHandler),
+		article:   new(handlers.ArticleHandler),
+		system:    new(handlers.SystemHandler),
+		approval:  new(handlers.ApprovalHandler),
+		rbac:      new(handlers.RBACHandler),
+		workspace: new(handlers.WorkspaceHandler),
+		mfa:       new(handlers.MFAHandler),
+		qrLogin:   new(handlers.QRLoginHandler),
+		apiKey:    new(handlers.APIKeyHandler),
+	}
+}
+
+func createKey(t *testing.T, apiKeys *services.APIKeyService, owner *models.User, scopes ...string) string {
+	t.Helper()
+	created, err := apiKeys.CreateAPIKey(owner, &models.CreateAPIKeyRequest{Name: "test", Scopes: scopes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created.Key
+}
+
+func serveWithKey(router *gin.Engine, key, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// concretePath fills the parameters of a route path with placeholder IDs.
+func concretePath(route string) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func TestReadOnlyAPIKeyCannotMutate(t *testing.T) {
+	router, apiKeys, admin := keyRouter(t)
+	// Even an admin's key holds only the scopes it was created with.
+	readOnly := createKey(t, apiKeys, admin, rbac.ArticlesRead, rbac.TopicsRead, rbac.MediaRead, rbac.UsersRead)
+
+	mutating := 0
+	for _, route := range router.Routes() {
+		if route.Method == http.MethodGet {
+			continue
+		}
+		mutating++
+		if w := serveWithKey(router, readOnly, route.Method, concretePath(route.Path)); w.Code != http.StatusForbidden {
+			t.Errorf("%s %s with a read-only key: got status %d, want 403: %s", route.Method, route.Path, w.Code, w.Body)
+		}
+	}
+	if mutating == 0 {
+		t.Fatal("no mutating routes registered")
+	}
+
+	// Reads about the account itself are not for keys either.
+	for _, path := range []string{
+		"/api/roles", "/api/roles/me", "/api/users/profile", "/api/users/admin", "/api/users/admin/stats",
+		"/api/workspaces/", "/api/api-keys/", "/api/auth/sessions",
+	} {
+		if w := serveWithKey(router, readOnly, http.MethodGet, path); w.Code != http.StatusForbidden {
+			t.Errorf("GET %s with a read-only key: got status %d, want 403: %s", path, w.Code, w.Body)
+		}
+	}
+
+	if w := serveWithKey(router, readOnly, http.MethodGet, "/api/topics/"); w.Code != http.StatusOK {
+		t.Fatalf("GET /api/topics/ with a read-only key: got status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestAPIKeyWithWriteScopeCanMutate(t *testing.T) {
+	router, apiKeys, admin := keyRouter(t)
+	writer := createKey(t, apiKeys, admin, rbac.TopicsRead, rbac.TopicsWrite)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/topics/", strings.NewReader(`{"name":"Go","keywords":["go"],"platforms":["twitter"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+writer)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/topics/ with topics:write: got status %d: %s", w.Code, w.Body)
+	}
+}
+
+// userRouter serves the protected routes on an in-memory store holding an
+// editor, a support user and an admin. Requests are signed in as the user
+// named by the X-User header, with the permissions of their role.
+func userRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	store := memory.New()
+	now := time.Now()
+	for _, role := range []string{rbac.RoleEditor, rbac.RoleSupport, rbac.RoleAdmin} {
+		user := &models.User{ID: role, Email: role + "@example.com", Role: role, IsAdmin: rbac.IsAdminRole(role), CreatedAt: now, UpdatedAt: now}
+		if err := store.Users().Create(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := gin.New()
+	router.Use(gin.Recovery(), middleware.ErrorMiddleware())
+	api := router.Group("/api")
+	api.Use(func(c *gin.Context) {
+		user, err := store.Users().Get(c.GetHeader("X-User"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Set("user", user)
+		c.Set("permissions", rbac.Permissions(user.Role))
+	})
+	h := unbackedHandlers()
+	h.user = handlers.NewUserHandler(services.NewUserService(store))
+	protectedRoutes(api, h)
+	return router
+}
+
+func TestUserRoutesRequirePermissionForOtherUsers(t *testing.T) {
+	router := userRouter(t)
+	tests := []struct {
+		actor, method, path string
+		status              int
+	}{
+		// Everyone reaches their own record.
+		{rbac.RoleEditor, http.MethodGet, "/api/users/editor", http.StatusOK},
+		{rbac.RoleEditor, http.MethodGet, "/api/users/editor/stats", http.StatusOK},
+		{rbac.RoleEditor, http.MethodPut, "/api/users/editor", http.StatusOK},
+
+		// Other users take users:read to read and users:write to change.
+		{rbac.RoleEditor, http.MethodGet, "/api/users/support", http.StatusForbidden},
+		{rbac.RoleEditor, http.MethodGet, "/api/users/support/stats", http.StatusForbidden},
+		{rbac.RoleEditor, http.MethodDelete, "/api/users/support", http.StatusForbidden},
+		{rbac.RoleSupport, http.MethodGet, "/api/users/editor", http.StatusOK},
+		{rbac.RoleSupport, http.MethodGet, "/api/users/editor/stats", http.StatusOK},
+		{rbac.RoleSupport, http.MethodPut, "/api/users/editor", http.StatusForbidden},
+		{rbac.RoleSupport, http.MethodDelete, "/api/users/editor", http.StatusForbidden},
+		{rbac.RoleAdmin, http.MethodPut, "/api/users/editor", http.StatusOK},
+		{rbac.RoleAdmin, http.MethodDelete, "/api/users/support", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User", test.actor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s %s as %s: got status %d, want %d: %s", test.method, test.path, test.actor, w.Code, test.status, w.Body)
+		}
+	}
+}
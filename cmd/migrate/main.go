@@ -1,116 +1,376 @@
 package main
 
 import (
+	"bufio"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"smg/pkg/config"
+	"smg/pkg/migrations"
 )
 
+const usage = `Usage: go run ./cmd/migrate [flags] <command> [args]
+
+Commands:
+  status       list applied and pending migrations
+  version      print the current version
+  up [N]       apply every pending migration, or the next N
+  down [N]     roll back every migration, or the last N
+  goto V       migrate up or down to version V
+  force V      record version V as cleanly applied without running anything,
+               after repairing a dirty database by hand
+  create NAME  write empty NAME up and down migrations stamped with UTC now
+  seed         load the fixtures for -env
+
+Flags:
+`
+
+// defaultDir is where create writes when -source is not given, relative
+// to the repository root.
+const defaultDir = "pkg/migrations"
+
 func main() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: .env file not found: %v", err)
 	}
 
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		log.Fatal("DATABASE_URL environment variable is required")
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	databaseURL := flag.String("database", "", "database URL (default database.url from the configuration)")
+	sourceDir := flag.String("source", "", "migrations directory (default the migrations built in from "+defaultDir+")")
+	env := flag.String("env", "", "environment whose fixtures seed loads (default the configured environment)")
+	yes := flag.Bool("yes", false, "roll back without asking for confirmation")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *databaseURL != "" {
+		cfg.Database.URL = *databaseURL
+	}
+
+	args := flag.Args()
+	if len(args) < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	command := args[0]
+
+	// create only writes files
+	if command == "create" {
+		if len(args) != 2 {
+			log.Fatal("Usage: create <name>")
+		}
+		dir := *sourceDir
+		if dir == "" {
+			dir = defaultDir
+		}
+		files, err := createMigration(dir, args[1], time.Now())
+		if err != nil {
+			log.Fatal("Failed to create migration: ", err)
+		}
+		fmt.Printf("Created migration files:\n- %s\n- %s\n", files[0], files[1])
+		fmt.Println("Add the same migration to apps/web/prisma/migrations and update health.SchemaVersion.")
+		return
 	}
 
 	// Connect to database
-	db, err := sql.Open("postgres", databaseURL)
+	db, err := cfg.Database.Open()
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 	defer db.Close()
+	target := describe(cfg.Database.URL)
 
-	// Create migration driver
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
-	if err != nil {
-		log.Fatal("Failed to create migration driver:", err)
+	if command == "seed" {
+		environment := *env
+		if environment == "" {
+			environment = cfg.Environment
+		}
+		if err := migrations.Seed(db, environment); err != nil {
+			log.Fatal("Failed to seed database: ", err)
+		}
+		fmt.Printf("Loaded %s fixtures into %s\n", environment, target)
+		return
 	}
 
 	// Create migrator
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		"postgres",
-		driver,
-	)
+	var source fs.FS = migrations.FS
+	if *sourceDir != "" {
+		source = os.DirFS(*sourceDir)
+	}
+	m, err := newMigrator(db, source)
 	if err != nil {
 		log.Fatal("Failed to create migrator:", err)
 	}
 
-	// Get command line argument
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: go run main.go [up|down|version|create <name>]")
-	}
+	switch command {
+	case "status":
+		if err := printStatus(m, source, target); err != nil {
+			log.Fatal("Failed to read status: ", err)
+		}
 
-	command := os.Args[1]
+	case "version":
+		version, dirty, err := m.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			fmt.Println("No migrations applied")
+			return
+		}
+		if err != nil {
+			log.Fatal("Failed to get version:", err)
+		}
+		fmt.Printf("Current version: %d, Dirty: %v\n", version, dirty)
 
-	switch command {
 	case "up":
-		if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-			log.Fatal("Failed to run migrations:", err)
+		n, all := count(args)
+		if all {
+			report(m.Up(), "Migrations completed successfully")
+		} else {
+			report(m.Steps(n), fmt.Sprintf("Applied %d migration(s)", n))
 		}
-		fmt.Println("Migrations completed successfully")
 
 	case "down":
-		if err := m.Down(); err != nil && err != migrate.ErrNoChange {
-			log.Fatal("Failed to rollback migrations:", err)
+		n, all := count(args)
+		what := "every migration"
+		if !all {
+			what = fmt.Sprintf("the last %d migration(s)", n)
+		}
+		if !*yes && !confirm(fmt.Sprintf("Roll back %s on %s?", what, target)) {
+			fmt.Println("Aborted")
+			return
+		}
+		if all {
+			report(m.Down(), "Migrations rolled back successfully")
+		} else {
+			report(m.Steps(-n), fmt.Sprintf("Rolled back %d migration(s)", n))
 		}
-		fmt.Println("Migrations rolled back successfully")
 
-	case "version":
-		version, dirty, err := m.Version()
-		if err != nil {
+	case "goto":
+		version := versionArg(args)
+		current, _, err := m.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
 			log.Fatal("Failed to get version:", err)
 		}
-		fmt.Printf("Current version: %d, Dirty: %v\n", version, dirty)
+		if uint(version) < current && !*yes &&
+			!confirm(fmt.Sprintf("Roll back %s from %d to %d?", target, current, version)) {
+			fmt.Println("Aborted")
+			return
+		}
+		report(m.Migrate(uint(version)), fmt.Sprintf("Migrated to version %d", version))
 
-	case "create":
-		if len(os.Args) < 3 {
-			log.Fatal("Usage: go run main.go create <migration_name>")
+	case "force":
+		version := versionArg(args)
+		if err := m.Force(int(version)); err != nil {
+			log.Fatal("Failed to force version:", err)
 		}
-		createMigration(os.Args[2])
+		fmt.Printf("Version forced to %d\n", version)
 
 	default:
-		log.Fatal("Unknown command. Use: up, down, version, or create")
+		log.Fatalf("Unknown command %q. Use: status, version, up, down, goto, force, create or seed", command)
 	}
 }
 
-func createMigration(name string) {
-	// Create migrations directory if it doesn't exist
-	if err := os.MkdirAll("migrations", 0755); err != nil {
-		log.Fatal("Failed to create migrations directory:", err)
+// newMigrator returns a migrator that logs each migration it runs and
+// stops after the current one on Ctrl-C, so the database is not left dirty.
+func newMigrator(db *sql.DB, source fs.FS) (*migrate.Migrate, error) {
+	m, err := migrations.Open(db, source)
+	if err != nil {
+		return nil, err
 	}
+	m.Log = logger{}
 
-	// Generate timestamp
-	timestamp := fmt.Sprintf("%d", getCurrentTimestamp())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		log.Println("Stopping after the current migration")
+		m.GracefulStop <- true
+	}()
 
-	// Create migration files
-	upFile := fmt.Sprintf("migrations/%s_%s.up.sql", timestamp, name)
-	downFile := fmt.Sprintf("migrations/%s_%s.down.sql", timestamp, name)
+	return m, nil
+}
 
-	// Create up migration file
-	if err := os.WriteFile(upFile, []byte("-- Migration up\n"), 0644); err != nil {
-		log.Fatal("Failed to create up migration file:", err)
+type logger struct{}
+
+func (logger) Printf(format string, v ...interface{}) {
+	log.Printf(strings.TrimSuffix(format, "\n"), v...)
+}
+
+func (logger) Verbose() bool { return false }
+
+// report prints done for a successful run and explains failures.
+func report(err error, done string) {
+	var short migrate.ErrShortLimit
+	var dirty migrate.ErrDirty
+	switch {
+	case err == nil:
+		fmt.Println(done)
+	case errors.Is(err, migrate.ErrNoChange):
+		fmt.Println("No change")
+	case errors.As(err, &short):
+		fmt.Printf("Stopped early: %d fewer migration(s) than requested were available\n", short.Short)
+	case errors.As(err, &dirty):
+		log.Fatalf("Migration %d failed part-way and the database is dirty. "+
+			"Repair the schema by hand, then run force with the last version that is fully applied.", dirty.Version)
+	default:
+		log.Fatal("Migration failed: ", err)
+	}
+}
+
+// printStatus lists every migration in source as applied or pending.
+func printStatus(m *migrate.Migrate, source fs.FS, target string) error {
+	current, dirty, err := m.Version()
+	applied := err == nil
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return err
+	}
+
+	src, err := iofs.New(source, ".")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fmt.Printf("Database: %s\n", target)
+	switch {
+	case !applied:
+		fmt.Println("Version:  none")
+	case dirty:
+		fmt.Printf("Version:  %d (dirty)\n", current)
+	default:
+		fmt.Printf("Version:  %d\n", current)
 	}
+	fmt.Println()
+
+	known := !applied
+	version, err := src.First()
+	for err == nil {
+		_, name, readErr := src.ReadUp(version)
+		if readErr != nil && !errors.Is(readErr, fs.ErrNotExist) {
+			return readErr
+		}
+
+		state := "pending"
+		switch {
+		case applied && version == current && dirty:
+			state = "dirty"
+		case applied && version <= current:
+			state = "applied"
+		}
+		if version == current {
+			known = true
+		}
+		fmt.Printf("%-8s %d_%s\n", state, version, name)
 
-	// Create down migration file
-	if err := os.WriteFile(downFile, []byte("-- Migration down\n"), 0644); err != nil {
-		log.Fatal("Failed to create down migration file:", err)
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
 	}
 
-	fmt.Printf("Created migration files:\n- %s\n- %s\n", upFile, downFile)
+	if !known {
+		fmt.Printf("\nVersion %d is not in the source; it was applied by a newer build.\n", current)
+	}
+	return nil
 }
 
-func getCurrentTimestamp() int64 {
-	return 20240101000000 // This should be replaced with actual timestamp generation
-}
\ No newline at end of file
+// count parses the optional N of up and down. all is true without one.
+func count(args []string) (n int, all bool) {
+	if len(args) < 2 {
+		return 0, true
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 {
+		log.Fatalf("Usage: %s [N], where N is a positive number of migrations", args[0])
+	}
+	return n, false
+}
+
+// versionArg parses the V of goto and force.
+func versionArg(args []string) int64 {
+	if len(args) != 2 {
+		log.Fatalf("Usage: %s <version>", args[0])
+	}
+	version, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || version < 0 {
+		log.Fatalf("Invalid version %q: use the numeric prefix of a migration", args[1])
+	}
+	return version
+}
+
+// confirm asks a yes/no question on stdin and defaults to no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// describe names the database in url without its credentials.
+func describe(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "the configured database"
+	}
+	return u.Host + u.Path
+}
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// createMigration writes empty up and down files for name into dir,
+// versioned with now in UTC in the format Prisma names migrations with.
+// Existing files are never overwritten.
+func createMigration(dir, name string, now time.Time) ([]string, error) {
+	if !migrationName.MatchString(name) {
+		return nil, fmt.Errorf("name %q may only contain lowercase letters, digits and underscores", name)
+	}
+
+	base := now.UTC().Format("20060102150405") + "_" + name
+	var files []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, base+"."+direction+".sql")
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return files, err
+		}
+		_, err = fmt.Fprintf(file, "-- Migration %s\n", direction)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return files, err
+		}
+		files = append(files, path)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, time.October, 19, 21, 30, 5, 0, time.FixedZone("CST", 8*60*60))
+
+	files, err := createMigration(dir, "add_tags", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "20261019133005_add_tags.up.sql"),
+		filepath.Join(dir, "20261019133005_add_tags.down.sql"),
+	}
+	if len(files) != 2 || files[0] != want[0] || files[1] != want[1] {
+		t.Fatalf("files = %v, want %v", files, want)
+	}
+	body, err := os.ReadFile(files[1])
+	if err != nil || string(body) != "-- Migration down\n" {
+		t.Errorf("down file = %q, %v", body, err)
+	}
+
+	if _, err := createMigration(dir, "add_tags", now); err == nil {
+		t.Error("second create with the same version overwrote the files")
+	}
+	if _, err := createMigration(dir, "add_tags", now.Add(time.Second)); err != nil {
+		t.Errorf("create a second later: %v", err)
+	}
+	if _, err := createMigration(dir, "../escape", now); err == nil {
+		t.Error("created a migration with a path in its name")
+	}
+}
@@ -1,20 +1,36 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"smg/pkg/config"
+	"smg/pkg/health"
+	"smg/pkg/logging"
+	"smg/pkg/metrics"
+	"smg/pkg/tracing"
 )
 
 type Scheduler struct {
-	db   *sql.DB
-	cron *cron.Cron
+	db     *sql.DB
+	cron   *cron.Cron
+	cfg    *config.Config
+	logger *slog.Logger
 }
 
 func main() {
@@ -23,13 +39,39 @@ func main() {
 		log.Printf("Warning: .env file not found: %v", err)
 	}
 
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		log.Fatal("DATABASE_URL environment variable is required")
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.Logging.Level, cfg.Logging.Format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	}, "smg-scheduler")
+	if err != nil {
+		log.Fatal("Failed to set up tracing:", err)
 	}
 
 	// Connect to database
-	db, err := sql.Open("postgres", databaseURL)
+	db, err := cfg.Database.Open()
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
@@ -40,90 +82,184 @@ func main() {
 		log.Fatal("Database connection failed:", err)
 	}
 
+	metrics.RegisterScheduler()
+	metrics.RegisterDB(db, "postgres")
+
 	// Create scheduler
 	scheduler := &Scheduler{
-		db:   db,
-		cron: cron.New(cron.WithSeconds()),
+		db:     db,
+		cron:   cron.New(cron.WithSeconds()),
+		cfg:    cfg,
+		logger: logger,
 	}
 
 	// Schedule jobs
 	scheduler.scheduleJobs()
 
+	if cfg.Scheduler.AdminAddr != "" {
+		go scheduler.serveAdmin(cfg.Scheduler.AdminAddr)
+	}
+
 	// Start scheduler
 	scheduler.cron.Start()
-	defer scheduler.cron.Stop()
 
-	log.Println("Scheduler started successfully")
+	logger.Info("scheduler started", "environment", cfg.Environment)
 
-	// Keep the program running
-	select {}
+	// Run until SIGINT or SIGTERM, then let running jobs finish and flush
+	// buffered spans.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	logger.Info("scheduler stopping")
+	<-scheduler.cron.Stop().Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("flushing spans failed", "error", err)
+	}
 }
 
 func (s *Scheduler) scheduleJobs() {
 	// Process scheduled reposts every minute
-	s.cron.AddFunc("0 * * * * *", s.processScheduledReposts)
+	s.schedule("0 * * * * *", "process_scheduled_reposts", s.processScheduledReposts)
 
 	// Fetch articles every 10 minutes
-	s.cron.AddFunc("0 */10 * * * *", s.fetchArticles)
+	s.schedule("0 */10 * * * *", "fetch_articles", s.fetchArticles)
 
 	// Generate AI captions every 5 minutes
-	s.cron.AddFunc("0 */5 * * * *", s.generateAICaptions)
+	s.schedule("0 */5 * * * *", "generate_ai_captions", s.generateAICaptions)
 
 	// Cleanup old data daily at 2 AM
-	s.cron.AddFunc("0 0 2 * * *", s.cleanupOldData)
+	s.schedule("0 0 2 * * *", "cleanup_old_data", s.cleanupOldData)
+}
 
-	log.Println("Scheduled jobs:")
-	log.Println("- Process scheduled reposts: every minute")
-	log.Println("- Fetch articles: every 10 minutes")
-	log.Println("- Generate AI captions: every 5 minutes")
-	log.Println("- Cleanup old data: daily at 2 AM")
+// serveAdmin serves /metrics, /livez and /readyz on addr. The scheduler
+// cannot do its work unobserved, so failing to listen stops it.
+func (s *Scheduler) serveAdmin(addr string) {
+	timeout := s.cfg.Health.Timeout
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/livez", health.NewProbe(timeout))
+	mux.Handle("/readyz", health.NewProbe(timeout).
+		Add("postgres", health.Postgres(s.db)).
+		Add("migrations", health.Migrations(s.db, health.SchemaVersion)))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second * 10,
+	}
+
+	s.logger.Info("admin server starting", "addr", addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatal("Failed to start admin server:", err)
+	}
+}
+
+// schedule adds a job. Every run gets its own run ID and trace, and the job
+// receives a context whose logger carries the job name, run ID and trace ID.
+// A job returns an error when the run as a whole failed; failures of single
+// items are logged and counted by the job itself.
+func (s *Scheduler) schedule(spec, name string, job func(ctx context.Context) error) {
+	_, err := s.cron.AddFunc(spec, func() {
+		runID := logging.NewID()
+		ctx, span := tracing.Start(context.Background(), "job "+name,
+			trace.WithAttributes(attribute.String("job", name), attribute.String("run_id", runID)),
+		)
+
+		logger := s.logger.With("job", name, "run_id", runID)
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			logger = logger.With("trace_id", traceID)
+		}
+		ctx = logging.WithLogger(ctx, logger)
+
+		start := time.Now()
+		logger.Info("job started")
+		err := job(ctx)
+		duration := time.Since(start)
+		tracing.End(span, err)
+		metrics.ObserveJobRun(name, err, duration)
+		if err != nil {
+			logger.Error("job failed", "duration", duration, "error", err)
+			return
+		}
+		logger.Info("job finished", "duration", duration)
+	})
+	if err != nil {
+		log.Fatalf("Failed to schedule %s: %v", name, err)
+	}
+	s.logger.Info("job scheduled", "job", name, "spec", spec)
 }
 
-func (s *Scheduler) processScheduledReposts() {
-	log.Println("Processing scheduled reposts...")
+func (s *Scheduler) processScheduledReposts(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
 
 	// Get reposts that are scheduled for now or earlier
-	rows, err := s.db.Query(`
-		SELECT id, article_id, media_account_id, custom_caption, ai_caption, user_id
-		FROM reposts 
-		WHERE status = 'pending' 
-		AND scheduled_at <= NOW()
-		LIMIT 10
-	`)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.id, r.article_id, r.media_account_id, r.custom_caption, r.ai_caption, r.user_id,
+			COALESCE(r.request_id, ''), COALESCE(r.trace_context, ''), m.platform
+		FROM reposts r
+		JOIN media_accounts m ON m.id = r.media_account_id
+		WHERE r.status = 'pending' 
+		AND r.approval_status IN ('not_required', 'approved')
+		AND r.scheduled_at <= NOW()
+		LIMIT $1
+	`, s.cfg.Publisher.BatchSize)
 	if err != nil {
-		log.Printf("Error fetching scheduled reposts: %v", err)
-		return
+		return fmt.Errorf("fetching scheduled reposts: %w", err)
 	}
 	defer rows.Close()
 
 	count := 0
 	for rows.Next() {
-		var repostID, articleID, mediaAccountID, userID string
+		var repostID, articleID, mediaAccountID, userID, requestID, traceContext, platform string
 		var customCaption, aiCaption *string
 
-		if err := rows.Scan(&repostID, &articleID, &mediaAccountID, &customCaption, &aiCaption, &userID); err != nil {
-			log.Printf("Error scanning repost: %v", err)
-			continue
+		err := rows.Scan(&repostID, &articleID, &mediaAccountID, &customCaption, &aiCaption, &userID,
+			&requestID, &traceContext, &platform)
+		if err != nil {
+			return fmt.Errorf("scanning repost: %w", err)
 		}
 
+		// The request ID and trace of the API call that queued the repost
+		// link this publish attempt to it.
+		options := []trace.SpanStartOption{trace.WithAttributes(
+			attribute.String("repost_id", repostID),
+			attribute.String("platform", platform),
+			attribute.String("request_id", requestID),
+		)}
+		if link, ok := tracing.LinkTo(traceContext); ok {
+			options = append(options, trace.WithLinks(link))
+		}
+		repostCtx, span := tracing.Start(ctx, "publish repost", options...)
+		repostCtx = logging.WithLogger(repostCtx, logger.With("repost_id", repostID, "request_id", requestID))
+
 		// Process the repost
-		if err := s.processRepost(repostID, articleID, mediaAccountID, customCaption, aiCaption, userID); err != nil {
-			log.Printf("Error processing repost %s: %v", repostID, err)
+		err = s.processRepost(repostCtx, repostID, articleID, mediaAccountID, customCaption, aiCaption, userID)
+		tracing.End(span, err)
+		if err != nil {
+			logging.FromContext(repostCtx).Error("publishing repost failed", "error", err)
+			metrics.RepostFailed(platform)
 			continue
 		}
+		metrics.RepostPublished(platform)
 
 		count++
 	}
-
-	if count > 0 {
-		log.Printf("Processed %d scheduled reposts", count)
+	if err := rows.Err(); err != nil {
+		return err
 	}
+
+	logger.Info("processed scheduled reposts", "count", count)
+	return nil
 }
 
-func (s *Scheduler) processRepost(repostID, articleID, mediaAccountID string, customCaption, aiCaption *string, userID string) error {
+func (s *Scheduler) processRepost(ctx context.Context, repostID, articleID, mediaAccountID string, customCaption, aiCaption *string, userID string) error {
 	// Get article content
 	var title, content, originalURL string
-	err := s.db.QueryRow(`
+	err := s.db.QueryRowContext(ctx, `
 		SELECT title, content, original_url 
 		FROM articles 
 		WHERE id = $1
@@ -134,7 +270,7 @@ func (s *Scheduler) processRepost(repostID, articleID, mediaAccountID string, cu
 
 	// Get media account info
 	var platform, accountName string
-	err = s.db.QueryRow(`
+	err = s.db.QueryRowContext(ctx, `
 		SELECT platform, account_name 
 		FROM media_accounts 
 		WHERE id = $1
@@ -152,10 +288,14 @@ func (s *Scheduler) processRepost(repostID, articleID, mediaAccountID string, cu
 	}
 
 	// Simulate posting to social media platform
-	log.Printf("Posting to %s (@%s): %s", platform, accountName, caption)
+	_, span := tracing.Start(ctx, "publisher post", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("platform", platform)),
+	)
+	logging.FromContext(ctx).Info("publishing repost", "platform", platform, "account", accountName, "caption", caption)
+	span.End()
 
 	// Update repost status
-	_, err = s.db.Exec(`
+	_, err = s.db.ExecContext(ctx, `
 		UPDATE reposts 
 		SET status = 'posted', posted_at = NOW(), updated_at = NOW()
 		WHERE id = $1
@@ -164,20 +304,21 @@ func (s *Scheduler) processRepost(repostID, articleID, mediaAccountID string, cu
 		return fmt.Errorf("failed to update repost status: %v", err)
 	}
 
+	logging.FromContext(ctx).Info("repost published")
+
 	return nil
 }
 
-func (s *Scheduler) fetchArticles() {
-	log.Println("Fetching articles...")
+func (s *Scheduler) fetchArticles(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
 
 	// Get all topics with their keywords
-	rows, err := s.db.Query(`
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, name, keywords, platforms, user_id 
 		FROM topics
 	`)
 	if err != nil {
-		log.Printf("Error fetching topics: %v", err)
-		return
+		return fmt.Errorf("fetching topics: %w", err)
 	}
 	defer rows.Close()
 
@@ -187,40 +328,54 @@ func (s *Scheduler) fetchArticles() {
 		var keywords, platforms []string
 
 		if err := rows.Scan(&topicID, &name, &keywords, &platforms, &userID); err != nil {
-			log.Printf("Error scanning topic: %v", err)
-			continue
+			return fmt.Errorf("scanning topic: %w", err)
 		}
 
 		// Simulate fetching articles for this topic
-		if err := s.fetchArticlesForTopic(topicID, name, keywords, platforms, userID); err != nil {
-			log.Printf("Error fetching articles for topic %s: %v", topicID, err)
+		topicCtx, span := tracing.Start(ctx, "fetch topic articles",
+			trace.WithAttributes(attribute.String("topic_id", topicID), attribute.StringSlice("platforms", platforms)),
+		)
+		topicCtx = logging.WithLogger(topicCtx, logger.With("topic_id", topicID))
+		err := s.fetchArticlesForTopic(topicCtx, topicID, name, keywords, platforms, userID)
+		tracing.End(span, err)
+		if err != nil {
+			logger.Error("fetching articles for topic failed", "topic_id", topicID, "error", err)
 			continue
 		}
 
 		count++
 	}
 
-	if count > 0 {
-		log.Printf("Fetched articles for %d topics", count)
+	if err := rows.Err(); err != nil {
+		return err
 	}
+
+	logger.Info("fetched articles", "topics", count)
+	return nil
 }
 
-func (s *Scheduler) fetchArticlesForTopic(topicID, name string, keywords, platforms []string, userID string) error {
+func (s *Scheduler) fetchArticlesForTopic(ctx context.Context, topicID, name string, keywords, platforms []string, userID string) error {
 	// This is a simulation - in real implementation, you would:
 	// 1. Use platform APIs to search for articles based on keywords
 	// 2. Filter and deduplicate articles
 	// 3. Store new articles in the database
 
-	log.Printf("Fetching articles for topic '%s' with keywords: %v on platforms: %v", name, keywords, platforms)
+	logger := logging.FromContext(ctx)
+	_, span := tracing.Start(ctx, "feed fetch", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("source", platforms[0])),
+	)
+	logger.Debug("fetching articles for topic", "topic", name, "keywords", keywords, "platforms", platforms)
+	span.End()
 
 	// Simulate finding 1-3 articles
+	ingested := 0
 	for i := 0; i < 2; i++ {
 		articleID := fmt.Sprintf("article_%d_%s", time.Now().Unix(), topicID)
 		
 		// Insert simulated article
-		_, err := s.db.Exec(`
-			INSERT INTO articles (id, title, content, original_url, platform, published_at, topic_id, user_id, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+		result, err := s.db.ExecContext(ctx, `
+			INSERT INTO articles (id, title, content, original_url, platform, published_at, topic_id, workspace_id, user_id, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, (SELECT workspace_id FROM topics WHERE id = $7), $8, NOW(), NOW())
 			ON CONFLICT (id) DO NOTHING
 		`, articleID, 
 			fmt.Sprintf("Article about %s", name),
@@ -232,28 +387,32 @@ func (s *Scheduler) fetchArticlesForTopic(topicID, name string, keywords, platfo
 			userID)
 		
 		if err != nil {
-			log.Printf("Error inserting article: %v", err)
+			logger.Error("inserting article failed", "article_id", articleID, "error", err)
+			continue
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			ingested++
 		}
 	}
+	metrics.ArticlesIngested(platforms[0], ingested)
 
 	return nil
 }
 
-func (s *Scheduler) generateAICaptions() {
-	log.Println("Generating AI captions...")
+func (s *Scheduler) generateAICaptions(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
 
 	// Get reposts that need AI captions
-	rows, err := s.db.Query(`
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT r.id, a.title, a.content
 		FROM reposts r
 		JOIN articles a ON r.article_id = a.id
 		WHERE r.ai_caption IS NULL
 		AND r.status = 'pending'
-		LIMIT 5
-	`)
+		LIMIT $1
+	`, s.cfg.AI.BatchSize)
 	if err != nil {
-		log.Printf("Error fetching reposts for AI captions: %v", err)
-		return
+		return fmt.Errorf("fetching reposts for AI captions: %w", err)
 	}
 	defer rows.Close()
 
@@ -262,85 +421,95 @@ func (s *Scheduler) generateAICaptions() {
 		var repostID, title, content string
 
 		if err := rows.Scan(&repostID, &title, &content); err != nil {
-			log.Printf("Error scanning repost for AI caption: %v", err)
-			continue
+			return fmt.Errorf("scanning repost for AI caption: %w", err)
 		}
 
 		// Generate AI caption (simulated)
-		aiCaption := s.generateAICaption(title, content)
+		start := time.Now()
+		aiCaption := s.generateAICaption(ctx, title, content)
+		metrics.ObserveAICaption(time.Since(start))
 
 		// Update repost with AI caption
-		_, err := s.db.Exec(`
+		_, err := s.db.ExecContext(ctx, `
 			UPDATE reposts 
 			SET ai_caption = $1, updated_at = NOW()
 			WHERE id = $2
 		`, aiCaption, repostID)
 		if err != nil {
-			log.Printf("Error updating AI caption for repost %s: %v", repostID, err)
+			logger.Error("updating AI caption failed", "repost_id", repostID, "error", err)
 			continue
 		}
 
 		count++
 	}
 
-	if count > 0 {
-		log.Printf("Generated AI captions for %d reposts", count)
+	if err := rows.Err(); err != nil {
+		return err
 	}
+
+	logger.Info("generated AI captions", "count", count)
+	return nil
 }
 
-func (s *Scheduler) generateAICaption(title, content string) string {
+func (s *Scheduler) generateAICaption(ctx context.Context, title, content string) string {
 	// This is a simulation - in real implementation, you would:
 	// 1. Use OpenAI API or similar service
 	// 2. Create a prompt with the article content
 	// 3. Generate an engaging caption
 
+	_, span := tracing.Start(ctx, "ai generate caption", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("model", s.cfg.AI.Model)),
+	)
+	defer span.End()
+
 	return fmt.Sprintf("🚀 %s - %s... #socialmedia #growth", title, content[:min(50, len(content))])
 }
 
-func (s *Scheduler) cleanupOldData() {
-	log.Println("Cleaning up old data...")
+func (s *Scheduler) cleanupOldData(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
+	var errs []error
 
 	// Delete old verification tokens (older than 1 day)
-	result, err := s.db.Exec(`
+	result, err := s.db.ExecContext(ctx, `
 		DELETE FROM verification_tokens 
 		WHERE expires < NOW() - INTERVAL '1 day'
 	`)
 	if err != nil {
-		log.Printf("Error cleaning up verification tokens: %v", err)
+		errs = append(errs, fmt.Errorf("cleaning up verification tokens: %w", err))
 	} else {
 		if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
-			log.Printf("Deleted %d old verification tokens", rowsAffected)
+			logger.Info("deleted old verification tokens", "count", rowsAffected)
 		}
 	}
 
 	// Delete old sessions (older than 7 days)
-	result, err = s.db.Exec(`
+	result, err = s.db.ExecContext(ctx, `
 		DELETE FROM sessions 
 		WHERE expires < NOW() - INTERVAL '7 days'
 	`)
 	if err != nil {
-		log.Printf("Error cleaning up sessions: %v", err)
+		errs = append(errs, fmt.Errorf("cleaning up sessions: %w", err))
 	} else {
 		if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
-			log.Printf("Deleted %d old sessions", rowsAffected)
+			logger.Info("deleted old sessions", "count", rowsAffected)
 		}
 	}
 
 	// Delete old articles (older than 30 days)
-	result, err = s.db.Exec(`
+	result, err = s.db.ExecContext(ctx, `
 		DELETE FROM articles 
 		WHERE created_at < NOW() - INTERVAL '30 days'
 		AND id NOT IN (SELECT article_id FROM reposts)
 	`)
 	if err != nil {
-		log.Printf("Error cleaning up articles: %v", err)
+		errs = append(errs, fmt.Errorf("cleaning up articles: %w", err))
 	} else {
 		if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
-			log.Printf("Deleted %d old articles", rowsAffected)
+			logger.Info("deleted old articles", "count", rowsAffected)
 		}
 	}
 
-	log.Println("Cleanup completed")
+	return errors.Join(errs...)
 }
 
 func min(a, b int) int {
@@ -0,0 +1,206 @@
+// Package apperr defines the errors services return when a request cannot
+// be served. An Error has a Kind, which decides the HTTP status, a stable
+// machine-readable code and an English message; the error middleware
+// renders it as RFC 7807 problem details in the client's language. Errors
+// of any other type are internal and never shown to clients.
+package apperr
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Kind classifies an error and decides its HTTP status.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindRateLimited
+	KindBadGateway
+)
+
+// Status returns the HTTP status for errors of kind k.
+func (k Kind) Status() int {
+	switch k {
+	case KindBadRequest, KindValidation:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindRateLimited:
+		return http.StatusTooManyRequests
+	case KindBadGateway:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Codes shared by several kinds of request.
+const (
+	CodeInternal        = "internal_error"
+	CodeValidation      = "validation_failed"
+	CodeNotFound        = "not_found"
+	CodeRateLimited     = "rate_limited"
+	CodeUnauthenticated = "unauthenticated"
+	CodeInvalidBody     = "invalid_body"
+	CodeEmptyBody       = "empty_body"
+)
+
+// Field error codes. Most are the validation tags of the request models.
+const (
+	FieldRequired = "required"
+	FieldInvalid  = "invalid"
+	FieldEmail    = "email"
+	FieldMin      = "min"
+	FieldMax      = "max"
+	FieldOneOf    = "oneof"
+)
+
+// Error is an error a client can act on.
+type Error struct {
+	Kind Kind
+	// Code is stable and machine-readable, such as "topic_not_found".
+	Code string
+	// Message is English and used when Code has no translation.
+	Message string
+	// Params fill the {name} placeholders of translated messages.
+	Params map[string]string
+	// Fields lists the invalid fields of a validation error.
+	Fields []FieldError
+	// RetryAfter tells a rate-limited client when to try again.
+	RetryAfter time.Duration
+	// Err is the underlying cause. It is logged but never shown.
+	Err error
+}
+
+// FieldError describes one invalid request field.
+type FieldError struct {
+	Field string
+	Code  string
+	// Param is the limit or choice list of a min, max or oneof rule.
+	Param string
+}
+
+// ErrUnauthenticated is returned when a route needs a user but the request
+// carries none.
+var ErrUnauthenticated = Unauthorized(CodeUnauthenticated, "User not authenticated")
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error with the same code, so the copies
+// made by Wrap and With still match the error they were made from.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Status returns the HTTP status of e.
+func (e *Error) Status() int {
+	return e.Kind.Status()
+}
+
+// Wrap returns a copy of e caused by err, so errors.Is still finds err.
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// With returns a copy of e whose messages have {name} replaced by value.
+func (e *Error) With(name, value string) *Error {
+	with := *e
+	with.Params = make(map[string]string, len(e.Params)+1)
+	for k, v := range e.Params {
+		with.Params[k] = v
+	}
+	with.Params[name] = value
+	with.Message = strings.ReplaceAll(e.Message, "{"+name+"}", value)
+	return &with
+}
+
+// New returns an error of the given kind.
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func BadRequest(code, message string) *Error {
+	return New(KindBadRequest, code, message)
+}
+
+func Unauthorized(code, message string) *Error {
+	return New(KindUnauthorized, code, message)
+}
+
+func Forbidden(code, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+func BadGateway(code, message string) *Error {
+	return New(KindBadGateway, code, message)
+}
+
+// NotFound reports that a resource, such as "topic" or "media_account",
+// does not exist or is outside the caller's reach. Its code is the resource
+// followed by "_not_found".
+func NotFound(resource string) *Error {
+	code := CodeNotFound
+	if resource != "" {
+		code = resource + "_" + CodeNotFound
+	}
+	return New(KindNotFound, code, message(code, English, strings.ReplaceAll(resource, "_", " ")+" not found"))
+}
+
+// Validation reports invalid request fields.
+func Validation(fields ...FieldError) *Error {
+	err := New(KindValidation, CodeValidation, "Validation failed")
+	err.Fields = fields
+	return err
+}
+
+// Required reports missing request fields.
+func Required(fields ...string) *Error {
+	errs := make([]FieldError, 0, len(fields))
+	for _, field := range fields {
+		errs = append(errs, FieldError{Field: field, Code: FieldRequired})
+	}
+	return Validation(errs...)
+}
+
+// Invalid reports a request field with a value that is not accepted.
+func Invalid(field string) *Error {
+	return Validation(FieldError{Field: field, Code: FieldInvalid})
+}
+
+// RateLimited reports that the client must wait retryAfter before trying
+// again.
+func RateLimited(retryAfter time.Duration) *Error {
+	err := New(KindRateLimited, CodeRateLimited, "Too many requests, try again later")
+	err.RetryAfter = retryAfter
+	return err
+}
@@ -0,0 +1,146 @@
+package apperr
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Languages messages are translated to.
+const (
+	English = "en"
+	Chinese = "zh"
+)
+
+var matcher = language.NewMatcher([]language.Tag{language.English, language.Chinese})
+
+// Language picks English or Chinese for an Accept-Language header,
+// defaulting to English.
+func Language(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return English
+	}
+	if _, index, confidence := matcher.Match(tags...); index == 1 && confidence != language.No {
+		return Chinese
+	}
+	return English
+}
+
+// messages translates error codes. Codes missing here fall back to the
+// error's own English message.
+var messages = map[string]map[string]string{
+	CodeInternal:        {English: "Something went wrong on our side", Chinese: "服务器内部错误"},
+	CodeValidation:      {English: "Some fields are invalid", Chinese: "部分字段无效"},
+	CodeNotFound:        {English: "Not found", Chinese: "未找到"},
+	CodeRateLimited:     {English: "Too many requests, try again later", Chinese: "请求过于频繁，请稍后再试"},
+	CodeUnauthenticated: {English: "User not authenticated", Chinese: "用户未登录"},
+	CodeInvalidBody:     {English: "Request body is not valid JSON", Chinese: "请求体不是有效的 JSON"},
+	CodeEmptyBody:       {English: "Request body is empty", Chinese: "请求体为空"},
+
+	"topic_not_found":           {English: "Topic not found", Chinese: "未找到主题"},
+	"article_not_found":         {English: "Article not found", Chinese: "未找到文章"},
+	"repost_not_found":          {English: "Repost not found", Chinese: "未找到转发"},
+	"media_account_not_found":   {English: "Media account not found", Chinese: "未找到媒体账号"},
+	"approval_policy_not_found": {English: "Approval policy not found", Chinese: "未找到审批策略"},
+	"user_not_found":            {English: "User not found", Chinese: "未找到用户"},
+	"workspace_not_found":       {English: "Workspace not found", Chinese: "未找到工作区"},
+	"member_not_found":          {English: "Workspace member not found", Chinese: "未找到工作区成员"},
+	"invitation_not_found":      {English: "Invitation not found", Chinese: "未找到邀请"},
+	"api_key_not_found":         {English: "API key not found", Chinese: "未找到 API 密钥"},
+	"session_not_found":         {English: "Session not found", Chinese: "未找到会话"},
+	"platform_not_found":        {English: "Platform not found", Chinese: "未找到平台"},
+	"qr_login_not_found":        {English: "QR login not found or expired", Chinese: "扫码登录不存在或已过期"},
+
+	"authorization_required":        {English: "Authorization header required", Chinese: "缺少 Authorization 请求头"},
+	"bearer_token_required":         {English: "Bearer token required", Chinese: "需要 Bearer 令牌"},
+	"invalid_token":                 {English: "Invalid token", Chinese: "令牌无效"},
+	"token_revoked":                 {English: "Token revoked", Chinese: "令牌已被撤销"},
+	"refresh_token_reused":          {English: "Refresh token reuse detected", Chinese: "检测到刷新令牌被重复使用"},
+	"invalid_api_key":               {English: "Invalid API key", Chinese: "API 密钥无效"},
+	"api_key_scope_not_granted":     {English: "Scope {scope} not granted by your role", Chinese: "你的角色未授予权限范围 {scope}"},
+	"api_key_not_allowed":           {English: "Not available with an API key", Chinese: "不能使用 API 密钥访问"},
+	"permission_required":           {English: "Permission {permission} required", Chinese: "需要 {permission} 权限"},
+	"workspace_membership_required": {English: "Not a member of this workspace", Chinese: "你不是该工作区的成员"},
+	"mfa_enrollment_required":       {English: "Two-factor authentication must be enabled", Chinese: "必须先启用双重验证"},
+	"too_many_attempts":             {English: "Too many attempts, try again later", Chinese: "尝试次数过多，请稍后再试"},
+	"invalid_credentials":           {English: "Invalid email or password", Chinese: "邮箱或密码错误"},
+	"email_taken":                   {English: "Email is already registered", Chinese: "该邮箱已被注册"},
+	"email_already_verified":        {English: "Email is already verified", Chinese: "邮箱已验证"},
+	"verification_token_invalid":    {English: "Invalid or expired token", Chinese: "链接无效或已过期"},
+
+	"mfa_already_enabled": {English: "Two-factor authentication is already enabled", Chinese: "双重验证已启用"},
+	"mfa_not_enabled":     {English: "Two-factor authentication is not enabled", Chinese: "双重验证未启用"},
+	"mfa_not_set_up":      {English: "Two-factor authentication has not been set up", Chinese: "尚未设置双重验证"},
+	"mfa_code_invalid":    {English: "Invalid authentication code", Chinese: "验证码错误"},
+	"mfa_token_invalid":   {English: "Invalid or expired MFA token", Chinese: "双重验证令牌无效或已过期"},
+	"mfa_required":        {English: "Two-factor authentication is required for admin users", Chinese: "管理员必须启用双重验证"},
+
+	"google_login_not_configured": {English: "Google login is not configured", Chinese: "未配置 Google 登录"},
+	"google_login_failed":         {English: "Google login failed", Chinese: "Google 登录失败"},
+	"google_unavailable":          {English: "Google could not be reached", Chinese: "无法连接 Google"},
+	"oauth_state_invalid":         {English: "Invalid or expired login state", Chinese: "登录状态无效或已过期"},
+	"email_not_verified":          {English: "Email address is not verified by the identity provider", Chinese: "身份提供方未验证该邮箱地址"},
+
+	"qr_login_state":      {English: "QR login cannot be changed in its current state", Chinese: "扫码登录当前状态下无法更改"},
+	"qr_login_poll_token": {English: "Invalid poll token", Chinese: "轮询令牌无效"},
+
+	"policy_target_required": {English: "Approval policy needs a media_account_id or a topic_id", Chinese: "审批策略需要指定 media_account_id 或 topic_id"},
+	"not_approver":           {English: "User is not an approver for this repost", Chinese: "你不是该转发的审批人"},
+	"repost_not_pending":     {English: "Repost is not awaiting approval", Chinese: "该转发不在待审批状态"},
+
+	"owner_required":        {English: "Only owners can grant or revoke the owner role", Chinese: "只有所有者可以授予或撤销所有者角色"},
+	"workspace_manager":     {English: "Workspace owner or admin role required", Chinese: "需要工作区所有者或管理员角色"},
+	"personal_workspace":    {English: "Personal workspaces cannot be deleted or lose their owner", Chinese: "个人工作区不能删除或移除所有者"},
+	"last_owner":            {English: "A workspace must keep at least one owner", Chinese: "工作区必须至少保留一名所有者"},
+	"invitation_invalid":    {English: "Invalid or expired invitation", Chinese: "邀请无效或已过期"},
+	"invitation_email":      {English: "Invitation was sent to a different email address", Chinese: "该邀请发送给了其他邮箱地址"},
+	"email_delivery_failed": {English: "The email could not be sent", Chinese: "邮件发送失败"},
+
+	"platform_exists": {English: "A platform with this name already exists", Chinese: "已存在同名平台"},
+}
+
+// titles are the problem titles of each kind.
+var titles = map[Kind]map[string]string{
+	KindInternal:     {English: "Internal error", Chinese: "内部错误"},
+	KindBadRequest:   {English: "Bad request", Chinese: "请求错误"},
+	KindValidation:   {English: "Validation failed", Chinese: "校验失败"},
+	KindUnauthorized: {English: "Unauthorized", Chinese: "未授权"},
+	KindForbidden:    {English: "Forbidden", Chinese: "禁止访问"},
+	KindNotFound:     {English: "Not found", Chinese: "未找到"},
+	KindConflict:     {English: "Conflict", Chinese: "冲突"},
+	KindRateLimited:  {English: "Too many requests", Chinese: "请求过多"},
+	KindBadGateway:   {English: "Bad gateway", Chinese: "上游服务错误"},
+}
+
+// fieldMessages translates field error codes; {param} is replaced with the
+// rule's parameter.
+var fieldMessages = map[string]map[string]string{
+	FieldRequired: {English: "is required", Chinese: "为必填项"},
+	FieldInvalid:  {English: "is invalid", Chinese: "无效"},
+	FieldEmail:    {English: "must be a valid email address", Chinese: "必须是有效的邮箱地址"},
+	FieldMin:      {English: "must be at least {param}", Chinese: "不能小于 {param}"},
+	FieldMax:      {English: "must be at most {param}", Chinese: "不能大于 {param}"},
+	FieldOneOf:    {English: "must be one of {param}", Chinese: "必须是以下之一：{param}"},
+}
+
+// message returns the translation of code, or fallback.
+func message(code, lang, fallback string) string {
+	if translated, ok := messages[code][lang]; ok {
+		return translated
+	}
+	if lang != English {
+		if translated, ok := messages[code][English]; ok {
+			return translated
+		}
+	}
+	return fallback
+}
+
+func fieldMessage(field FieldError, lang string) string {
+	text, ok := fieldMessages[field.Code][lang]
+	if !ok {
+		text = fieldMessages[FieldInvalid][lang]
+	}
+	return strings.ReplaceAll(text, "{param}", field.Param)
+}
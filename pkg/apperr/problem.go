@@ -0,0 +1,114 @@
+package apperr
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ContentType is the media type of problem details.
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Code and Errors are
+// extensions; clients should switch on Code rather than on the text.
+type Problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail,omitempty"`
+	Instance  string         `json:"instance,omitempty"`
+	Code      string         `json:"code"`
+	RequestID string         `json:"request_id,omitempty"`
+	Errors    []FieldProblem `json:"errors,omitempty"`
+}
+
+// FieldProblem is one invalid field of a validation problem.
+type FieldProblem struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// From returns err as an *Error. A bare sql.ErrNoRows becomes a generic
+// not found error; anything else that is not an *Error becomes an internal
+// error wrapping err.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFound("").Wrap(err)
+	}
+	return New(KindInternal, CodeInternal, "Internal error").Wrap(err)
+}
+
+// FromBinding turns an error from binding a request body into a
+// validation error naming the failing fields, or an invalid_body error when
+// the body could not be decoded at all.
+func FromBinding(err error) *Error {
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		fields := make([]FieldError, 0, len(invalid))
+		for _, fieldErr := range invalid {
+			fields = append(fields, FieldError{
+				Field: fieldName(fieldErr),
+				Code:  fieldErr.Tag(),
+				Param: fieldErr.Param(),
+			})
+		}
+		return Validation(fields...).Wrap(err)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return Invalid(typeErr.Field).Wrap(err)
+	}
+
+	if errors.Is(err, io.EOF) {
+		return BadRequest(CodeEmptyBody, "Request body is empty").Wrap(err)
+	}
+	return BadRequest(CodeInvalidBody, "Request body is not valid JSON").Wrap(err)
+}
+
+// fieldName is the field's name as the client sent it: the json tag name
+// when the validator was set up to report those, otherwise the struct field
+// name.
+func fieldName(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fieldErr.Field()
+}
+
+func (e *Error) detail(lang string) string {
+	detail := message(e.Code, lang, e.Message)
+	for name, value := range e.Params {
+		detail = strings.ReplaceAll(detail, "{"+name+"}", value)
+	}
+	return detail
+}
+
+// Problem renders e in lang.
+func (e *Error) Problem(lang string) Problem {
+	problem := Problem{
+		Type:   "urn:smg:problem:" + e.Code,
+		Title:  titles[e.Kind][lang],
+		Status: e.Status(),
+		Detail: e.detail(lang),
+		Code:   e.Code,
+	}
+	for _, field := range e.Fields {
+		problem.Errors = append(problem.Errors, FieldProblem{
+			Field:   field.Field,
+			Code:    field.Code,
+			Message: fieldMessage(field, lang),
+		})
+	}
+	return problem
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[redacted]"
+
+// setting is one leaf of Config.
+type setting struct {
+	key    string // file key, such as database.url
+	env    string
+	secret string // "true" for secrets, "url" for URLs with a password
+	value  reflect.Value
+}
+
+// settings lists every leaf of cfg in declaration order.
+func (c *Config) settings() []setting {
+	return collect(reflect.ValueOf(c).Elem(), "")
+}
+
+func collect(v reflect.Value, prefix string) []setting {
+	var settings []setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := prefix + field.Tag.Get("config")
+		if field.Type.Kind() == reflect.Struct {
+			settings = append(settings, collect(v.Field(i), key+".")...)
+			continue
+		}
+		settings = append(settings, setting{
+			key:    key,
+			env:    field.Tag.Get("env"),
+			secret: field.Tag.Get("secret"),
+			value:  v.Field(i),
+		})
+	}
+	return settings
+}
+
+// loadFile applies a YAML or TOML file. Unknown keys are errors, so a typo
+// does not silently leave a default in place.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	flat := map[string]interface{}{}
+	flatten(values, "", flat)
+
+	byKey := map[string]setting{}
+	for _, s := range c.settings() {
+		byKey[s.key] = s
+	}
+
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("config file %s: unknown setting %q", path, key)
+		}
+		if err := s.setFile(flat[key]); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// loadEnv applies environment variables. Empty variables are ignored.
+func (c *Config) loadEnv() error {
+	for _, s := range c.settings() {
+		if s.env == "" {
+			continue
+		}
+		value := os.Getenv(s.env)
+		if value == "" {
+			continue
+		}
+		if err := s.set(value); err != nil {
+			return fmt.Errorf("%s: %w", s.env, err)
+		}
+	}
+	return nil
+}
+
+func flatten(values map[string]interface{}, prefix string, flat map[string]interface{}) {
+	for key, value := range values {
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(nested, prefix+key+".", flat)
+			continue
+		}
+		flat[prefix+key] = value
+	}
+}
+
+// setFile sets the setting from a decoded file value. Lists may be written
+// as a list or as a comma-separated string.
+func (s setting) setFile(raw interface{}) error {
+	if list, ok := raw.([]interface{}); ok {
+		if s.value.Kind() != reflect.Slice {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+		values := make([]string, 0, len(list))
+		for _, item := range list {
+			values = append(values, fmt.Sprint(item))
+		}
+		s.value.Set(reflect.ValueOf(values))
+		return nil
+	}
+	return s.set(fmt.Sprint(raw))
+}
+
+// set parses value into the setting's type.
+func (s setting) set(value string) error {
+	switch s.value.Interface().(type) {
+	case string:
+		s.value.SetString(value)
+	case int:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		s.value.SetInt(int64(n))
+	case float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		s.value.SetFloat(f)
+	case bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		s.value.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, use a value such as 30s or 5m", value)
+		}
+		s.value.SetInt(int64(d))
+	case []string:
+		var values []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
+
+// Redacted returns the effective settings as nested maps keyed like the
+// config file, with secrets replaced and passwords removed from URLs.
+func (c *Config) Redacted() map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, s := range c.settings() {
+		var value interface{} = s.value.Interface()
+		switch s.secret {
+		case "true":
+			if s.value.String() != "" {
+				value = redacted
+			}
+		case "url":
+			value = redactURL(s.value.String())
+		}
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+
+		parts := strings.Split(s.key, ".")
+		section := out
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := section[part].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				section[part] = next
+			}
+			section = next
+		}
+		section[parts[len(parts)-1]] = value
+	}
+	return out
+}
+
+// Print writes the effective settings to w as YAML, with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// redactURL hides the password in a URL. A value that does not parse is
+// hidden entirely, as it may still hold one.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	return u.Redacted()
+}
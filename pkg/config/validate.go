@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// minJWTSecretLength is the shortest HS256 secret accepted in production.
+const minJWTSecretLength = 32
+
+// Validate checks every setting and reports all problems at once. In
+// production it also refuses default and placeholder secrets.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	switch c.Environment {
+	case EnvDevelopment, EnvTest, EnvStaging, EnvProduction:
+	default:
+		problems = append(problems, fmt.Sprintf("environment: unknown environment %q", c.Environment))
+	}
+
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("logging.level: unknown level %q", c.Logging.Level))
+	}
+	check(c.Logging.Format == "json" || c.Logging.Format == "text",
+		"logging.format: must be json or text, not %q", c.Logging.Format)
+
+	switch c.Tracing.Exporter {
+	case "otlp", "stdout", "none":
+	default:
+		problems = append(problems, fmt.Sprintf("tracing.exporter: must be otlp, stdout or none, not %q", c.Tracing.Exporter))
+	}
+	check(c.Tracing.Exporter != "otlp" || c.Tracing.Endpoint != "", "tracing.endpoint: required with the otlp exporter")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio: must be between 0 and 1")
+
+	check(validPort(c.Server.Port), "server.port: invalid port %q", c.Server.Port)
+	check(validURL(c.Server.AppURL), "server.app_url: invalid URL %q", c.Server.AppURL)
+	for _, origin := range c.Server.CORSOrigins {
+		check(origin == "*" || validURL(origin), "server.cors_origins: invalid origin %q", origin)
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		check(validIPOrCIDR(proxy), "server.trusted_proxies: invalid IP or CIDR %q", proxy)
+	}
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout: must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout: must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout: must not be negative")
+
+	check(c.Database.URL != "", "database.url: required")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns: must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns: must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns: must not exceed max_open_conns")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime: must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time: must not be negative")
+
+	check(c.Redis.Addr != "", "redis.addr: required")
+	check(c.Redis.DB >= 0, "redis.db: must not be negative")
+	check(c.Redis.PoolSize >= 0, "redis.pool_size: must not be negative")
+	check(c.Redis.DialTimeout >= 0, "redis.dial_timeout: must not be negative")
+
+	check(c.SMTP.Port > 0 && c.SMTP.Port <= 65535, "smtp.port: invalid port %d", c.SMTP.Port)
+
+	check(c.AI.Timeout > 0, "ai.timeout: must be positive")
+	check(c.AI.BatchSize > 0, "ai.batch_size: must be positive")
+	check(c.Publisher.Timeout > 0, "publisher.timeout: must be positive")
+	check(c.Publisher.BatchSize > 0, "publisher.batch_size: must be positive")
+
+	if c.Scheduler.AdminAddr != "" {
+		_, port, err := net.SplitHostPort(c.Scheduler.AdminAddr)
+		check(err == nil && validPort(port), "scheduler.admin_addr: invalid address %q, use host:port or :port", c.Scheduler.AdminAddr)
+	}
+
+	check(c.Health.Timeout > 0, "health.timeout: must be positive")
+
+	if c.IsProduction() {
+		problems = append(problems, c.productionProblems()...)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// productionProblems refuses the defaults and .env.example placeholders
+// that would let anyone forge tokens or reach the database.
+func (c *Config) productionProblems() []string {
+	var problems []string
+
+	if c.JWT.PrivateKey == "" && c.JWT.PrivateKeyFile == "" {
+		switch {
+		case placeholder(c.JWT.Secret):
+			problems = append(problems, "jwt.secret: the default secret cannot be used in production")
+		case len(c.JWT.Secret) < minJWTSecretLength:
+			problems = append(problems, fmt.Sprintf("jwt.secret: must be at least %d characters in production", minJWTSecretLength))
+		}
+	}
+
+	if u, err := url.Parse(c.Database.URL); err == nil && u.User != nil {
+		if password, _ := u.User.Password(); password == "postgres" {
+			problems = append(problems, "database.url: the default database password cannot be used in production")
+		}
+	}
+
+	for _, s := range c.settings() {
+		// The JWT secret is checked above, and is unused with a key.
+		if s.key == "jwt.secret" {
+			continue
+		}
+		if s.secret == "true" && placeholder(s.value.String()) {
+			problems = append(problems, fmt.Sprintf("%s: placeholder value cannot be used in production", s.key))
+		}
+	}
+
+	return problems
+}
+
+// placeholder reports whether value is an example value such as the ones
+// in .env.example.
+func placeholder(value string) bool {
+	return strings.HasPrefix(value, "your-") || strings.Contains(value, "change-in-production")
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func validIPOrCIDR(raw string) bool {
+	if _, _, err := net.ParseCIDR(raw); err == nil {
+		return true
+	}
+	return net.ParseIP(raw) != nil
+}
+
+func validURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
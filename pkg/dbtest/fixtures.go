@@ -0,0 +1,44 @@
+package dbtest
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"smg/pkg/migrations"
+)
+
+// Fixtures are the rows inserted by Seed, from
+// pkg/migrations/seeds/test.sql.
+type Fixtures struct {
+	// AdminID is a user with the admin role and a personal workspace.
+	AdminID string
+	// EditorID is an editor who owns WorkspaceID.
+	EditorID       string
+	WorkspaceID    string
+	TopicID        string
+	MediaAccountID string
+	ArticleID      string
+	// Now is the creation time of every seeded row.
+	Now time.Time
+}
+
+// Seed inserts the test environment's seed data into db: an admin, and an
+// editor whose workspace holds one topic, media account and article.
+func Seed(t testing.TB, db *sql.DB) *Fixtures {
+	t.Helper()
+
+	if err := migrations.Seed(db, "test"); err != nil {
+		t.Fatal("dbtest:", err)
+	}
+
+	return &Fixtures{
+		AdminID:        "user_admin",
+		EditorID:       "user_editor",
+		WorkspaceID:    "ws_user_editor",
+		TopicID:        "topic_golang",
+		MediaAccountID: "media_twitter",
+		ArticleID:      "article_release",
+		Now:            time.Date(2026, time.January, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
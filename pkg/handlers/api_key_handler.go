@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/services"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	keys, err := h.apiKeyService.GetAPIKeys(user.(*models.User))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey returns the new key. It is the only time the key is shown.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	key, err := h.apiKeyService.CreateAPIKey(user.(*models.User), &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	keyID := c.Param("id")
+	if keyID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(user.(*models.User), keyID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/services"
+)
+
+type ApprovalHandler struct {
+	approvalService *services.ApprovalService
+}
+
+func NewApprovalHandler(approvalService *services.ApprovalService) *ApprovalHandler {
+	return &ApprovalHandler{approvalService: approvalService}
+}
+
+func (h *ApprovalHandler) GetPolicies(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	userModel := user.(*models.User)
+	policies, err := h.approvalService.GetPolicies(userModel.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, policies)
+}
+
+func (h *ApprovalHandler) CreatePolicy(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	var req models.ApprovalPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userModel := user.(*models.User)
+	policy, err := h.approvalService.CreatePolicy(userModel, &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, policy)
+}
+
+func (h *ApprovalHandler) UpdatePolicy(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	policyID := c.Param("id")
+	if policyID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	var req models.ApprovalPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userModel := user.(*models.User)
+	policy, err := h.approvalService.UpdatePolicy(userModel, policyID, &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (h *ApprovalHandler) DeletePolicy(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	policyID := c.Param("id")
+	if policyID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	userModel := user.(*models.User)
+	err := h.approvalService.DeletePolicy(userModel, policyID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Policy deleted successfully"})
+}
+
+func (h *ApprovalHandler) GetPendingApprovals(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	userModel := user.(*models.User)
+	reposts, err := h.approvalService.GetPendingApprovals(userModel)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reposts)
+}
+
+func (h *ApprovalHandler) ApproveRepost(c *gin.Context) {
+	h.reviewRepost(c, h.approvalService.ApproveRepost)
+}
+
+func (h *ApprovalHandler) RejectRepost(c *gin.Context) {
+	h.reviewRepost(c, h.approvalService.RejectRepost)
+}
+
+func (h *ApprovalHandler) GetRepostApprovals(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	repostID := c.Param("id")
+	if repostID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	userModel := user.(*models.User)
+	approvals, err := h.approvalService.GetRepostApprovals(userModel, repostID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, approvals)
+}
+
+func (h *ApprovalHandler) reviewRepost(c *gin.Context, review func(context.Context, string, *models.User, *string) (*models.Repost, error)) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	repostID := c.Param("id")
+	if repostID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	// The comment is optional, so an empty body is accepted.
+	var req models.ReviewRepostRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(apperr.FromBinding(err))
+			return
+		}
+	}
+
+	userModel := user.(*models.User)
+	repost, err := review(c.Request.Context(), repostID, userModel, req.Comment)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, repost)
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"smg/pkg/apperr"
 	"smg/pkg/models"
 	"smg/pkg/services"
 )
@@ -20,7 +21,7 @@ func NewArticleHandler(articleService *services.ArticleService) *ArticleHandler
 func (h *ArticleHandler) GetArticles(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
@@ -34,10 +35,16 @@ func (h *ArticleHandler) GetArticles(c *gin.Context) {
 		pageSize = 20
 	}
 
+	state, ok := articleStateQuery(c)
+	if !ok {
+		c.Error(apperr.Invalid("state"))
+		return
+	}
+
 	userModel := user.(*models.User)
-	articles, err := h.articleService.GetArticles(userModel.ID, page, pageSize)
+	articles, err := h.articleService.GetArticles(userModel, state, page, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -47,20 +54,20 @@ func (h *ArticleHandler) GetArticles(c *gin.Context) {
 func (h *ArticleHandler) CreateArticle(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
 	var article models.Article
 	if err := c.ShouldBindJSON(&article); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	userModel := user.(*models.User)
-	createdArticle, err := h.articleService.CreateArticle(userModel.ID, &article)
+	createdArticle, err := h.articleService.CreateArticle(userModel, &article)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -68,15 +75,22 @@ func (h *ArticleHandler) CreateArticle(c *gin.Context) {
 }
 
 func (h *ArticleHandler) GetArticle(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	articleID := c.Param("id")
 	if articleID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Article ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
-	article, err := h.articleService.GetArticle(articleID)
+	userModel := user.(*models.User)
+	article, err := h.articleService.GetArticle(userModel, articleID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		c.Error(err)
 		return
 	}
 
@@ -84,21 +98,28 @@ func (h *ArticleHandler) GetArticle(c *gin.Context) {
 }
 
 func (h *ArticleHandler) UpdateArticle(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	articleID := c.Param("id")
 	if articleID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Article ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
 	var article models.Article
 	if err := c.ShouldBindJSON(&article); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	updatedArticle, err := h.articleService.UpdateArticle(articleID, &article)
+	userModel := user.(*models.User)
+	updatedArticle, err := h.articleService.UpdateArticle(userModel, articleID, &article)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -106,44 +127,74 @@ func (h *ArticleHandler) UpdateArticle(c *gin.Context) {
 }
 
 func (h *ArticleHandler) DeleteArticle(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	articleID := c.Param("id")
 	if articleID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Article ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
-	err := h.articleService.DeleteArticle(articleID)
+	userModel := user.(*models.User)
+	err := h.articleService.DeleteArticle(userModel, articleID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Article deleted successfully"})
 }
 
+func (h *ArticleHandler) BulkUpdateArticles(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	var req models.BulkArticleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userModel := user.(*models.User)
+	updated, err := h.articleService.BulkUpdateState(userModel, req.IDs, models.ArticleStates[req.Action])
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
 func (h *ArticleHandler) RepostArticle(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
 	articleID := c.Param("id")
 	if articleID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Article ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
 	var req models.RepostRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	userModel := user.(*models.User)
-	repost, err := h.articleService.RepostArticle(articleID, userModel.ID, &req)
+	repost, err := h.articleService.RepostArticle(c.Request.Context(), userModel, articleID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -153,7 +204,7 @@ func (h *ArticleHandler) RepostArticle(c *gin.Context) {
 func (h *ArticleHandler) GetReposts(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
@@ -168,11 +219,27 @@ func (h *ArticleHandler) GetReposts(c *gin.Context) {
 	}
 
 	userModel := user.(*models.User)
-	reposts, err := h.articleService.GetReposts(userModel.ID, page, pageSize)
+	reposts, err := h.articleService.GetReposts(userModel, page, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, reposts)
-}
\ No newline at end of file
+}
+// articleStateQuery reads the optional "state" filter and reports whether it
+// names a known article state.
+func articleStateQuery(c *gin.Context) (string, bool) {
+	state := c.Query("state")
+	if state == "" {
+		return "", true
+	}
+
+	for _, known := range models.ArticleStates {
+		if state == known {
+			return state, true
+		}
+	}
+
+	return "", false
+}
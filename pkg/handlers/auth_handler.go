@@ -1,31 +1,78 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"smg/pkg/apperr"
+	"smg/pkg/logging"
 	"smg/pkg/models"
+	"smg/pkg/oidc"
 	"smg/pkg/services"
 )
 
+var (
+	errEmailAlreadyVerified = apperr.Conflict("email_already_verified", "Email is already verified")
+	errEmailDelivery        = apperr.BadGateway("email_delivery_failed", "The email could not be sent")
+	errGoogleLogin          = apperr.Unauthorized("google_login_failed", "Google login failed")
+	errGoogleUnavailable    = apperr.BadGateway("google_unavailable", "Google could not be reached")
+)
+
 type AuthHandler struct {
-	authService *services.AuthService
+	authService         *services.AuthService
+	sessionService      *services.SessionService
+	oauthService        *services.OAuthService
+	verificationService *services.VerificationService
 }
 
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
-	return &AuthHandler{authService: authService}
+func NewAuthHandler(
+	authService *services.AuthService,
+	sessionService *services.SessionService,
+	oauthService *services.OAuthService,
+	verificationService *services.VerificationService,
+) *AuthHandler {
+	return &AuthHandler{
+		authService:         authService,
+		sessionService:      sessionService,
+		oauthService:        oauthService,
+		verificationService: verificationService,
+	}
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	response, challenge, err := h.authService.Login(req.Email, req.Password, clientInfo(c, req.DeviceName))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// VerifyMFA completes a login that was answered with an MFA challenge.
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	response, err := h.authService.Login(req.Email, req.Password)
+	response, err := h.authService.VerifyMFA(req.MFAToken, req.Code)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -35,16 +82,22 @@ func (h *AuthHandler) Login(c *gin.Context) {
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	response, err := h.authService.Register(req.Name, req.Email, req.Password)
+	response, err := h.authService.Register(req.Name, req.Email, req.Password, clientInfo(c, req.DeviceName))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
+	// The account is usable right away; the user can ask for another
+	// verification email if this one is lost.
+	if err := h.verificationService.SendVerification(&response.User, c.GetHeader("Accept-Language")); err != nil {
+		logging.FromContext(c.Request.Context()).Error("verification email failed", "user_id", response.User.ID, "error", err)
+	}
+
 	c.JSON(http.StatusCreated, response)
 }
 
@@ -54,60 +107,227 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 	
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	user, err := h.authService.ValidateToken(req.RefreshToken)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
-		return
+	response, err := h.authService.RefreshToken(req.RefreshToken)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = services.ErrInvalidToken.Wrap(err)
 	}
-
-	response, err := h.authService.Login(user.Email, "")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// Logout revokes the token family of the refresh token in the body or, if
+// none is given, of the bearer access token.
 func (h *AuthHandler) Logout(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// The body is optional.
+	_ = c.ShouldBindJSON(&req)
+
+	token := req.RefreshToken
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		c.Error(apperr.Required("refresh_token"))
+		return
+	}
+
+	if err := h.authService.Logout(token); err != nil {
+		c.Error(err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
 }
 
-func (h *AuthHandler) GenerateQRCode(c *gin.Context) {
-	var req models.QRCodeRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// GoogleLogin redirects the browser to Google's consent screen.
+func (h *AuthHandler) GoogleLogin(c *gin.Context) {
+	authURL, err := h.oauthService.StartGoogleLogin()
+	if err != nil {
+		if !errors.Is(err, services.ErrOAuthNotConfigured) {
+			err = errGoogleUnavailable.Wrap(err)
+		}
+		c.Error(err)
 		return
 	}
 
-	response, err := h.authService.GenerateQRCode(req.UserID)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// GoogleCallback completes the Google login and returns the same response
+// as a password login.
+func (h *AuthHandler) GoogleCallback(c *gin.Context) {
+	if errorCode := c.Query("error"); errorCode != "" {
+		c.Error(errGoogleLogin.Wrap(errors.New(errorCode)))
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		c.Error(apperr.Required("code", "state"))
+		return
+	}
+
+	response, challenge, err := h.oauthService.CompleteGoogleLogin(code, state, clientInfo(c, ""))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// Errors the service already explains pass through; anything else
+		// is a bad ID token or Google failing to answer.
+		var appErr *apperr.Error
+		switch {
+		case errors.Is(err, oidc.ErrInvalidIDToken), errors.Is(err, oidc.ErrNonceMismatch):
+			err = errGoogleLogin.Wrap(err)
+		case !errors.As(err, &appErr):
+			err = errGoogleUnavailable.Wrap(err)
+		}
+		c.Error(err)
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *AuthHandler) VerifyQRCode(c *gin.Context) {
-	var req struct {
-		Token string `json:"token" binding:"required"`
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
 	}
-	
+
+	if err := h.verificationService.VerifyEmail(req.Token); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	userModel := user.(*models.User)
+	if userModel.EmailVerified != nil {
+		c.Error(errEmailAlreadyVerified)
+		return
+	}
+
+	if err := h.verificationService.SendVerification(userModel, c.GetHeader("Accept-Language")); err != nil {
+		c.Error(errEmailDelivery.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	response, err := h.authService.VerifyQRCode(req.Token)
+	locale := req.Locale
+	if locale == "" {
+		locale = c.GetHeader("Accept-Language")
+	}
+
+	if err := h.verificationService.RequestPasswordReset(req.Email, locale); err != nil {
+		c.Error(errEmailDelivery.Wrap(err))
+		return
+	}
+
+	// Same response whether or not the address exists.
+	c.JSON(http.StatusOK, gin.H{"message": "If the address is registered, a reset link has been sent"})
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	if err := h.verificationService.ResetPassword(req.Token, req.Password); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// JWKS publishes the public token verification keys.
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authService.JWKS())
+}
+
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	userModel := user.(*models.User)
+	sessions, err := h.sessionService.GetSessions(userModel)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	userModel := user.(*models.User)
+	if err := h.sessionService.RevokeSession(userModel, sessionID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// clientInfo describes the requesting device. deviceName comes from the
+// request body and falls back to the X-Device-Name header.
+func clientInfo(c *gin.Context, deviceName string) *models.ClientInfo {
+	if deviceName == "" {
+		deviceName = c.GetHeader("X-Device-Name")
+	}
+
+	return &models.ClientInfo{
+		DeviceName: deviceName,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+		RequestID:  logging.RequestID(c.Request.Context()),
+	}
 }
\ No newline at end of file
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"smg/pkg/apperr"
+	"smg/pkg/dbtest"
+	"smg/pkg/mailer"
+	"smg/pkg/mailer/smtptest"
+	"smg/pkg/middleware"
+	"smg/pkg/models"
+	"smg/pkg/redistest"
+	"smg/pkg/services"
+)
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
+
+// verificationRouter serves the email verification and password reset
+// routes on the seeded test database, mailing to the returned sink. The
+// seeded editor is signed in for the resend route.
+func verificationRouter(t *testing.T) (*gin.Engine, *services.SessionService, *smtptest.Sink) {
+	t.Helper()
+	db := dbtest.DB(t)
+	dbtest.Seed(t, db)
+
+	sink, err := smtptest.NewSink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sink.Close() })
+
+	sessions := services.NewSessionService(db, redistest.New(t))
+	m := mailer.New(mailer.Config{Host: sink.Host, Port: sink.Port, From: "noreply@example.com"})
+	handler := NewAuthHandler(nil, sessions, nil, services.NewVerificationService(db, m, sessions, "https://app.example.com"))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
+	router.POST("/auth/verify-email", handler.VerifyEmail)
+	router.POST("/auth/verify-email/resend", func(c *gin.Context) {
+		c.Set("user", &models.User{ID: "user_editor", Email: "editor@example.com"})
+	}, handler.ResendVerification)
+	router.POST("/auth/password/forgot", handler.ForgotPassword)
+	router.POST("/auth/password/reset", handler.ResetPassword)
+	return router, sessions, sink
+}
+
+var mailedToken = regexp.MustCompile(`\?token=(\S+)`)
+
+func tokenFrom(t *testing.T, sink *smtptest.Sink) string {
+	t.Helper()
+	msg, err := sink.WaitForMessage(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := mailedToken.FindStringSubmatch(msg.Body)
+	if match == nil {
+		t.Fatalf("no token link in email:\n%s", msg.Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func jsonString(t *testing.T, value string) string {
+	t.Helper()
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(encoded)
+}
+
+func expectProblem(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	var problem apperr.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != status || problem.Code != code {
+		t.Fatalf("got status %d, problem %+v; want %d %s", w.Code, problem, status, code)
+	}
+}
+
+func TestForgotPasswordHandler(t *testing.T) {
+	router, _, sink := verificationRouter(t)
+
+	unknown := serve(router, http.MethodPost, "/auth/password/forgot", `{"email":"nobody@example.com"}`)
+	if unknown.Code != http.StatusOK {
+		t.Fatalf("unknown address: got status %d: %s", unknown.Code, unknown.Body)
+	}
+	if messages := sink.Messages(); len(messages) != 0 {
+		t.Fatalf("unknown address was sent %d email(s)", len(messages))
+	}
+
+	known := serve(router, http.MethodPost, "/auth/password/forgot", `{"email":"editor@example.com","locale":"zh"}`)
+	if known.Code != unknown.Code || known.Body.String() != unknown.Body.String() {
+		t.Fatalf("known address: got %d %s, unknown got %d %s", known.Code, known.Body, unknown.Code, unknown.Body)
+	}
+	msg, err := sink.WaitForMessage(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.To[0] != "editor@example.com" || msg.Subject != "重設您的密碼" {
+		t.Fatalf("reset email: got to %v, subject %q", msg.To, msg.Subject)
+	}
+
+	expectProblem(t, serve(router, http.MethodPost, "/auth/password/forgot", `{"email":"not an address"}`),
+		http.StatusBadRequest, apperr.CodeValidation)
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	router, sessions, sink := verificationRouter(t)
+	if err := sessions.CreateSession("user_editor", "family-laptop", services.LoginMethodPassword, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serve(router, http.MethodPost, "/auth/password/forgot", `{"email":"editor@example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("forgot: got status %d: %s", w.Code, w.Body)
+	}
+	body := `{"token":` + jsonString(t, tokenFrom(t, sink)) + `,"password":"correct horse"}`
+
+	expectProblem(t, serve(router, http.MethodPost, "/auth/password/reset", `{"token":"x","password":"short"}`),
+		http.StatusBadRequest, apperr.CodeValidation)
+
+	if w := serve(router, http.MethodPost, "/auth/password/reset", body); w.Code != http.StatusOK {
+		t.Fatalf("reset: got status %d: %s", w.Code, w.Body)
+	}
+	if revoked, err := sessions.IsRevoked("family-laptop"); err != nil || !revoked {
+		t.Fatalf("session family after reset: revoked %v, %v", revoked, err)
+	}
+
+	expectProblem(t, serve(router, http.MethodPost, "/auth/password/reset", body),
+		http.StatusBadRequest, "verification_token_invalid")
+}
+
+func TestVerifyEmailHandler(t *testing.T) {
+	router, _, sink := verificationRouter(t)
+
+	if w := serve(router, http.MethodPost, "/auth/verify-email/resend", ""); w.Code != http.StatusOK {
+		t.Fatalf("resend: got status %d: %s", w.Code, w.Body)
+	}
+	body := `{"token":` + jsonString(t, tokenFrom(t, sink)) + `}`
+
+	expectProblem(t, serve(router, http.MethodPost, "/auth/verify-email", `{"token":"unknown"}`),
+		http.StatusBadRequest, "verification_token_invalid")
+	if w := serve(router, http.MethodPost, "/auth/verify-email", body); w.Code != http.StatusOK {
+		t.Fatalf("verify: got status %d: %s", w.Code, w.Body)
+	}
+	expectProblem(t, serve(router, http.MethodPost, "/auth/verify-email", body),
+		http.StatusBadRequest, "verification_token_invalid")
+}
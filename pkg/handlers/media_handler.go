@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"smg/pkg/apperr"
 	"smg/pkg/models"
 	"smg/pkg/services"
 )
@@ -19,14 +20,14 @@ func NewMediaHandler(mediaService *services.MediaService) *MediaHandler {
 func (h *MediaHandler) GetAccounts(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
 	userModel := user.(*models.User)
-	accounts, err := h.mediaService.GetAccounts(userModel.ID)
+	accounts, err := h.mediaService.GetAccounts(userModel)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -36,20 +37,20 @@ func (h *MediaHandler) GetAccounts(c *gin.Context) {
 func (h *MediaHandler) CreateAccount(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
 	var req models.ConnectPlatformRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	userModel := user.(*models.User)
-	account, err := h.mediaService.CreateAccount(userModel.ID, &req)
+	account, err := h.mediaService.CreateAccount(userModel, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -57,15 +58,22 @@ func (h *MediaHandler) CreateAccount(c *gin.Context) {
 }
 
 func (h *MediaHandler) GetAccount(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	accountID := c.Param("id")
 	if accountID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Account ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
-	account, err := h.mediaService.GetAccount(accountID)
+	userModel := user.(*models.User)
+	account, err := h.mediaService.GetAccount(userModel, accountID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		c.Error(err)
 		return
 	}
 
@@ -73,21 +81,28 @@ func (h *MediaHandler) GetAccount(c *gin.Context) {
 }
 
 func (h *MediaHandler) UpdateAccount(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	accountID := c.Param("id")
 	if accountID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Account ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
 	var req models.ConnectPlatformRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	account, err := h.mediaService.UpdateAccount(accountID, &req)
+	userModel := user.(*models.User)
+	account, err := h.mediaService.UpdateAccount(userModel, accountID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -95,15 +110,22 @@ func (h *MediaHandler) UpdateAccount(c *gin.Context) {
 }
 
 func (h *MediaHandler) DeleteAccount(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	accountID := c.Param("id")
 	if accountID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Account ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
-	err := h.mediaService.DeleteAccount(accountID)
+	userModel := user.(*models.User)
+	err := h.mediaService.DeleteAccount(userModel, accountID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -113,26 +135,26 @@ func (h *MediaHandler) DeleteAccount(c *gin.Context) {
 func (h *MediaHandler) ConnectPlatform(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
 	platform := c.Param("platform")
 	if platform == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Platform is required"})
+		c.Error(apperr.Required("platform"))
 		return
 	}
 
 	var req models.ConnectPlatformRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	userModel := user.(*models.User)
-	account, err := h.mediaService.ConnectPlatform(userModel.ID, platform, &req)
+	account, err := h.mediaService.ConnectPlatform(userModel, platform, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -140,15 +162,22 @@ func (h *MediaHandler) ConnectPlatform(c *gin.Context) {
 }
 
 func (h *MediaHandler) DisconnectAccount(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	accountID := c.Param("id")
 	if accountID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Account ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
-	err := h.mediaService.DisconnectAccount(accountID)
+	userModel := user.(*models.User)
+	err := h.mediaService.DisconnectAccount(userModel, accountID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"smg/pkg/apperr"
+	"smg/pkg/logging"
+	"smg/pkg/models"
+	"smg/pkg/services"
+)
+
+// qrLoginWait bounds how long a websocket waits; QR sessions expire well
+// before it.
+const qrLoginWait = time.Minute * 3
+
+var qrLoginUpgrader = websocket.Upgrader{
+	// The poll token authenticates the connection, and the API is served
+	// to any origin.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+type QRLoginHandler struct {
+	qrLoginService *services.QRLoginService
+}
+
+func NewQRLoginHandler(qrLoginService *services.QRLoginService) *QRLoginHandler {
+	return &QRLoginHandler{qrLoginService: qrLoginService}
+}
+
+// CreateSession starts a QR login for the device making the request.
+func (h *QRLoginHandler) CreateSession(c *gin.Context) {
+	var req models.QRLoginRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(apperr.FromBinding(err))
+			return
+		}
+	}
+
+	session, err := h.qrLoginService.Create(clientInfo(c, req.DeviceName))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+// Poll returns the session status to the requesting device, with tokens
+// once the login has been confirmed.
+func (h *QRLoginHandler) Poll(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	var req models.QRLoginPollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	status, err := h.qrLoginService.Poll(id, req.PollToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Watch streams status changes to the requesting device over a websocket,
+// as an alternative to polling. The poll token is passed as a query
+// parameter because browsers cannot set headers on websocket requests.
+// Every message is a QRLoginStatus; the connection is closed once the
+// login is completed, rejected or expired.
+func (h *QRLoginHandler) Watch(c *gin.Context) {
+	id := c.Param("id")
+	pollToken := c.Query("poll_token")
+	if id == "" || pollToken == "" {
+		c.Error(apperr.Required("id", "poll_token"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), qrLoginWait)
+	defer cancel()
+
+	// Subscribe before reading the status so no change is missed.
+	pubsub, err := h.qrLoginService.Subscribe(ctx, id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer pubsub.Close()
+
+	status, err := h.qrLoginService.Poll(id, pollToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	conn, err := qrLoginUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written the error response.
+		return
+	}
+	defer conn.Close()
+
+	// Nothing is expected from the client; reading detects when it goes
+	// away.
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	events := pubsub.Channel()
+	for {
+		if err := conn.WriteJSON(status); err != nil {
+			return
+		}
+		if services.QRLoginDone(status.Status) {
+			closeWebsocket(conn, "")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			closeWebsocket(conn, "QR login expired")
+			return
+		case <-events:
+		}
+
+		status, err = h.qrLoginService.Poll(id, pollToken)
+		if errors.Is(err, services.ErrQRLoginNotFound) {
+			closeWebsocket(conn, "QR login expired")
+			return
+		}
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("QR login poll failed", "qr_login_id", id, "error", err)
+			closeWebsocket(conn, "QR login failed")
+			return
+		}
+	}
+}
+
+// Scan is called by the signed-in device after scanning the QR code. It
+// returns the requesting device's details for the user to check.
+func (h *QRLoginHandler) Scan(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	id := c.Param("id")
+	if id == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	details, err := h.qrLoginService.Scan(user.(*models.User), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
+
+func (h *QRLoginHandler) Confirm(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	id := c.Param("id")
+	if id == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	if err := h.qrLoginService.Confirm(user.(*models.User), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "QR login confirmed"})
+}
+
+func (h *QRLoginHandler) Reject(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	id := c.Param("id")
+	if id == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	if err := h.qrLoginService.Reject(user.(*models.User), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "QR login rejected"})
+}
+
+func closeWebsocket(conn *websocket.Conn, reason string) {
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason)
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+}
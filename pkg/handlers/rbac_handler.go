@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/services"
+)
+
+type RBACHandler struct {
+	rbacService *services.RBACService
+}
+
+func NewRBACHandler(rbacService *services.RBACService) *RBACHandler {
+	return &RBACHandler{rbacService: rbacService}
+}
+
+func (h *RBACHandler) GetRoles(c *gin.Context) {
+	c.JSON(http.StatusOK, h.rbacService.GetRoles())
+}
+
+func (h *RBACHandler) GetMyPermissions(c *gin.Context) {
+	permissions, exists := c.Get("permissions")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+func (h *RBACHandler) SetUserRole(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	userID := c.Param("id")
+	if userID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	var req models.SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userModel := user.(*models.User)
+	updatedUser, err := h.rbacService.SetRole(userModel, userID, req.Role)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updatedUser)
+}
@@ -1,25 +1,34 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"smg/pkg/apperr"
+	"smg/pkg/logging"
 	"smg/pkg/models"
 	"smg/pkg/services"
 )
 
 type SystemHandler struct {
 	systemService *services.SystemService
+	auditService  *services.AuditService
 }
 
-func NewSystemHandler(systemService *services.SystemService) *SystemHandler {
-	return &SystemHandler{systemService: systemService}
+func NewSystemHandler(systemService *services.SystemService, auditService *services.AuditService) *SystemHandler {
+	return &SystemHandler{
+		systemService: systemService,
+		auditService:  auditService,
+	}
 }
 
 func (h *SystemHandler) GetSettings(c *gin.Context) {
 	settings, err := h.systemService.GetSettings()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -27,15 +36,21 @@ func (h *SystemHandler) GetSettings(c *gin.Context) {
 }
 
 func (h *SystemHandler) UpdateSettings(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	var settings map[string]string
 	if err := c.ShouldBindJSON(&settings); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	err := h.systemService.UpdateSettings(settings)
+	err := h.systemService.UpdateSettings(user.(*models.User), settings)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -45,7 +60,7 @@ func (h *SystemHandler) UpdateSettings(c *gin.Context) {
 func (h *SystemHandler) GetStats(c *gin.Context) {
 	stats, err := h.systemService.GetStats()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -55,7 +70,7 @@ func (h *SystemHandler) GetStats(c *gin.Context) {
 func (h *SystemHandler) GetPlatforms(c *gin.Context) {
 	platforms, err := h.systemService.GetPlatforms()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -65,13 +80,13 @@ func (h *SystemHandler) GetPlatforms(c *gin.Context) {
 func (h *SystemHandler) CreatePlatform(c *gin.Context) {
 	var platform models.Platform
 	if err := c.ShouldBindJSON(&platform); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	createdPlatform, err := h.systemService.CreatePlatform(&platform)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -81,19 +96,19 @@ func (h *SystemHandler) CreatePlatform(c *gin.Context) {
 func (h *SystemHandler) UpdatePlatform(c *gin.Context) {
 	platformID := c.Param("id")
 	if platformID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Platform ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
 	var platform models.Platform
 	if err := c.ShouldBindJSON(&platform); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	updatedPlatform, err := h.systemService.UpdatePlatform(platformID, &platform)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -103,15 +118,73 @@ func (h *SystemHandler) UpdatePlatform(c *gin.Context) {
 func (h *SystemHandler) DeletePlatform(c *gin.Context) {
 	platformID := c.Param("id")
 	if platformID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Platform ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
 	err := h.systemService.DeletePlatform(platformID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Platform deleted successfully"})
-}
\ No newline at end of file
+}
+
+// GetAuditEvents lists audit events, newest first. Events can be filtered
+// by action, actor_id, resource_type and resource_id, and by time with
+// from and to in RFC 3339.
+func (h *SystemHandler) GetAuditEvents(c *gin.Context) {
+	var filter models.AuditFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+
+	events, err := h.auditService.GetEvents(&filter, page, pageSize)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// ExportAuditEvents downloads the audit events matching the same filters
+// as GetAuditEvents as a CSV file.
+func (h *SystemHandler) ExportAuditEvents(c *gin.Context) {
+	var filter models.AuditFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.csv", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	err := h.auditService.ExportEvents(&filter, c.Writer)
+	if err == nil {
+		return
+	}
+	if !c.Writer.Written() {
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		c.Error(err)
+		return
+	}
+
+	// Rows are streamed, so a failure part way can only cut the file short.
+	logging.FromContext(c.Request.Context()).Error("audit export failed", "error", err)
+	c.Abort()
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"smg/pkg/apperr"
 	"smg/pkg/models"
 	"smg/pkg/services"
 )
@@ -20,7 +21,7 @@ func NewTopicHandler(topicService *services.TopicService) *TopicHandler {
 func (h *TopicHandler) GetTopics(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
@@ -35,9 +36,9 @@ func (h *TopicHandler) GetTopics(c *gin.Context) {
 	}
 
 	userModel := user.(*models.User)
-	topics, err := h.topicService.GetTopics(userModel.ID, page, pageSize)
+	topics, err := h.topicService.GetTopics(userModel, page, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -47,20 +48,20 @@ func (h *TopicHandler) GetTopics(c *gin.Context) {
 func (h *TopicHandler) CreateTopic(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
 	var req models.CreateTopicRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	userModel := user.(*models.User)
-	topic, err := h.topicService.CreateTopic(userModel.ID, &req)
+	topic, err := h.topicService.CreateTopic(userModel, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -68,15 +69,22 @@ func (h *TopicHandler) CreateTopic(c *gin.Context) {
 }
 
 func (h *TopicHandler) GetTopic(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	topicID := c.Param("id")
 	if topicID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Topic ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
-	topic, err := h.topicService.GetTopic(topicID)
+	userModel := user.(*models.User)
+	topic, err := h.topicService.GetTopic(userModel, topicID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+		c.Error(err)
 		return
 	}
 
@@ -84,21 +92,28 @@ func (h *TopicHandler) GetTopic(c *gin.Context) {
 }
 
 func (h *TopicHandler) UpdateTopic(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	topicID := c.Param("id")
 	if topicID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Topic ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
 	var req models.CreateTopicRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	topic, err := h.topicService.UpdateTopic(topicID, &req)
+	userModel := user.(*models.User)
+	topic, err := h.topicService.UpdateTopic(userModel, topicID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -106,15 +121,22 @@ func (h *TopicHandler) UpdateTopic(c *gin.Context) {
 }
 
 func (h *TopicHandler) DeleteTopic(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	topicID := c.Param("id")
 	if topicID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Topic ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
-	err := h.topicService.DeleteTopic(topicID)
+	userModel := user.(*models.User)
+	err := h.topicService.DeleteTopic(userModel, topicID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -122,9 +144,15 @@ func (h *TopicHandler) DeleteTopic(c *gin.Context) {
 }
 
 func (h *TopicHandler) GetTopicArticles(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
 	topicID := c.Param("id")
 	if topicID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Topic ID is required"})
+		c.Error(apperr.Required("id"))
 		return
 	}
 
@@ -138,11 +166,50 @@ func (h *TopicHandler) GetTopicArticles(c *gin.Context) {
 		pageSize = 20
 	}
 
-	articles, err := h.topicService.GetTopicArticles(topicID, page, pageSize)
+	state, ok := articleStateQuery(c)
+	if !ok {
+		c.Error(apperr.Invalid("state"))
+		return
+	}
+
+	userModel := user.(*models.User)
+	articles, err := h.topicService.GetTopicArticles(userModel, topicID, state, page, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, articles)
-}
\ No newline at end of file
+}
+func (h *TopicHandler) GetTopicStats(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	topicID := c.Param("id")
+	if topicID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	userModel := user.(*models.User)
+	stats, err := h.topicService.GetTopicStats(userModel, topicID, days, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
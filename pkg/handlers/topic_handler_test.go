@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"smg/pkg/apperr"
+	"smg/pkg/middleware"
+	"smg/pkg/models"
+	"smg/pkg/repository/memory"
+	"smg/pkg/services"
+)
+
+// topicRouter serves the topic routes for a caller in the given workspace.
+func topicRouter(handler *TopicHandler, workspaceID string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware(), func(c *gin.Context) {
+		c.Set("user", &models.User{ID: "user-" + workspaceID, Email: "user@example.com", WorkspaceID: workspaceID})
+	})
+	router.POST("/topics", handler.CreateTopic)
+	router.GET("/topics/:id", handler.GetTopic)
+	return router
+}
+
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "en")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestTopicHandler(t *testing.T) {
+	handler := NewTopicHandler(services.NewTopicService(memory.New()))
+	owner := topicRouter(handler, "workspace")
+	outsider := topicRouter(handler, "other")
+
+	w := serve(owner, http.MethodPost, "/topics", `{"name":"Go","keywords":["go"],"platforms":["twitter"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create topic: got status %d: %s", w.Code, w.Body)
+	}
+	var topic models.Topic
+	if err := json.Unmarshal(w.Body.Bytes(), &topic); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serve(owner, http.MethodGet, "/topics/"+topic.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("get topic: got status %d: %s", w.Code, w.Body)
+	}
+
+	for name, test := range map[string]struct {
+		router *gin.Engine
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		"topic of another workspace": {outsider, http.MethodGet, "/topics/" + topic.ID, "", http.StatusNotFound, "topic_not_found"},
+		"missing fields":             {owner, http.MethodPost, "/topics", `{"name":"Go"}`, http.StatusBadRequest, apperr.CodeValidation},
+	} {
+		w := serve(test.router, test.method, test.path, test.body)
+		if w.Code != test.status || w.Header().Get("Content-Type") != apperr.ContentType {
+			t.Fatalf("%s: got status %d, content type %q", name, w.Code, w.Header().Get("Content-Type"))
+		}
+		var problem apperr.Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatal(err)
+		}
+		if problem.Code != test.code || problem.Status != test.status {
+			t.Fatalf("%s: got problem %+v", name, problem)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/services"
+)
+
+type WorkspaceHandler struct {
+	workspaceService *services.WorkspaceService
+}
+
+func NewWorkspaceHandler(workspaceService *services.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{workspaceService: workspaceService}
+}
+
+func (h *WorkspaceHandler) GetWorkspaces(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	userModel := user.(*models.User)
+	workspaces, err := h.workspaceService.GetWorkspaces(userModel.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workspaces)
+}
+
+func (h *WorkspaceHandler) CreateWorkspace(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	var req models.WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userModel := user.(*models.User)
+	workspace, err := h.workspaceService.CreateWorkspace(userModel, &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, workspace)
+}
+
+func (h *WorkspaceHandler) GetWorkspace(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	workspaceID := c.Param("id")
+	if workspaceID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	userModel := user.(*models.User)
+	workspace, err := h.workspaceService.GetWorkspace(userModel, workspaceID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace)
+}
+
+func (h *WorkspaceHandler) UpdateWorkspace(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	workspaceID := c.Param("id")
+	if workspaceID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	var req models.WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userModel := user.(*models.User)
+	workspace, err := h.workspaceService.UpdateWorkspace(userModel, workspaceID, &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace)
+}
+
+func (h *WorkspaceHandler) DeleteWorkspace(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	workspaceID := c.Param("id")
+	if workspaceID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	userModel := user.(*models.User)
+	if err := h.workspaceService.DeleteWorkspace(userModel, workspaceID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Workspace deleted successfully"})
+}
+
+func (h *WorkspaceHandler) GetMembers(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	workspaceID := c.Param("id")
+	if workspaceID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	userModel := user.(*models.User)
+	members, err := h.workspaceService.GetMembers(userModel, workspaceID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+func (h *WorkspaceHandler) SetMemberRole(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	workspaceID := c.Param("id")
+	memberID := c.Param("userId")
+	if workspaceID == "" || memberID == "" {
+		c.Error(apperr.Required("id", "userId"))
+		return
+	}
+
+	var req models.WorkspaceMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userModel := user.(*models.User)
+	if err := h.workspaceService.SetMemberRole(userModel, workspaceID, memberID, req.Role); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member role updated successfully"})
+}
+
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	workspaceID := c.Param("id")
+	memberID := c.Param("userId")
+	if workspaceID == "" || memberID == "" {
+		c.Error(apperr.Required("id", "userId"))
+		return
+	}
+
+	userModel := user.(*models.User)
+	if err := h.workspaceService.RemoveMember(userModel, workspaceID, memberID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func (h *WorkspaceHandler) CreateInvitation(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	workspaceID := c.Param("id")
+	if workspaceID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	var req models.InvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userModel := user.(*models.User)
+	invitation, err := h.workspaceService.CreateInvitation(userModel, workspaceID, &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+func (h *WorkspaceHandler) GetInvitations(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	workspaceID := c.Param("id")
+	if workspaceID == "" {
+		c.Error(apperr.Required("id"))
+		return
+	}
+
+	userModel := user.(*models.User)
+	invitations, err := h.workspaceService.GetInvitations(userModel, workspaceID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+func (h *WorkspaceHandler) RevokeInvitation(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	workspaceID := c.Param("id")
+	invitationID := c.Param("invitationId")
+	if workspaceID == "" || invitationID == "" {
+		c.Error(apperr.Required("id", "invitationId"))
+		return
+	}
+
+	userModel := user.(*models.User)
+	if err := h.workspaceService.RevokeInvitation(userModel, workspaceID, invitationID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+func (h *WorkspaceHandler) AcceptInvitation(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	var req models.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userModel := user.(*models.User)
+	workspace, err := h.workspaceService.AcceptInvitation(userModel, req.Token)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace)
+}
@@ -0,0 +1,137 @@
+// Package health serves the /livez and /readyz probes. A probe runs its
+// checks concurrently, each under a timeout, and answers 200 when all pass
+// or 503 with the failing checks otherwise. The JSON body lists every check
+// either way.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Statuses.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc reports whether a dependency is usable. It must give up when
+// ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Probe is a named set of checks.
+type Probe struct {
+	timeout time.Duration
+	checks  []check
+}
+
+// Report is the result of running a probe.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// NewProbe returns a probe whose checks each get timeout to answer. A probe
+// without checks always passes, which is what a liveness probe wants: it
+// should fail only when the process itself is stuck, not when a dependency
+// is down.
+func NewProbe(timeout time.Duration) *Probe {
+	return &Probe{timeout: timeout}
+}
+
+// Add adds a check reported under name.
+func (p *Probe) Add(name string, fn CheckFunc) *Probe {
+	p.checks = append(p.checks, check{name: name, fn: fn})
+	return p
+}
+
+// Run runs every check and waits for all of them.
+func (p *Probe) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(p.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range p.checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+			result := p.run(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (p *Probe) run(ctx context.Context, c check) Result {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.fn(ctx)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Status:     StatusOK,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ServeHTTP runs the probe and writes its report.
+func (p *Probe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := p.Run(r.Context())
+
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// Postgres checks that db answers a ping.
+func Postgres(db *sql.DB) CheckFunc {
+	return db.PingContext
+}
+
+// Redis checks that client answers a PING.
+func Redis(client *redis.Client) CheckFunc {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the newest migration this build was written against.
+// Update it with every migration added to apps/web/prisma/migrations and
+// pkg/migrations.
+const SchemaVersion = "20261019190000_platforms"
+
+// Migrations checks the migrations recorded in db: none may have failed,
+// and the newest applied one must be expected or later. A newer schema
+// passes so the previous release keeps serving during a deploy.
+//
+// Databases migrated by Prisma keep their history in _prisma_migrations;
+// those migrated by cmd/migrate or pkg/dbtest only record the numeric
+// version in schema_migrations.
+func Migrations(db *sql.DB, expected string) CheckFunc {
+	return func(ctx context.Context) error {
+		var prisma, golang sql.NullString
+		err := db.QueryRowContext(ctx,
+			"SELECT to_regclass('_prisma_migrations')::text, to_regclass('schema_migrations')::text",
+		).Scan(&prisma, &golang)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case prisma.Valid:
+			return prismaMigrations(ctx, db, expected)
+		case golang.Valid:
+			return golangMigrations(ctx, db, expected)
+		}
+		return fmt.Errorf("no migrations applied, expected %s", expected)
+	}
+}
+
+func prismaMigrations(ctx context.Context, db *sql.DB, expected string) error {
+	var failed string
+	err := db.QueryRowContext(ctx, `
+		SELECT migration_name FROM _prisma_migrations
+		WHERE finished_at IS NULL AND rolled_back_at IS NULL
+		ORDER BY started_at
+		LIMIT 1
+	`).Scan(&failed)
+	if err == nil {
+		return fmt.Errorf("migration %s did not finish", failed)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	var applied sql.NullString
+	err = db.QueryRowContext(ctx, `
+		SELECT MAX(migration_name) FROM _prisma_migrations
+		WHERE finished_at IS NOT NULL AND rolled_back_at IS NULL
+	`).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if !applied.Valid {
+		return fmt.Errorf("no migrations applied, expected %s", expected)
+	}
+	if applied.String < expected {
+		return fmt.Errorf("schema is at %s, expected %s", applied.String, expected)
+	}
+	return nil
+}
+
+func golangMigrations(ctx context.Context, db *sql.DB, expected string) error {
+	prefix, _, _ := strings.Cut(expected, "_")
+	want, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil {
+		return fmt.Errorf("expected migration %s has no numeric version", expected)
+	}
+
+	var version int64
+	var dirty bool
+	err = db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no migrations applied, expected %s", expected)
+	}
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migration %d did not finish", version)
+	}
+	if version < want {
+		return fmt.Errorf("schema is at %d, expected %s", version, expected)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package health
+
+import (
+	"context"
+	"testing"
+
+	"smg/pkg/dbtest"
+)
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
+
+func TestMigrations(t *testing.T) {
+	db := dbtest.DB(t)
+
+	if err := Migrations(db, SchemaVersion)(context.Background()); err != nil {
+		t.Errorf("current schema: %v", err)
+	}
+	if err := Migrations(db, "20250101000000_older")(context.Background()); err != nil {
+		t.Errorf("older expectation: %v", err)
+	}
+	if err := Migrations(db, "20991231000000_future")(context.Background()); err == nil {
+		t.Error("future expectation passed")
+	}
+
+	if _, err := db.Exec("UPDATE schema_migrations SET dirty = true"); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("UPDATE schema_migrations SET dirty = false")
+	if err := Migrations(db, SchemaVersion)(context.Background()); err == nil {
+		t.Error("dirty schema passed")
+	}
+}
@@ -0,0 +1,268 @@
+// Package jwtkeys manages the keys used to sign and verify JWTs.
+//
+// Tokens are signed with a single active key and carry its ID in the kid
+// header. Any number of additional public keys can be kept for verification
+// so tokens signed before a key rotation stay valid until they expire. RSA
+// keys sign with RS256 and Ed25519 keys with EdDSA; the public halves are
+// published as a JWK set. When no private key is configured, tokens fall
+// back to HS256 with the shared secret, which is never published.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config selects the key material. PrivateKey holds a PEM encoded key
+// directly and takes precedence over PrivateKeyFile. PublicKeyFiles lists
+// PEM files with additional verification keys, typically the keys that were
+// active before the last rotation.
+type Config struct {
+	Secret         string
+	PrivateKey     string
+	PrivateKeyFile string
+	PublicKeyFiles []string
+}
+
+// Key is a single signing or verification key.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// Private is nil for verification-only keys.
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// KeySet signs tokens with its active key and verifies them against every
+// key it holds.
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// JWK is the public part of a key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Load builds a KeySet from cfg.
+func Load(cfg Config) (*KeySet, error) {
+	privatePEM := []byte(cfg.PrivateKey)
+	if len(privatePEM) == 0 && cfg.PrivateKeyFile != "" {
+		var err error
+		if privatePEM, err = os.ReadFile(cfg.PrivateKeyFile); err != nil {
+			return nil, fmt.Errorf("read JWT private key: %w", err)
+		}
+	}
+
+	if len(privatePEM) == 0 {
+		if cfg.Secret == "" {
+			return nil, errors.New("either a JWT private key or a JWT secret is required")
+		}
+		key := &Key{Method: jwt.SigningMethodHS256, Private: []byte(cfg.Secret), Public: []byte(cfg.Secret)}
+		return &KeySet{active: key, keys: map[string]*Key{"": key}}, nil
+	}
+
+	active, err := parseKey(privatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse JWT private key: %w", err)
+	}
+	if active.Private == nil {
+		return nil, errors.New("JWT private key is a public key")
+	}
+
+	ks := &KeySet{active: active, keys: map[string]*Key{active.ID: active}}
+	for _, path := range cfg.PublicKeyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read JWT verification key: %w", err)
+		}
+		key, err := parseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse JWT verification key %s: %w", path, err)
+		}
+		if _, exists := ks.keys[key.ID]; !exists {
+			key.Private = nil
+			ks.keys[key.ID] = key
+		}
+	}
+
+	return ks, nil
+}
+
+// Sign returns the signed token for claims, with the active key's ID in the
+// kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	if ks.active.ID != "" {
+		token.Header["kid"] = ks.active.ID
+	}
+	return token.SignedString(ks.active.Private)
+}
+
+// Keyfunc resolves the verification key for a token from its kid header. It
+// is meant to be passed to jwt.Parse together with ValidMethods.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("key %q does not use %s", kid, token.Method.Alg())
+	}
+	return key.Public, nil
+}
+
+// ValidMethods lists the algorithms of every key in the set.
+func (ks *KeySet) ValidMethods() []string {
+	seen := make(map[string]bool)
+	var methods []string
+	for _, key := range ks.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWKS returns the public keys of the set. Symmetric keys are never
+// included, so an HS256-only set publishes no keys.
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	// Active key first, then the rest in no particular order.
+	if jwk, ok := toJWK(ks.active); ok {
+		set.Keys = append(set.Keys, jwk)
+	}
+	for _, key := range ks.keys {
+		if key == ks.active {
+			continue
+		}
+		if jwk, ok := toJWK(key); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	return set
+}
+
+func parseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+	}
+
+	jwk, _ := toJWK(key)
+	key.ID = thumbprint(jwk)
+
+	return key, nil
+}
+
+func toJWK(key *Key) (JWK, bool) {
+	encode := base64.RawURLEncoding.EncodeToString
+
+	switch public := key.Public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: key.Method.Alg(),
+			Kid: key.ID,
+			N:   encode(public.N.Bytes()),
+			E:   encode(big.NewInt(int64(public.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Use: "sig",
+			Alg: key.Method.Alg(),
+			Kid: key.ID,
+			Crv: "Ed25519",
+			X:   encode(public),
+		}, true
+	}
+
+	return JWK{}, false
+}
+
+// thumbprint derives a key ID from the JWK thumbprint (RFC 7638), so the
+// same key always gets the same kid without having to configure one.
+func thumbprint(jwk JWK) string {
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	encoded, _ := json.Marshal(members)
+	sum := sha256.Sum256(encoded)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
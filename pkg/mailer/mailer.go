@@ -0,0 +1,139 @@
+// Package mailer renders the transactional email templates and delivers
+// them over SMTP.
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net"
+	"net/smtp"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Template names.
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplateResetPassword = "reset_password"
+)
+
+// Supported locales. Anything else falls back to DefaultLocale.
+const (
+	LocaleEnglish = "en"
+	LocaleChinese = "zh"
+	DefaultLocale = LocaleEnglish
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// templates maps "<name>.<locale>" to its template. Every file defines a
+// "subject" and a "body" template, so each is parsed on its own.
+var templates = loadTemplates()
+
+type Config struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+type Mailer struct {
+	config Config
+}
+
+// Message is a rendered email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+func New(config Config) *Mailer {
+	return &Mailer{config: config}
+}
+
+// Locale maps an Accept-Language style value to a supported locale.
+func Locale(value string) string {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(value)), "zh") {
+		return LocaleChinese
+	}
+	return DefaultLocale
+}
+
+// Render executes the named template in the given locale.
+func Render(name, locale string, to string, data interface{}) (*Message, error) {
+	t, ok := templates[name+"."+Locale(locale)]
+	if !ok {
+		return nil, fmt.Errorf("mail template %s not found", name)
+	}
+
+	var subject, body bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := t.ExecuteTemplate(&body, "body", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    strings.TrimLeft(body.String(), "\n"),
+	}, nil
+}
+
+// Send renders and delivers the named template.
+func (m *Mailer) Send(name, locale, to string, data interface{}) error {
+	msg, err := Render(name, locale, to, data)
+	if err != nil {
+		return err
+	}
+	return m.Deliver(msg)
+}
+
+// Deliver sends a rendered message. STARTTLS is used when the server offers
+// it; credentials are only sent when a username is configured.
+func (m *Mailer) Deliver(msg *Message) error {
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+
+	addr := net.JoinHostPort(m.config.Host, m.config.Port)
+	return smtp.SendMail(addr, auth, m.config.From, []string{msg.To}, m.encode(msg))
+}
+
+func (m *Mailer) encode(msg *Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+func loadTemplates() map[string]*template.Template {
+	files, err := fs.Glob(templateFS, "templates/*.tmpl")
+	if err != nil {
+		panic(err)
+	}
+
+	loaded := make(map[string]*template.Template)
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".tmpl")
+		loaded[name] = template.Must(template.ParseFS(templateFS, file))
+	}
+	return loaded
+}
@@ -0,0 +1,77 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"smg/pkg/mailer/smtptest"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	data := map[string]string{
+		"Name":      "Ada",
+		"Email":     "ada@example.com",
+		"Link":      "https://app.example.com/verify?token=abc",
+		"ExpiresIn": "24 hours",
+	}
+
+	for _, test := range []struct {
+		name, locale, subject string
+	}{
+		{TemplateVerifyEmail, "en", "Verify your email address"},
+		{TemplateVerifyEmail, "zh-TW", "請驗證您的電子郵件地址"},
+		{TemplateResetPassword, "en-US", "Reset your password"},
+		{TemplateResetPassword, "zh", "重設您的密碼"},
+		// Unsupported locales fall back to English.
+		{TemplateResetPassword, "fr", "Reset your password"},
+	} {
+		msg, err := Render(test.name, test.locale, data["Email"], data)
+		if err != nil {
+			t.Fatalf("%s.%s: %v", test.name, test.locale, err)
+		}
+		if msg.Subject != test.subject {
+			t.Errorf("%s.%s subject: got %q, want %q", test.name, test.locale, msg.Subject, test.subject)
+		}
+		for _, value := range data {
+			if !strings.Contains(msg.Body, value) {
+				t.Errorf("%s.%s body does not contain %q:\n%s", test.name, test.locale, value, msg.Body)
+			}
+		}
+		if strings.Contains(msg.Body, "<no value>") {
+			t.Errorf("%s.%s body refers to missing data:\n%s", test.name, test.locale, msg.Body)
+		}
+	}
+
+	if _, err := Render("welcome", "en", "ada@example.com", data); err == nil {
+		t.Fatal("rendering an unknown template succeeded")
+	}
+}
+
+func TestSend(t *testing.T) {
+	sink, err := smtptest.NewSink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	m := New(Config{Host: sink.Host, Port: sink.Port, From: "noreply@example.com"})
+	data := map[string]string{"Name": "Ada", "Email": "ada@example.com", "Link": "https://app.example.com", "ExpiresIn": "1 小時"}
+	if err := m.Send(TemplateResetPassword, "zh", "ada@example.com", data); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := sink.WaitForMessage(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.From != "noreply@example.com" || len(msg.To) != 1 || msg.To[0] != "ada@example.com" {
+		t.Fatalf("envelope: got from %q to %v", msg.From, msg.To)
+	}
+	if msg.Subject != "重設您的密碼" {
+		t.Fatalf("subject: got %q", msg.Subject)
+	}
+	if !strings.Contains(msg.Body, "此連結將於 1 小時 後失效") {
+		t.Fatalf("body: got\n%s", msg.Body)
+	}
+}
@@ -0,0 +1,195 @@
+// Package smtptest provides an in-process SMTP server that accepts every
+// message and keeps it in memory, so mail flows can be exercised without a
+// real mail server.
+//
+//	sink, _ := smtptest.NewSink()
+//	defer sink.Close()
+//	m := mailer.New(mailer.Config{Host: sink.Host, Port: sink.Port, From: "noreply@example.com"})
+//	...
+//	msg, _ := sink.WaitForMessage(time.Second)
+package smtptest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a received email.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string
+	Raw     string
+}
+
+// Sink is a minimal SMTP server. It supports the commands net/smtp's client
+// uses without TLS or authentication.
+type Sink struct {
+	Host string
+	Port string
+
+	listener net.Listener
+	messages chan Message
+
+	mu       sync.Mutex
+	received []Message
+}
+
+// NewSink starts a sink on a random local port.
+func NewSink() (*Sink, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	sink := &Sink{
+		Host:     host,
+		Port:     port,
+		listener: listener,
+		messages: make(chan Message, 100),
+	}
+	go sink.serve()
+
+	return sink, nil
+}
+
+// Messages returns every message received so far.
+func (s *Sink) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.received...)
+}
+
+// WaitForMessage returns the next message not yet returned by a previous
+// call, waiting up to timeout for it to arrive.
+func (s *Sink) WaitForMessage(timeout time.Duration) (*Message, error) {
+	select {
+	case msg := <-s.messages:
+		return &msg, nil
+	case <-time.After(timeout):
+		return nil, errors.New("smtptest: no message received")
+	}
+}
+
+func (s *Sink) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Sink) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Sink) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	reply("220 smtptest ready")
+
+	var from string
+	var to []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250 smtptest")
+		case "MAIL":
+			from = addressArg(line)
+			to = nil
+			reply("250 OK")
+		case "RCPT":
+			to = append(to, addressArg(line))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			raw, err := readData(r)
+			if err != nil {
+				return
+			}
+			s.store(from, to, raw)
+			reply("250 OK")
+		case "RSET":
+			from, to = "", nil
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func (s *Sink) store(from string, to []string, raw string) {
+	msg := Message{From: from, To: to, Raw: raw}
+	if parsed, err := mail.ReadMessage(strings.NewReader(raw)); err == nil {
+		subject := parsed.Header.Get("Subject")
+		if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+			subject = decoded
+		}
+		msg.Subject = subject
+
+		body, _ := io.ReadAll(parsed.Body)
+		msg.Body = strings.ReplaceAll(string(body), "\r\n", "\n")
+	}
+
+	s.mu.Lock()
+	s.received = append(s.received, msg)
+	s.mu.Unlock()
+
+	select {
+	case s.messages <- msg:
+	default:
+	}
+}
+
+func readData(r *bufio.Reader) (string, error) {
+	var data strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return data.String(), nil
+		}
+		// Undo dot-stuffing.
+		data.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+func addressArg(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.LastIndex(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
@@ -0,0 +1,157 @@
+// Package metrics defines the Prometheus metrics of the API and the
+// scheduler and serves them on /metrics. Each binary registers only what it
+// uses, so a metric without samples is simply absent.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "smg"
+
+// Job outcomes.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Registry holds every metric of the process, including the Go runtime and
+// process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	redisCommands = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_commands_total",
+		Help:      "Redis commands sent, by command.",
+	}, []string{"command"})
+
+	redisErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_command_errors_total",
+		Help:      "Redis commands that failed, by command. Missing keys are not errors.",
+	}, []string{"command"})
+
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scheduler_job_runs_total",
+		Help:      "Scheduler job runs by job and outcome.",
+	}, []string{"job", "outcome"})
+
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scheduler_job_duration_seconds",
+		Help:      "Scheduler job run duration by job.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"job"})
+
+	repostsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reposts_published_total",
+		Help:      "Reposts published, by platform.",
+	}, []string{"platform"})
+
+	repostsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reposts_failed_total",
+		Help:      "Repost publish attempts that failed, by platform.",
+	}, []string{"platform"})
+
+	articlesIngested = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "articles_ingested_total",
+		Help:      "New articles stored by the fetch job, by source platform.",
+	}, []string{"source"})
+
+	aiCaptionDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ai_caption_duration_seconds",
+		Help:      "Time taken to generate an AI caption.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// RegisterAPI registers the metrics the API records.
+func RegisterAPI() {
+	Registry.MustRegister(httpRequests, httpDuration, redisCommands, redisErrors)
+}
+
+// RegisterScheduler registers the metrics the scheduler records.
+func RegisterScheduler() {
+	Registry.MustRegister(jobRuns, jobDuration, repostsPublished, repostsFailed, articlesIngested, aiCaptionDuration)
+}
+
+// RegisterDB exports the connection pool statistics of db under the given
+// name, such as "postgres".
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the registered metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveHTTPRequest records a served request. route is the route template,
+// such as /api/articles/:id, so IDs do not become labels.
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveJobRun records a finished scheduler job run.
+func ObserveJobRun(job string, err error, duration time.Duration) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeFailure
+	}
+	jobRuns.WithLabelValues(job, outcome).Inc()
+	jobDuration.WithLabelValues(job).Observe(duration.Seconds())
+}
+
+// RepostPublished counts a successful publish attempt.
+func RepostPublished(platform string) {
+	repostsPublished.WithLabelValues(platform).Inc()
+}
+
+// RepostFailed counts a failed publish attempt.
+func RepostFailed(platform string) {
+	repostsFailed.WithLabelValues(platform).Inc()
+}
+
+// ArticlesIngested counts n new articles from source.
+func ArticlesIngested(source string, n int) {
+	articlesIngested.WithLabelValues(source).Add(float64(n))
+}
+
+// ObserveAICaption records how long generating one caption took.
+func ObserveAICaption(duration time.Duration) {
+	aiCaptionDuration.Observe(duration.Seconds())
+}
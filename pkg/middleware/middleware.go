@@ -1,23 +1,57 @@
 package middleware
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"reflect"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"smg/pkg/apperr"
+	"smg/pkg/logging"
+	"smg/pkg/metrics"
 	"smg/pkg/models"
+	"smg/pkg/ratelimit"
+	"smg/pkg/rbac"
 	"smg/pkg/services"
+	"smg/pkg/tracing"
 )
 
-// CORSMiddleware handles CORS headers
-func CORSMiddleware() gin.HandlerFunc {
+// CORSMiddleware handles CORS headers for the allowed origins. "*" allows
+// any origin; otherwise a matching request origin is echoed back.
+func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
+	anyOrigin := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		origin := c.GetHeader("Origin")
+		switch {
+		case anyOrigin:
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		case allowed[origin]:
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Add("Vary", "Origin")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, X-Workspace-ID, X-Device-Name, X-Request-ID, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -28,68 +62,398 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-// LoggerMiddleware logs HTTP requests
-func LoggerMiddleware() gin.HandlerFunc {
-	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
-			param.ClientIP,
-			param.TimeStamp.Format(time.RFC1123),
-			param.Method,
-			param.Path,
-			param.Request.Proto,
-			param.StatusCode,
-			param.Latency,
-			param.Request.UserAgent(),
-			param.ErrorMessage,
+// maxRequestIDLength bounds a client-supplied X-Request-ID, which ends up
+// in logs and audit events.
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware gives every request an ID. A well-formed X-Request-ID
+// from the client is kept, so a request can be followed across services;
+// otherwise a new one is generated. The ID is echoed in the response and
+// the request context carries it along with a logger that includes it.
+func RequestIDMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = logging.NewID()
+		}
+		c.Header("X-Request-ID", requestID)
+
+		ctx := logging.WithRequestID(c.Request.Context(), requestID)
+		ctx = logging.WithLogger(ctx, logger.With("request_id", requestID))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-_.:", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// TracingMiddleware starts a server span for every request, named after its
+// route template and continuing the trace of an incoming traceparent
+// header. The request logger gains the trace ID, so log lines and spans can
+// be matched up.
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				attribute.String("request_id", logging.RequestID(ctx)),
+			),
 		)
-	})
+		defer span.End()
+
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("trace_id", traceID))
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		for _, err := range c.Errors {
+			span.RecordError(err.Err)
+		}
+	}
 }
 
-// AuthMiddleware validates JWT tokens
-func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
+// LoggerMiddleware writes one access log line per request with the request
+// logger, so the line carries the request ID and, once authenticated, the
+// user ID.
+func LoggerMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			attrs = append(attrs, slog.String("errors", errs))
+		}
+
+		logging.FromContext(c.Request.Context()).LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// MetricsMiddleware records the latency and status of every request by
+// route template. Requests that match no route share one label, so probes
+// of random paths cannot grow the number of series.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// ErrorMiddleware renders the last error a handler or middleware added with
+// c.Error as RFC 7807 problem details, in English or Chinese following
+// Accept-Language. Errors that are not *apperr.Error are internal: the
+// access log records them and the client only learns that something failed.
+func ErrorMiddleware() gin.HandlerFunc {
+	// Report invalid fields by the names clients send.
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := apperr.From(c.Errors.Last().Err)
+		lang := apperr.Language(c.GetHeader("Accept-Language"))
+		problem := err.Problem(lang)
+		problem.Instance = c.Request.URL.Path
+		problem.RequestID = logging.RequestID(c.Request.Context())
+
+		if err.RetryAfter > 0 {
+			c.Header("Retry-After", ratelimit.RetryAfter(err.RetryAfter))
+		}
+		c.Header("Content-Type", apperr.ContentType)
+		c.Header("Content-Language", lang)
+		c.Header("Vary", "Accept-Language")
+		c.JSON(problem.Status, problem)
+	}
+}
+
+// AuthMiddleware authenticates the request with a JWT access token or a
+// personal API key. API keys are sent in X-API-Key or as a bearer token
+// starting with "smg_". A key's permissions are its owner's limited to the
+// key's scopes, and it never carries the owner's admin bypass.
+func AuthMiddleware(authService *services.AuthService, apiKeyService *services.APIKeyService, rbacService *services.RBACService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader("X-API-Key")
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		if apiKey == "" && authHeader == "" {
+			c.Error(apperr.Unauthorized("authorization_required", "Authorization header required"))
 			c.Abort()
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
+		if apiKey == "" && tokenString == authHeader {
+			c.Error(apperr.Unauthorized("bearer_token_required", "Bearer token required"))
+			c.Abort()
+			return
+		}
+		if apiKey == "" && strings.HasPrefix(tokenString, services.APIKeyPrefix) {
+			apiKey = tokenString
+		}
+
+		var user *models.User
+		var key *models.APIKey
+		var err error
+		if apiKey != "" {
+			user, key, err = apiKeyService.Authenticate(apiKey)
+		} else {
+			user, err = authService.ValidateToken(tokenString)
+		}
+		if err != nil {
+			if !errors.Is(err, services.ErrTokenRevoked) && !errors.Is(err, services.ErrInvalidAPIKey) {
+				err = services.ErrInvalidToken.Wrap(err)
+			}
+			c.Error(err)
 			c.Abort()
 			return
 		}
 
-		user, err := authService.ValidateToken(tokenString)
+		permissions, err := rbacService.Permissions(user.ID)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Error(fmt.Errorf("load permissions: %w", err))
 			c.Abort()
 			return
 		}
 
+		if key != nil {
+			permissions = rbac.Restrict(permissions, key.Scopes)
+			user.IsAdmin = false
+			c.Set("api_key", key)
+		}
+
+		user.IPAddress = c.ClientIP()
+		user.RequestID = logging.RequestID(c.Request.Context())
+
+		ctx := c.Request.Context()
+		c.Request = c.Request.WithContext(logging.WithLogger(ctx, logging.FromContext(ctx).With("user_id", user.ID)))
+
 		c.Set("user", user)
+		c.Set("permissions", permissions)
 		c.Next()
 	}
 }
 
-// AdminMiddleware ensures user is admin
-func AdminMiddleware() gin.HandlerFunc {
+// DenyAPIKeys rejects requests authenticated with an API key, for routes
+// that manage the account itself: keys must not mint keys, change 2FA,
+// approve logins, edit the user or manage workspaces.
+func DenyAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, viaKey := c.Get("api_key"); viaKey {
+			c.Error(apperr.Forbidden("api_key_not_allowed", "Not available with an API key"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// WorkspaceMiddleware selects the workspace the request acts on. It is read
+// from the X-Workspace-ID header, falling back to the token's workspace claim
+// and then to the user's personal workspace. Content permissions are narrowed
+// to the user's role in that workspace; global admins may act in any
+// workspace with their global permissions.
+func WorkspaceMiddleware(workspaceService *services.WorkspaceService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user, exists := c.Get("user")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Error(apperr.ErrUnauthenticated)
+			c.Abort()
+			return
+		}
+		userModel := user.(*models.User)
+
+		workspaceID := c.GetHeader("X-Workspace-ID")
+		if workspaceID == "" {
+			workspaceID = userModel.WorkspaceID
+		}
+		if workspaceID == "" {
+			workspaceID = services.PersonalWorkspaceID(userModel.ID)
+		}
+
+		role, err := workspaceService.MemberRole(workspaceID, userModel.ID)
+		if err == sql.ErrNoRows && workspaceID == services.PersonalWorkspaceID(userModel.ID) {
+			// Users created outside the API have no personal workspace yet.
+			if err = workspaceService.EnsurePersonalWorkspace(userModel); err == nil {
+				role = rbac.RoleOwner
+			}
+		}
+		if err != nil && err != sql.ErrNoRows {
+			c.Error(fmt.Errorf("load workspace membership: %w", err))
+			c.Abort()
+			return
+		}
+		if err == sql.ErrNoRows && !userModel.IsAdmin {
+			c.Error(apperr.Forbidden("workspace_membership_required", "Not a member of this workspace"))
 			c.Abort()
 			return
 		}
 
-		if !user.(*models.User).IsAdmin {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		if role != "" {
+			permissions, _ := c.Get("permissions")
+			c.Set("permissions", rbac.WorkspacePermissions(permissions.([]string), role))
+		}
+
+		userModel.WorkspaceID = workspaceID
+		c.Set("workspace_role", role)
+		c.Next()
+	}
+}
+
+// RateLimitMiddleware limits requests per client IP. Requests over the limit
+// get a 429 with Retry-After. If Redis is unavailable requests are let
+// through rather than taking the endpoint down.
+func RateLimitMiddleware(limiter *ratelimit.Limiter, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := limiter.Allow(c.Request.Context(), limit, c.ClientIP())
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Warn("rate limit unavailable", "limit", limit.Name, "error", err)
+			c.Next()
+			return
+		}
+
+		if !result.Allowed {
+			c.Error(apperr.RateLimited(result.RetryAfter))
 			c.Abort()
 			return
 		}
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// MFAEnrollmentMiddleware blocks users who are required to have two-factor
+// authentication but have not enrolled yet. The /api/auth routes stay open so
+// they can enroll and manage their sessions.
+func MFAEnrollmentMiddleware(mfaService *services.MFAService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.Error(apperr.ErrUnauthenticated)
+			c.Abort()
+			return
+		}
+		userModel := user.(*models.User)
+
+		if userModel.TwoFactorEnabled || strings.HasPrefix(c.FullPath(), "/api/auth/") {
+			c.Next()
+			return
+		}
+
+		required, err := mfaService.Required(userModel)
+		if err != nil {
+			c.Error(fmt.Errorf("load two-factor policy: %w", err))
+			c.Abort()
+			return
+		}
+		if required {
+			c.Error(apperr.Forbidden("mfa_enrollment_required", "Two-factor authentication must be enabled"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission ensures the authenticated user's role grants permission
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permissions, exists := c.Get("permissions")
+		if !exists {
+			c.Error(apperr.ErrUnauthenticated)
+			c.Abort()
+			return
+		}
+
+		if !rbac.Has(permissions.([]string), permission) {
+			c.Error(apperr.Forbidden("permission_required", "Permission {permission} required").With("permission", permission))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermissionOrSelf is RequirePermission for routes on a single user,
+// named by the :id parameter; a user acting on their own record needs no
+// permission.
+func RequirePermissionOrSelf(permission string) gin.HandlerFunc {
+	requirePermission := RequirePermission(permission)
+	return func(c *gin.Context) {
+		if user, exists := c.Get("user"); exists && user.(*models.User).ID == c.Param("id") {
+			c.Next()
+			return
+		}
+
+		requirePermission(c)
+	}
+}
@@ -0,0 +1,26 @@
+-- DropTable
+DROP TABLE "reposts";
+
+-- DropTable
+DROP TABLE "articles";
+
+-- DropTable
+DROP TABLE "media_accounts";
+
+-- DropTable
+DROP TABLE "topics";
+
+-- DropTable
+DROP TABLE "system_settings";
+
+-- DropTable
+DROP TABLE "verification_tokens";
+
+-- DropTable
+DROP TABLE "sessions";
+
+-- DropTable
+DROP TABLE "accounts";
+
+-- DropTable
+DROP TABLE "users";
@@ -0,0 +1,10 @@
+-- DropTable
+DROP TABLE "repost_approvals";
+
+-- DropTable
+DROP TABLE "approval_policies";
+
+-- AlterTable
+ALTER TABLE "reposts" DROP COLUMN "approval_status",
+DROP COLUMN "approved_at",
+DROP COLUMN "approved_by";
@@ -0,0 +1,9 @@
+-- DropIndex
+DROP INDEX "articles_topic_id_state_idx";
+
+-- DropIndex
+DROP INDEX "articles_user_id_state_idx";
+
+-- AlterTable
+ALTER TABLE "articles" DROP COLUMN "state",
+DROP COLUMN "state_changed_at";
@@ -0,0 +1,9 @@
+-- AlterTable
+ALTER TABLE "articles" ADD COLUMN     "state" TEXT NOT NULL DEFAULT 'new',
+ADD COLUMN     "state_changed_at" TIMESTAMP(3);
+
+-- CreateIndex
+CREATE INDEX "articles_user_id_state_idx" ON "articles"("user_id", "state");
+
+-- CreateIndex
+CREATE INDEX "articles_topic_id_state_idx" ON "articles"("topic_id", "state");
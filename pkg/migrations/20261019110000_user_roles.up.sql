@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "users" ADD COLUMN     "role" TEXT NOT NULL DEFAULT 'editor';
+
+-- Existing administrators keep their rights under the admin role
+UPDATE "users" SET "role" = 'admin' WHERE "is_admin" = true;
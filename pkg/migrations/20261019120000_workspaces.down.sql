@@ -0,0 +1,20 @@
+-- AlterTable
+ALTER TABLE "reposts" DROP COLUMN "workspace_id";
+
+-- AlterTable
+ALTER TABLE "articles" DROP COLUMN "workspace_id";
+
+-- AlterTable
+ALTER TABLE "media_accounts" DROP COLUMN "workspace_id";
+
+-- AlterTable
+ALTER TABLE "topics" DROP COLUMN "workspace_id";
+
+-- DropTable
+DROP TABLE "workspace_invitations";
+
+-- DropTable
+DROP TABLE "workspace_members";
+
+-- DropTable
+DROP TABLE "workspaces";
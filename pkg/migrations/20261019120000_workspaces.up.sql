@@ -0,0 +1,117 @@
+-- CreateTable
+CREATE TABLE "workspaces" (
+    "id" TEXT NOT NULL,
+    "name" TEXT NOT NULL,
+    "personal" BOOLEAN NOT NULL DEFAULT false,
+    "owner_id" TEXT NOT NULL,
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(3) NOT NULL,
+
+    CONSTRAINT "workspaces_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "workspace_members" (
+    "id" TEXT NOT NULL,
+    "workspace_id" TEXT NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "role" TEXT NOT NULL DEFAULT 'editor',
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "workspace_members_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "workspace_invitations" (
+    "id" TEXT NOT NULL,
+    "workspace_id" TEXT NOT NULL,
+    "email" TEXT NOT NULL,
+    "role" TEXT NOT NULL DEFAULT 'editor',
+    "token_hash" TEXT NOT NULL,
+    "invited_by" TEXT NOT NULL,
+    "expires_at" TIMESTAMP(3) NOT NULL,
+    "accepted_at" TIMESTAMP(3),
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "workspace_invitations_pkey" PRIMARY KEY ("id")
+);
+
+-- Backfill: one personal workspace per existing user
+INSERT INTO "workspaces" ("id", "name", "personal", "owner_id", "created_at", "updated_at")
+SELECT 'ws_' || "id", COALESCE("name", "email"), true, "id", CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+FROM "users";
+
+INSERT INTO "workspace_members" ("id", "workspace_id", "user_id", "role", "created_at")
+SELECT 'wm_' || "id", 'ws_' || "id", "id", 'owner', CURRENT_TIMESTAMP
+FROM "users";
+
+-- AlterTable
+ALTER TABLE "topics" ADD COLUMN     "workspace_id" TEXT;
+UPDATE "topics" SET "workspace_id" = 'ws_' || "user_id";
+ALTER TABLE "topics" ALTER COLUMN "workspace_id" SET NOT NULL;
+
+-- AlterTable
+ALTER TABLE "media_accounts" ADD COLUMN     "workspace_id" TEXT;
+UPDATE "media_accounts" SET "workspace_id" = 'ws_' || "user_id";
+ALTER TABLE "media_accounts" ALTER COLUMN "workspace_id" SET NOT NULL;
+
+-- AlterTable
+ALTER TABLE "articles" ADD COLUMN     "workspace_id" TEXT;
+UPDATE "articles" SET "workspace_id" = 'ws_' || "user_id";
+ALTER TABLE "articles" ALTER COLUMN "workspace_id" SET NOT NULL;
+
+-- AlterTable
+ALTER TABLE "reposts" ADD COLUMN     "workspace_id" TEXT;
+UPDATE "reposts" SET "workspace_id" = 'ws_' || "user_id";
+ALTER TABLE "reposts" ALTER COLUMN "workspace_id" SET NOT NULL;
+
+-- CreateIndex
+CREATE UNIQUE INDEX "workspace_members_workspace_id_user_id_key" ON "workspace_members"("workspace_id", "user_id");
+
+-- CreateIndex
+CREATE INDEX "workspace_members_user_id_idx" ON "workspace_members"("user_id");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "workspace_invitations_token_hash_key" ON "workspace_invitations"("token_hash");
+
+-- CreateIndex
+CREATE INDEX "workspace_invitations_workspace_id_idx" ON "workspace_invitations"("workspace_id");
+
+-- CreateIndex
+CREATE INDEX "topics_workspace_id_idx" ON "topics"("workspace_id");
+
+-- CreateIndex
+CREATE INDEX "media_accounts_workspace_id_idx" ON "media_accounts"("workspace_id");
+
+-- CreateIndex
+CREATE INDEX "articles_workspace_id_state_idx" ON "articles"("workspace_id", "state");
+
+-- CreateIndex
+CREATE INDEX "reposts_workspace_id_idx" ON "reposts"("workspace_id");
+
+-- AddForeignKey
+ALTER TABLE "workspaces" ADD CONSTRAINT "workspaces_owner_id_fkey" FOREIGN KEY ("owner_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "workspace_members" ADD CONSTRAINT "workspace_members_workspace_id_fkey" FOREIGN KEY ("workspace_id") REFERENCES "workspaces"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "workspace_members" ADD CONSTRAINT "workspace_members_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "workspace_invitations" ADD CONSTRAINT "workspace_invitations_workspace_id_fkey" FOREIGN KEY ("workspace_id") REFERENCES "workspaces"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "workspace_invitations" ADD CONSTRAINT "workspace_invitations_invited_by_fkey" FOREIGN KEY ("invited_by") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "topics" ADD CONSTRAINT "topics_workspace_id_fkey" FOREIGN KEY ("workspace_id") REFERENCES "workspaces"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "media_accounts" ADD CONSTRAINT "media_accounts_workspace_id_fkey" FOREIGN KEY ("workspace_id") REFERENCES "workspaces"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "articles" ADD CONSTRAINT "articles_workspace_id_fkey" FOREIGN KEY ("workspace_id") REFERENCES "workspaces"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "reposts" ADD CONSTRAINT "reposts_workspace_id_fkey" FOREIGN KEY ("workspace_id") REFERENCES "workspaces"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,10 @@
+-- DropIndex
+DROP INDEX "sessions_user_id_idx";
+
+-- AlterTable
+ALTER TABLE "sessions" DROP COLUMN "created_at",
+DROP COLUMN "device_name",
+DROP COLUMN "ip_address",
+DROP COLUMN "last_seen_at",
+DROP COLUMN "login_method",
+DROP COLUMN "user_agent";
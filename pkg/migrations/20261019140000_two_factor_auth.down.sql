@@ -0,0 +1,6 @@
+-- DropTable
+DROP TABLE "two_factor_recovery_codes";
+
+-- AlterTable
+ALTER TABLE "users" DROP COLUMN "two_factor_enabled_at",
+DROP COLUMN "two_factor_secret";
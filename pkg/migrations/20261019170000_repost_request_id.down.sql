@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "reposts" DROP COLUMN "request_id";
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "reposts" ADD COLUMN     "request_id" TEXT;
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "reposts" DROP COLUMN "trace_context";
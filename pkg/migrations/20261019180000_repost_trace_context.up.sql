@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "reposts" ADD COLUMN     "trace_context" TEXT;
@@ -0,0 +1,77 @@
+// Package migrations embeds the Go services' copy of the database schema.
+//
+// Every Prisma migration in apps/web/prisma/migrations has a matching
+// <name>.up.sql here, copied verbatim, and a hand-written <name>.down.sql
+// that undoes it. golang-migrate records the numeric prefix of the newest
+// applied file in schema_migrations.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// FS holds the migration files.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// New returns a migrator that applies FS to db. Closing the migrator
+// closes db.
+func New(db *sql.DB) (*migrate.Migrate, error) {
+	return Open(db, FS)
+}
+
+// Open is New with the migrations read from the root of fsys, such as
+// os.DirFS("pkg/migrations") to try out files before they are embedded.
+func Open(db *sql.DB, fsys fs.FS) (*migrate.Migrate, error) {
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return withDriver(driver, fsys)
+}
+
+// Up applies every pending migration to db. Unlike New it borrows a
+// single connection and leaves db open.
+func Up(db *sql.DB) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	m, err := withDriver(driver, FS)
+	if err != nil {
+		driver.Close()
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+func withDriver(driver database.Driver, fsys fs.FS) (*migrate.Migrate, error) {
+	source, err := iofs.New(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.NewWithInstance("iofs", source, "postgres", driver)
+}
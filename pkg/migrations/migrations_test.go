@@ -0,0 +1,104 @@
+package migrations_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"smg/pkg/dbtest"
+	"smg/pkg/migrations"
+)
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
+
+// TestMatchesPrisma keeps the embedded schema in step with the Prisma one
+// the web app migrates.
+func TestMatchesPrisma(t *testing.T) {
+	dirs, err := os.ReadDir("../../apps/web/prisma/migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prisma := make(map[string]bool)
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		name := dir.Name()
+		prisma[name] = true
+
+		want, err := os.ReadFile(filepath.Join("../../apps/web/prisma/migrations", name, "migration.sql"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := migrations.FS.ReadFile(name + ".up.sql")
+		if err != nil {
+			t.Errorf("%s: no up migration", name)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s.up.sql differs from the Prisma migration", name)
+		}
+		if _, err := migrations.FS.ReadFile(name + ".down.sql"); err != nil {
+			t.Errorf("%s: no down migration", name)
+		}
+	}
+
+	files, err := migrations.FS.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		name := file.Name()
+		for _, suffix := range []string{".up.sql", ".down.sql"} {
+			if base, ok := strings.CutSuffix(name, suffix); ok && !prisma[base] {
+				t.Errorf("%s has no Prisma migration", name)
+			}
+		}
+	}
+}
+
+func TestDownUp(t *testing.T) {
+	db := dbtest.DB(t)
+
+	// Not closed: closing the migrator would close the shared database.
+	m, err := migrations.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Down(); err != nil {
+		t.Fatal("down:", err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal("up:", err)
+	}
+
+	if err := m.Up(); !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("second up = %v, want ErrNoChange", err)
+	}
+	dbtest.Seed(t, db)
+}
+
+func TestSeed(t *testing.T) {
+	for _, env := range []string{"development", "staging", "test"} {
+		t.Run(env, func(t *testing.T) {
+			db := dbtest.DB(t)
+			// Seeds are loaded again on existing databases.
+			for i := 0; i < 2; i++ {
+				if err := migrations.Seed(db, env); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+
+	if err := migrations.Seed(nil, "production"); err == nil {
+		t.Error("production has seed data")
+	}
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+//go:embed seeds/*.sql
+var seeds embed.FS
+
+// Seed loads the fixtures in seeds/<env>.sql into db in one transaction.
+// Production has none.
+func Seed(db *sql.DB, env string) error {
+	script, err := seeds.ReadFile("seeds/" + env + ".sql")
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no seed data for environment %q", env)
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(string(script)); err != nil {
+		return fmt.Errorf("seed %s: %w", env, err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,47 @@
+-- Sample data for a local database. Both users sign in with the password
+-- "password". Safe to load more than once.
+
+INSERT INTO "users" ("id", "name", "email", "email_verified", "password", "is_admin", "role", "created_at", "updated_at") VALUES
+('user_admin', 'Admin', 'admin@example.com', CURRENT_TIMESTAMP, '$2a$10$0Mp78QEaRgIsE7LrW9eKLOOBKMKwjWXe2CCyWP61HSdhZPvS0HeCO', true, 'admin', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+('user_editor', 'Editor', 'editor@example.com', CURRENT_TIMESTAMP, '$2a$10$0Mp78QEaRgIsE7LrW9eKLOOBKMKwjWXe2CCyWP61HSdhZPvS0HeCO', false, 'editor', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "workspaces" ("id", "name", "personal", "owner_id", "created_at", "updated_at") VALUES
+('ws_user_admin', 'Admin', true, 'user_admin', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+('ws_user_editor', 'Editor', true, 'user_editor', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+('ws_newsroom', 'Newsroom', false, 'user_editor', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "workspace_members" ("id", "workspace_id", "user_id", "role", "created_at") VALUES
+('wm_user_admin', 'ws_user_admin', 'user_admin', 'owner', CURRENT_TIMESTAMP),
+('wm_user_editor', 'ws_user_editor', 'user_editor', 'owner', CURRENT_TIMESTAMP),
+('wm_newsroom_editor', 'ws_newsroom', 'user_editor', 'owner', CURRENT_TIMESTAMP),
+('wm_newsroom_admin', 'ws_newsroom', 'user_admin', 'editor', CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "topics" ("id", "name", "description", "keywords", "platforms", "workspace_id", "user_id", "created_at", "updated_at") VALUES
+('topic_golang', 'Go', 'News from the Go community', '{go,golang}', '{twitter}', 'ws_newsroom', 'user_editor', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+('topic_postgres', 'PostgreSQL', 'Database releases and tips', '{postgres,postgresql}', '{twitter}', 'ws_newsroom', 'user_editor', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "media_accounts" ("id", "platform", "account_id", "account_name", "workspace_id", "user_id", "created_at", "updated_at") VALUES
+('media_twitter', 'twitter', '1001', '@newsroom', 'ws_newsroom', 'user_editor', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "articles" ("id", "title", "content", "original_url", "platform", "author_name", "published_at", "topic_id", "workspace_id", "user_id", "state", "created_at", "updated_at") VALUES
+('article_go_release', 'Go 1.21 is released', 'Go 1.21 adds min, max and clear builtins.', 'https://go.dev/blog/go1.21', 'twitter', 'The Go Team', CURRENT_TIMESTAMP - INTERVAL '2 days', 'topic_golang', 'ws_newsroom', 'user_editor', 'approved', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+('article_go_slog', 'Structured logging with slog', 'The log/slog package brings structured logging to the standard library.', 'https://go.dev/blog/slog', 'twitter', 'Jonathan Amsterdam', CURRENT_TIMESTAMP - INTERVAL '1 day', 'topic_golang', 'ws_newsroom', 'user_editor', 'new', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+('article_pg_release', 'PostgreSQL 15 released', 'MERGE, logical replication improvements and faster sorts.', 'https://www.postgresql.org/about/news/postgresql-15-released-2526/', 'twitter', 'PostgreSQL', CURRENT_TIMESTAMP - INTERVAL '3 days', 'topic_postgres', 'ws_newsroom', 'user_editor', 'new', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "reposts" ("id", "article_id", "media_account_id", "custom_caption", "status", "scheduled_at", "workspace_id", "user_id", "created_at", "updated_at") VALUES
+('repost_go_release', 'article_go_release', 'media_twitter', 'Go 1.21 is out!', 'pending', CURRENT_TIMESTAMP + INTERVAL '1 hour', 'ws_newsroom', 'user_editor', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "platforms" ("id", "name", "display_name", "enabled", "config", "created_at", "updated_at") VALUES
+('platform_twitter', 'twitter', 'Twitter', true, '{}', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "system_settings" ("id", "key", "value", "created_at", "updated_at") VALUES
+('setting_require_admin_2fa', 'security.require_admin_2fa', 'false', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
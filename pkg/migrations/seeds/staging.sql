@@ -0,0 +1,10 @@
+-- Reference data for staging. Accounts are created by signing up, so
+-- there are no users here. Safe to load more than once.
+
+INSERT INTO "platforms" ("id", "name", "display_name", "enabled", "config", "created_at", "updated_at") VALUES
+('platform_twitter', 'twitter', 'Twitter', true, '{}', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "system_settings" ("id", "key", "value", "created_at", "updated_at") VALUES
+('setting_require_admin_2fa', 'security.require_admin_2fa', 'true', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,29 @@
+-- Fixtures for integration tests, loaded by dbtest.Seed. Tests refer to
+-- these IDs through dbtest.Fixtures, so keep the two in step.
+
+INSERT INTO "users" ("id", "name", "email", "is_admin", "role", "created_at", "updated_at") VALUES
+('user_admin', 'Admin', 'admin@example.com', true, 'admin', '2026-01-01 09:00:00', '2026-01-01 09:00:00'),
+('user_editor', 'Editor', 'editor@example.com', false, 'editor', '2026-01-01 09:00:00', '2026-01-01 09:00:00')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "workspaces" ("id", "name", "personal", "owner_id", "created_at", "updated_at") VALUES
+('ws_user_admin', 'Admin', true, 'user_admin', '2026-01-01 09:00:00', '2026-01-01 09:00:00'),
+('ws_user_editor', 'Editor', true, 'user_editor', '2026-01-01 09:00:00', '2026-01-01 09:00:00')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "workspace_members" ("id", "workspace_id", "user_id", "role", "created_at") VALUES
+('wm_user_admin', 'ws_user_admin', 'user_admin', 'owner', '2026-01-01 09:00:00'),
+('wm_user_editor', 'ws_user_editor', 'user_editor', 'owner', '2026-01-01 09:00:00')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "topics" ("id", "name", "description", "keywords", "platforms", "workspace_id", "user_id", "created_at", "updated_at") VALUES
+('topic_golang', 'Go', 'Articles about Go', '{go,golang}', '{twitter}', 'ws_user_editor', 'user_editor', '2026-01-01 09:00:00', '2026-01-01 09:00:00')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "media_accounts" ("id", "platform", "account_id", "account_name", "workspace_id", "user_id", "created_at", "updated_at") VALUES
+('media_twitter', 'twitter', '1001', '@editor', 'ws_user_editor', 'user_editor', '2026-01-01 09:00:00', '2026-01-01 09:00:00')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "articles" ("id", "title", "content", "original_url", "platform", "published_at", "topic_id", "workspace_id", "user_id", "created_at", "updated_at") VALUES
+('article_release', 'Go 1.21 is released', 'Release notes', 'https://go.dev/blog/go1.21', 'twitter', '2026-01-01 09:00:00', 'topic_golang', 'ws_user_editor', 'user_editor', '2026-01-01 09:00:00', '2026-01-01 09:00:00')
+ON CONFLICT DO NOTHING;
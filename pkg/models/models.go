@@ -12,6 +12,12 @@ type User struct {
 	Password      *string   `json:"-" db:"password"`
 	Image         *string   `json:"image" db:"image"`
 	IsAdmin       bool      `json:"is_admin" db:"is_admin"`
+	Role          string    `json:"role" db:"role"`
+	TwoFactorEnabled bool   `json:"two_factor_enabled" db:"-"`
+	WorkspaceID   string    `json:"workspace_id,omitempty" db:"-"` // active workspace of the request
+	SessionID     string    `json:"-" db:"-"`                      // token family of the request
+	IPAddress     string    `json:"-" db:"-"`                      // client address of the request
+	RequestID     string    `json:"-" db:"-"`                      // X-Request-ID of the request
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -22,6 +28,7 @@ type Topic struct {
 	Description *string   `json:"description" db:"description"`
 	Keywords    []string  `json:"keywords" db:"keywords"`
 	Platforms   []string  `json:"platforms" db:"platforms"`
+	WorkspaceID string    `json:"workspace_id" db:"workspace_id"`
 	UserID      string    `json:"user_id" db:"user_id"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
@@ -35,6 +42,7 @@ type MediaAccount struct {
 	AccessToken  *string    `json:"-" db:"access_token"`
 	RefreshToken *string    `json:"-" db:"refresh_token"`
 	ExpiresAt    *time.Time `json:"expires_at" db:"expires_at"`
+	WorkspaceID  string     `json:"workspace_id" db:"workspace_id"`
 	UserID       string     `json:"user_id" db:"user_id"`
 	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
@@ -50,11 +58,32 @@ type Article struct {
 	AuthorID    *string   `json:"author_id" db:"author_id"`
 	PublishedAt time.Time `json:"published_at" db:"published_at"`
 	TopicID     string    `json:"topic_id" db:"topic_id"`
+	WorkspaceID string    `json:"workspace_id" db:"workspace_id"`
 	UserID      string    `json:"user_id" db:"user_id"`
+	State          string     `json:"state" db:"state"`
+	StateChangedAt *time.Time `json:"state_changed_at" db:"state_changed_at"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// Article triage states. Fetched articles start as ArticleStateNew and are
+// moved between states by the owner from the inbox.
+const (
+	ArticleStateNew      = "new"
+	ArticleStateApproved = "approved"
+	ArticleStateRejected = "rejected"
+	ArticleStateArchived = "archived"
+)
+
+// ArticleStates maps the bulk actions accepted by the API to the state they
+// move an article into.
+var ArticleStates = map[string]string{
+	"restore": ArticleStateNew,
+	"approve": ArticleStateApproved,
+	"reject":  ArticleStateRejected,
+	"archive": ArticleStateArchived,
+}
+
 type Repost struct {
 	ID             string     `json:"id" db:"id"`
 	ArticleID      string     `json:"article_id" db:"article_id"`
@@ -65,11 +94,102 @@ type Repost struct {
 	ScheduledAt    *time.Time `json:"scheduled_at" db:"scheduled_at"`
 	PostedAt       *time.Time `json:"posted_at" db:"posted_at"`
 	ExternalID     *string    `json:"external_id" db:"external_id"`
+	ApprovalStatus string     `json:"approval_status" db:"approval_status"`
+	ApprovedBy     *string    `json:"approved_by" db:"approved_by"`
+	ApprovedAt     *time.Time `json:"approved_at" db:"approved_at"`
+	WorkspaceID    string     `json:"workspace_id" db:"workspace_id"`
 	UserID         string     `json:"user_id" db:"user_id"`
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+// Repost approval states. Reposts that match no approval policy are created
+// as ApprovalNotRequired and are eligible for publishing immediately.
+const (
+	ApprovalNotRequired = "not_required"
+	ApprovalPending     = "pending"
+	ApprovalApproved    = "approved"
+	ApprovalRejected    = "rejected"
+)
+
+type ApprovalPolicy struct {
+	ID             string    `json:"id" db:"id"`
+	MediaAccountID *string   `json:"media_account_id" db:"media_account_id"`
+	TopicID        *string   `json:"topic_id" db:"topic_id"`
+	ApproverIDs    []string  `json:"approver_ids" db:"approver_ids"`
+	AllowAdmins    bool      `json:"allow_admins" db:"allow_admins"`
+	UserID         string    `json:"user_id" db:"user_id"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type RepostApproval struct {
+	ID         string    `json:"id" db:"id"`
+	RepostID   string    `json:"repost_id" db:"repost_id"`
+	ReviewerID string    `json:"reviewer_id" db:"reviewer_id"`
+	Decision   string    `json:"decision" db:"decision"`
+	Comment    *string   `json:"comment" db:"comment"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+type Workspace struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Personal  bool      `json:"personal" db:"personal"`
+	OwnerID   string    `json:"owner_id" db:"owner_id"`
+	Role      string    `json:"role,omitempty" db:"-"` // the caller's role in the workspace
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type WorkspaceMember struct {
+	ID          string    `json:"id" db:"id"`
+	WorkspaceID string    `json:"workspace_id" db:"workspace_id"`
+	UserID      string    `json:"user_id" db:"user_id"`
+	Name        *string   `json:"name" db:"name"`
+	Email       string    `json:"email" db:"email"`
+	Role        string    `json:"role" db:"role"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type WorkspaceInvitation struct {
+	ID          string     `json:"id" db:"id"`
+	WorkspaceID string     `json:"workspace_id" db:"workspace_id"`
+	Email       string     `json:"email" db:"email"`
+	Role        string     `json:"role" db:"role"`
+	Token       string     `json:"token,omitempty" db:"-"` // only returned when the invitation is created
+	InvitedBy   string     `json:"invited_by" db:"invited_by"`
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at" db:"accepted_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+type Session struct {
+	ID          string    `json:"id" db:"id"`
+	UserID      string    `json:"user_id" db:"user_id"`
+	DeviceName  *string   `json:"device_name" db:"device_name"`
+	UserAgent   *string   `json:"user_agent" db:"user_agent"`
+	IPAddress   *string   `json:"ip_address" db:"ip_address"`
+	LoginMethod string    `json:"login_method" db:"login_method"`
+	Current     bool      `json:"current" db:"-"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" db:"last_seen_at"`
+	Expires     time.Time `json:"expires" db:"expires"`
+}
+
+// APIKey is a personal key for automation. The key itself is only returned
+// when it is created; Prefix identifies it afterwards.
+type APIKey struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
 type SystemSetting struct {
 	ID        string    `json:"id" db:"id"`
 	Key       string    `json:"key" db:"key"`
@@ -88,26 +208,147 @@ type Platform struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// AuditEvent records a security- or content-relevant action. Before and
+// After hold the fields of the resource the action changed.
+type AuditEvent struct {
+	ID           string                 `json:"id"`
+	Action       string                 `json:"action"`
+	ActorID      *string                `json:"actor_id"`
+	ActorEmail   *string                `json:"actor_email"`
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   string                 `json:"resource_id"`
+	Before       map[string]interface{} `json:"before,omitempty"`
+	After        map[string]interface{} `json:"after,omitempty"`
+	IPAddress    string                 `json:"ip_address"`
+	RequestID    string                 `json:"request_id"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// AuditFilter narrows an audit log query. Empty fields match everything;
+// From is inclusive and To exclusive.
+type AuditFilter struct {
+	Action       string     `form:"action"`
+	ActorID      string     `form:"actor_id"`
+	ResourceType string     `form:"resource_type"`
+	ResourceID   string     `form:"resource_id"`
+	From         *time.Time `form:"from"`
+	To           *time.Time `form:"to"`
+}
+
 // Request/Response models
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"`
 }
 
 type RegisterRequest struct {
-	Name     string `json:"name" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
+	Name       string `json:"name" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required,min=6"`
+	DeviceName string `json:"device_name"`
+}
+
+// ClientInfo describes the device a login comes from.
+type ClientInfo struct {
+	DeviceName string
+	UserAgent  string
+	IPAddress  string
+	RequestID  string
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email  string `json:"email" binding:"required,email"`
+	Locale string `json:"locale"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
 	Password string `json:"password" binding:"required,min=6"`
 }
 
-type QRCodeRequest struct {
-	UserID string `json:"user_id" binding:"required"`
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
 }
 
-type QRCodeResponse struct {
-	Token   string `json:"token"`
-	QRCode  string `json:"qr_code"`
-	Expires int64  `json:"expires"`
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorStatus struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCode     string `json:"qr_code"` // PNG data URL of OTPAuthURI
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// ExpiresInDays defaults to 90.
+	ExpiresInDays int `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type QRLoginRequest struct {
+	DeviceName string `json:"device_name"`
+}
+
+// QRLoginSession is returned to the device that wants to sign in. It shows
+// QRCode and keeps PollToken to itself.
+type QRLoginSession struct {
+	ID        string `json:"id"`
+	PollToken string `json:"poll_token"`
+	QRCode    string `json:"qr_code"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+type QRLoginPollRequest struct {
+	PollToken string `json:"poll_token" binding:"required"`
+}
+
+// QRLoginStatus is what the requesting device sees. Auth is set exactly
+// once, on the poll that completes the login.
+type QRLoginStatus struct {
+	Status string        `json:"status"`
+	Auth   *AuthResponse `json:"auth,omitempty"`
+}
+
+// QRLoginDetails is shown on the approving device so the user can check
+// which device they are signing in.
+type QRLoginDetails struct {
+	ID         string    `json:"id"`
+	Status     string    `json:"status"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
 }
 
 type AuthResponse struct {
@@ -141,6 +382,50 @@ type RepostRequest struct {
 	ScheduledAt    *time.Time `json:"scheduled_at"`
 }
 
+type BulkArticleRequest struct {
+	IDs    []string `json:"ids" binding:"required,min=1,max=100"`
+	Action string   `json:"action" binding:"required,oneof=restore approve reject archive"`
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin editor viewer support"`
+}
+
+type RoleInfo struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type WorkspaceRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type WorkspaceMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin editor viewer"`
+}
+
+type InvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=admin editor viewer"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ApprovalPolicyRequest struct {
+	MediaAccountID *string  `json:"media_account_id"`
+	TopicID        *string  `json:"topic_id"`
+	ApproverIDs    []string `json:"approver_ids"`
+	// AllowAdmins is left unchanged by an update when omitted, and
+	// defaults to true on create.
+	AllowAdmins *bool `json:"allow_admins"`
+}
+
+type ReviewRepostRequest struct {
+	Comment *string `json:"comment"`
+}
+
 type StatsResponse struct {
 	TotalUsers     int64 `json:"total_users"`
 	ActiveUsers    int64 `json:"active_users"`
@@ -150,6 +435,26 @@ type StatsResponse struct {
 	RepostsToday   int64 `json:"reposts_today"`
 }
 
+type DailyCount struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+type NamedCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+type TopicStatsResponse struct {
+	TopicID         string           `json:"topic_id"`
+	Days            int              `json:"days"`
+	Since           time.Time        `json:"since"`
+	ArticlesPerDay  []DailyCount     `json:"articles_per_day"`
+	RepostsByStatus map[string]int64 `json:"reposts_by_status"`
+	TopSources      []NamedCount     `json:"top_sources"`
+	TopAuthors      []NamedCount     `json:"top_authors"`
+}
+
 type PaginatedResponse struct {
 	Data       interface{} `json:"data"`
 	Page       int         `json:"page"`
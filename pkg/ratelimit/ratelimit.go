@@ -0,0 +1,107 @@
+// Package ratelimit implements sliding-window rate limits in Redis, so the
+// limits hold across every API instance.
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limit allows Requests per Window for each key.
+type Limit struct {
+	// Name separates the counters of different limits on the same key.
+	Name     string
+	Requests int
+	Window   time.Duration
+}
+
+// Result is the outcome of a rate limit check.
+type Result struct {
+	Allowed bool
+	// RetryAfter is how long until the next request would be allowed. It is
+	// zero when Allowed is true.
+	RetryAfter time.Duration
+}
+
+// slidingWindow keeps one sorted-set member per allowed request, scored by
+// its time in milliseconds. Requests older than the window are dropped
+// before counting; a denied request is not recorded, and the wait is until
+// the oldest request in the window expires.
+var slidingWindow = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+if redis.call('ZCARD', key) < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return 0
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return tonumber(oldest[2]) + window - now
+`)
+
+type Limiter struct {
+	redisClient *redis.Client
+}
+
+func New(redisClient *redis.Client) *Limiter {
+	return &Limiter{redisClient: redisClient}
+}
+
+// Allow records a request for key under limit if the limit has room for it.
+func (l *Limiter) Allow(ctx context.Context, limit Limit, key string) (Result, error) {
+	member, err := requestID()
+	if err != nil {
+		return Result{}, err
+	}
+
+	now := time.Now().UnixMilli()
+	wait, err := slidingWindow.Run(ctx, l.redisClient, []string{redisKey(limit, key)},
+		now, limit.Window.Milliseconds(), limit.Requests, fmt.Sprintf("%d-%s", now, member),
+	).Int64()
+	if err != nil {
+		return Result{}, err
+	}
+
+	if wait <= 0 {
+		return Result{Allowed: true}, nil
+	}
+	return Result{RetryAfter: time.Duration(wait) * time.Millisecond}, nil
+}
+
+// Reset forgets every request recorded for key under limit.
+func (l *Limiter) Reset(ctx context.Context, limit Limit, key string) error {
+	return l.redisClient.Del(ctx, redisKey(limit, key)).Err()
+}
+
+func redisKey(limit Limit, key string) string {
+	return fmt.Sprintf("ratelimit:%s:%s", limit.Name, key)
+}
+
+func requestID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RetryAfter formats d for a Retry-After header: whole seconds, rounded up
+// so clients never retry too early.
+func RetryAfter(d time.Duration) string {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
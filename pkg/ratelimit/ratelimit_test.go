@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"smg/pkg/redistest"
+)
+
+// window does what slidingWindow does on a server, with a sorted slice of
+// request times standing in for each sorted set. redistest does not run Lua,
+// so the tests cover the arguments Allow passes the script and how it reads
+// the reply.
+type window struct {
+	mu   sync.Mutex
+	sets map[string][]int64
+	// calls records the keys the script was run on.
+	calls []string
+}
+
+func (w *window) run(script string, keys, args []string) (interface{}, error) {
+	sum := sha1.Sum([]byte(script))
+	if hex.EncodeToString(sum[:]) != slidingWindow.Hash() {
+		return nil, fmt.Errorf("unexpected script")
+	}
+	if len(keys) != 1 || len(args) != 4 {
+		return nil, fmt.Errorf("got %d keys and %d arguments, want 1 and 4", len(keys), len(args))
+	}
+	var numbers [3]int64
+	for i := range numbers {
+		n, err := strconv.ParseInt(args[i], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		numbers[i] = n
+	}
+	now, size, limit := numbers[0], numbers[1], numbers[2]
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.calls = append(w.calls, keys[0])
+
+	// ZREMRANGEBYSCORE key -inf now-window
+	var kept []int64
+	for _, at := range w.sets[keys[0]] {
+		if at > now-size {
+			kept = append(kept, at)
+		}
+	}
+	if int64(len(kept)) < limit {
+		kept = append(kept, now)
+		sort.Slice(kept, func(i, j int) bool { return kept[i] < kept[j] })
+		w.sets[keys[0]] = kept
+		return int64(0), nil
+	}
+	w.sets[keys[0]] = kept
+	return kept[0] + size - now, nil
+}
+
+func newLimiter(t *testing.T) (*Limiter, *window) {
+	t.Helper()
+	w := &window{sets: map[string][]int64{}}
+	return New(redistest.NewWithScripts(t, w.run)), w
+}
+
+func allow(t *testing.T, limiter *Limiter, limit Limit, key string) Result {
+	t.Helper()
+	result, err := limiter.Allow(context.Background(), limit, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestAllow(t *testing.T) {
+	limiter, w := newLimiter(t)
+	limit := Limit{Name: "test", Requests: 3, Window: time.Minute}
+
+	start := time.Now()
+	for i := 1; i <= limit.Requests; i++ {
+		if result := allow(t, limiter, limit, "alice"); !result.Allowed || result.RetryAfter != 0 {
+			t.Fatalf("request %d: got %+v, want allowed", i, result)
+		}
+	}
+
+	// The next request waits until the first one leaves the window.
+	denied := allow(t, limiter, limit, "alice")
+	if denied.Allowed {
+		t.Fatal("request over the limit was allowed")
+	}
+	if max, min := limit.Window, limit.Window-time.Since(start)-time.Millisecond; denied.RetryAfter > max || denied.RetryAfter < min {
+		t.Fatalf("RetryAfter: got %v, want between %v and %v", denied.RetryAfter, min, max)
+	}
+
+	// Keys and limits are counted apart.
+	if result := allow(t, limiter, limit, "bob"); !result.Allowed {
+		t.Fatal("another key was limited")
+	}
+	if result := allow(t, limiter, Limit{Name: "other", Requests: 1, Window: time.Minute}, "alice"); !result.Allowed {
+		t.Fatal("another limit was limited")
+	}
+	if got, want := w.calls[len(w.calls)-1], "ratelimit:other:alice"; got != want {
+		t.Fatalf("key: got %s, want %s", got, want)
+	}
+}
+
+func TestAllowSlidesTheWindow(t *testing.T) {
+	limiter, _ := newLimiter(t)
+	limit := Limit{Name: "test", Requests: 2, Window: 100 * time.Millisecond}
+
+	allow(t, limiter, limit, "alice")
+	time.Sleep(60 * time.Millisecond)
+	allow(t, limiter, limit, "alice")
+
+	// The first request leaves the window about 40ms from now; denied
+	// requests do not count, so the wait does not grow.
+	var denied Result
+	for i := 0; i < 3; i++ {
+		if denied = allow(t, limiter, limit, "alice"); denied.Allowed {
+			t.Fatal("request over the limit was allowed")
+		}
+	}
+	if denied.RetryAfter <= 0 || denied.RetryAfter > 40*time.Millisecond {
+		t.Fatalf("RetryAfter: got %v, want at most 40ms", denied.RetryAfter)
+	}
+
+	time.Sleep(denied.RetryAfter + time.Millisecond)
+	if result := allow(t, limiter, limit, "alice"); !result.Allowed {
+		t.Fatalf("request after the oldest left the window: got %+v", result)
+	}
+	// The second request is still in the window.
+	if result := allow(t, limiter, limit, "alice"); result.Allowed {
+		t.Fatal("request over the limit was allowed")
+	}
+}
+
+func TestAllowFailsWithoutRedis(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { client.Close() })
+
+	if _, err := New(client).Allow(context.Background(), Limit{Name: "test", Requests: 1, Window: time.Second}, "alice"); err == nil {
+		t.Fatal("got no error")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		wait time.Duration
+		want string
+	}{
+		{0, "1"},
+		{time.Millisecond, "1"},
+		{time.Second, "1"},
+		{time.Second + time.Millisecond, "2"},
+		{90 * time.Second, "90"},
+	}
+	for _, test := range tests {
+		if got := RetryAfter(test.wait); got != test.want {
+			t.Errorf("RetryAfter(%v): got %s, want %s", test.wait, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package rbac defines the user roles and the named permissions each role
+// grants.
+package rbac
+
+import "sort"
+
+const (
+	RoleOwner   = "owner"
+	RoleAdmin   = "admin"
+	RoleEditor  = "editor"
+	RoleViewer  = "viewer"
+	RoleSupport = "support"
+)
+
+// DefaultRole is assigned to newly registered users.
+const DefaultRole = RoleEditor
+
+const (
+	ArticlesRead    = "articles:read"
+	ArticlesWrite   = "articles:write"
+	TopicsRead      = "topics:read"
+	TopicsWrite     = "topics:write"
+	MediaRead       = "media:read"
+	MediaWrite      = "media:write"
+	RepostsWrite    = "reposts:write"
+	UsersRead       = "users:read"
+	UsersWrite      = "users:write"
+	RolesManage     = "roles:manage"
+	SystemSettings  = "system:settings"
+	SystemStats     = "system:stats"
+	SystemPlatforms = "system:platforms"
+	SystemAudit     = "system:audit"
+)
+
+var contentRead = []string{ArticlesRead, TopicsRead, MediaRead}
+
+var contentWrite = []string{ArticlesWrite, TopicsWrite, MediaWrite, RepostsWrite}
+
+var administration = []string{
+	UsersRead, UsersWrite, RolesManage,
+	SystemSettings, SystemStats, SystemPlatforms, SystemAudit,
+}
+
+var rolePermissions = map[string][]string{
+	RoleOwner:   concat(contentRead, contentWrite, administration),
+	RoleAdmin:   concat(contentRead, contentWrite, administration),
+	RoleEditor:  concat(contentRead, contentWrite),
+	RoleViewer:  concat(contentRead),
+	RoleSupport: concat(contentRead, []string{UsersRead, SystemStats}),
+}
+
+// Roles returns every known role name in a stable order.
+func Roles() []string {
+	return []string{RoleOwner, RoleAdmin, RoleEditor, RoleViewer, RoleSupport}
+}
+
+// IsAdminRole reports whether role carries administrator rights. It is what
+// keeps User.IsAdmin in sync with the role.
+func IsAdminRole(role string) bool {
+	return role == RoleOwner || role == RoleAdmin
+}
+
+// Permissions returns the sorted permissions granted by role. Unknown roles
+// grant nothing.
+func Permissions(role string) []string {
+	permissions := append([]string(nil), rolePermissions[role]...)
+	sort.Strings(permissions)
+	return permissions
+}
+
+// Has reports whether permissions contains permission.
+func Has(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkspaceRoles returns the roles a member can hold inside a workspace.
+func WorkspaceRoles() []string {
+	return []string{RoleOwner, RoleAdmin, RoleEditor, RoleViewer}
+}
+
+// CanManageWorkspace reports whether a member with wsRole may rename the
+// workspace and manage its members and invitations.
+func CanManageWorkspace(wsRole string) bool {
+	return wsRole == RoleOwner || wsRole == RoleAdmin
+}
+
+// WorkspacePermissions narrows global, the permissions of the user's global
+// role, to a workspace where the user holds wsRole. Content permissions are
+// kept only if wsRole grants them too; administration permissions are not
+// affected.
+func WorkspacePermissions(global []string, wsRole string) []string {
+	content := concat(contentRead, contentWrite)
+
+	var permissions []string
+	for _, p := range global {
+		if Has(content, p) && !Has(rolePermissions[wsRole], p) {
+			continue
+		}
+		permissions = append(permissions, p)
+	}
+	return permissions
+}
+
+// Restrict keeps the permissions that are also in scopes.
+func Restrict(permissions, scopes []string) []string {
+	var restricted []string
+	for _, p := range permissions {
+		if Has(scopes, p) {
+			restricted = append(restricted, p)
+		}
+	}
+	return restricted
+}
+
+func concat(groups ...[]string) []string {
+	var all []string
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	return all
+}
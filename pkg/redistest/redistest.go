@@ -0,0 +1,297 @@
+// Package redistest provides a Redis client backed by an in-memory map, so
+// code that keeps state in Redis can be tested without a server.
+//
+//	client := redistest.New(t)
+//	service := services.NewSessionService(db, client)
+//
+// The client answers the string commands the services use: GET, SET (with
+// EX, PX and NX), SETNX, GETDEL, DEL, EXISTS, INCR, EXPIRE and PTTL. Keys
+// expire by the wall clock. Lua scripts are answered by the function given
+// to NewWithScripts. Any other command, and every pipeline, fails.
+package redistest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ScriptFunc answers a Lua script run with the given keys and arguments,
+// standing in for what the script would do on a server.
+type ScriptFunc func(script string, keys, args []string) (interface{}, error)
+
+// New returns a client serving commands from a fresh, empty keyspace. It is
+// closed when the test finishes.
+func New(t testing.TB) *redis.Client {
+	return NewWithScripts(t, nil)
+}
+
+// NewWithScripts is like New, but answers scripts with run. Its reply must
+// be a string, an int64, a []interface{} of those, or nil.
+func NewWithScripts(t testing.TB, run ScriptFunc) *redis.Client {
+	// Commands never reach the network; the address is never dialled.
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	client.AddHook(&keyspace{entries: make(map[string]entry), scripts: run})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+type entry struct {
+	value   string
+	expires time.Time
+}
+
+func (e entry) live(now time.Time) bool {
+	return e.expires.IsZero() || now.Before(e.expires)
+}
+
+type keyspace struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	scripts ScriptFunc
+}
+
+// replyError is an error reply, which go-redis tells apart from I/O errors.
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+
+func (replyError) RedisError() {}
+
+func (k *keyspace) DialHook(redis.DialHook) redis.DialHook {
+	return func(context.Context, string, string) (net.Conn, error) {
+		return nil, fmt.Errorf("redistest: no connections")
+	}
+}
+
+func (k *keyspace) ProcessHook(redis.ProcessHook) redis.ProcessHook {
+	return func(_ context.Context, cmd redis.Cmder) error {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+
+		if err := k.process(cmd, strings.ToLower(cmd.Name()), args(cmd)); err != nil {
+			cmd.SetErr(err)
+		}
+		return cmd.Err()
+	}
+}
+
+func (k *keyspace) ProcessPipelineHook(redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(_ context.Context, cmds []redis.Cmder) error {
+		err := fmt.Errorf("redistest: pipelines are not supported")
+		for _, cmd := range cmds {
+			cmd.SetErr(err)
+		}
+		return err
+	}
+}
+
+// get returns the live entry for key, dropping it if it has expired.
+func (k *keyspace) get(key string) (entry, bool) {
+	e, ok := k.entries[key]
+	if ok && !e.live(time.Now()) {
+		delete(k.entries, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+func (k *keyspace) process(cmd redis.Cmder, name string, args []string) error {
+	switch name {
+	case "get", "getdel":
+		if len(args) != 1 {
+			return arity(name)
+		}
+		e, ok := k.get(args[0])
+		if name == "getdel" {
+			delete(k.entries, args[0])
+		}
+		if !ok {
+			return redis.Nil
+		}
+		return setVal(cmd, e.value)
+
+	case "set":
+		if len(args) < 2 {
+			return arity(name)
+		}
+		e := entry{value: args[1]}
+		nx := false
+		for i := 2; i < len(args); i++ {
+			switch strings.ToLower(args[i]) {
+			case "nx":
+				nx = true
+			case "ex", "px":
+				if i+1 == len(args) {
+					return arity(name)
+				}
+				n, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil {
+					return err
+				}
+				unit := time.Second
+				if strings.ToLower(args[i]) == "px" {
+					unit = time.Millisecond
+				}
+				e.expires = time.Now().Add(time.Duration(n) * unit)
+				i++
+			default:
+				return fmt.Errorf("redistest: unsupported SET option %q", args[i])
+			}
+		}
+		if _, exists := k.get(args[0]); nx && exists {
+			return setVal(cmd, false)
+		}
+		k.entries[args[0]] = e
+		if nx {
+			return setVal(cmd, true)
+		}
+		return setVal(cmd, "OK")
+
+	case "setnx":
+		if len(args) != 2 {
+			return arity(name)
+		}
+		if _, exists := k.get(args[0]); exists {
+			return setVal(cmd, false)
+		}
+		k.entries[args[0]] = entry{value: args[1]}
+		return setVal(cmd, true)
+
+	case "del", "exists":
+		var n int64
+		for _, key := range args {
+			if _, ok := k.get(key); ok {
+				n++
+				if name == "del" {
+					delete(k.entries, key)
+				}
+			}
+		}
+		return setVal(cmd, n)
+
+	case "incr":
+		if len(args) != 1 {
+			return arity(name)
+		}
+		e, _ := k.get(args[0])
+		n := int64(0)
+		if e.value != "" {
+			var err error
+			if n, err = strconv.ParseInt(e.value, 10, 64); err != nil {
+				return fmt.Errorf("ERR value is not an integer or out of range")
+			}
+		}
+		n++
+		e.value = strconv.FormatInt(n, 10)
+		k.entries[args[0]] = e
+		return setVal(cmd, n)
+
+	case "expire":
+		if len(args) != 2 {
+			return arity(name)
+		}
+		seconds, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		e, ok := k.get(args[0])
+		if !ok {
+			return setVal(cmd, false)
+		}
+		e.expires = time.Now().Add(time.Duration(seconds) * time.Second)
+		k.entries[args[0]] = e
+		return setVal(cmd, true)
+
+	case "pttl":
+		if len(args) != 1 {
+			return arity(name)
+		}
+		// Redis answers -2 for a missing key and -1 for one without a TTL.
+		e, ok := k.get(args[0])
+		switch {
+		case !ok:
+			return setVal(cmd, time.Duration(-2))
+		case e.expires.IsZero():
+			return setVal(cmd, time.Duration(-1))
+		}
+		return setVal(cmd, time.Until(e.expires).Truncate(time.Millisecond))
+
+	case "evalsha":
+		// Scripts are only known by their source, so go-redis falls back to
+		// EVAL, which sends it.
+		return replyError("NOSCRIPT No matching script. Please use EVAL.")
+
+	case "eval":
+		if k.scripts == nil {
+			return fmt.Errorf("redistest: no script function; use NewWithScripts")
+		}
+		if len(args) < 2 {
+			return arity(name)
+		}
+		numKeys, err := strconv.Atoi(args[1])
+		if err != nil || numKeys < 0 || 2+numKeys > len(args) {
+			return fmt.Errorf("ERR Number of keys can't be greater than number of args")
+		}
+		reply, err := k.scripts(args[0], args[2:2+numKeys], args[2+numKeys:])
+		if err != nil {
+			return err
+		}
+		if reply == nil {
+			return redis.Nil
+		}
+		return setVal(cmd, reply)
+	}
+
+	return fmt.Errorf("redistest: unsupported command %s", strings.ToUpper(name))
+}
+
+// setVal stores a reply in cmd, which must be of the type go-redis uses for
+// the command.
+func setVal(cmd redis.Cmder, value interface{}) error {
+	switch cmd := cmd.(type) {
+	case *redis.StringCmd:
+		cmd.SetVal(value.(string))
+	case *redis.StatusCmd:
+		cmd.SetVal(value.(string))
+	case *redis.BoolCmd:
+		cmd.SetVal(value.(bool))
+	case *redis.IntCmd:
+		cmd.SetVal(value.(int64))
+	case *redis.DurationCmd:
+		cmd.SetVal(value.(time.Duration))
+	case *redis.Cmd:
+		cmd.SetVal(value)
+	default:
+		return fmt.Errorf("redistest: unexpected reply type %T for %s", cmd, cmd.Name())
+	}
+	return nil
+}
+
+// args returns the arguments of cmd after its name as strings.
+func args(cmd redis.Cmder) []string {
+	raw := cmd.Args()
+	list := make([]string, 0, len(raw))
+	for _, arg := range raw[1:] {
+		switch arg := arg.(type) {
+		case string:
+			list = append(list, arg)
+		case []byte:
+			list = append(list, string(arg))
+		default:
+			list = append(list, fmt.Sprint(arg))
+		}
+	}
+	return list
+}
+
+func arity(name string) error {
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", name)
+}
@@ -0,0 +1,81 @@
+package redistest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	client := New(t)
+
+	if err := client.Get(ctx, "missing").Err(); !errors.Is(err, redis.Nil) {
+		t.Fatalf("GET of a missing key: got %v, want redis.Nil", err)
+	}
+	if err := client.Set(ctx, "key", "value", time.Minute).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := client.Get(ctx, "key").Result(); err != nil || value != "value" {
+		t.Fatalf("GET: got %q, %v", value, err)
+	}
+	if ttl, err := client.PTTL(ctx, "key").Result(); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("PTTL: got %v, %v", ttl, err)
+	}
+
+	if set, err := client.SetNX(ctx, "key", "other", time.Minute).Result(); err != nil || set {
+		t.Fatalf("SETNX of an existing key: got %v, %v", set, err)
+	}
+	if set, err := client.SetNX(ctx, "fresh", "1", 0).Result(); err != nil || !set {
+		t.Fatalf("SETNX of a new key: got %v, %v", set, err)
+	}
+	if n, err := client.Incr(ctx, "fresh").Result(); err != nil || n != 2 {
+		t.Fatalf("INCR: got %d, %v", n, err)
+	}
+	if n, err := client.Exists(ctx, "key", "fresh", "missing").Result(); err != nil || n != 2 {
+		t.Fatalf("EXISTS: got %d, %v", n, err)
+	}
+
+	if value, err := client.GetDel(ctx, "key").Result(); err != nil || value != "value" {
+		t.Fatalf("GETDEL: got %q, %v", value, err)
+	}
+	if n, err := client.Del(ctx, "key", "fresh").Result(); err != nil || n != 1 {
+		t.Fatalf("DEL: got %d, %v", n, err)
+	}
+
+	if err := client.Set(ctx, "short", "1", time.Millisecond).Err(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if n, err := client.Exists(ctx, "short").Result(); err != nil || n != 0 {
+		t.Fatalf("EXISTS after expiry: got %d, %v", n, err)
+	}
+
+	if err := client.HSet(ctx, "hash", "field", "value").Err(); err == nil {
+		t.Fatal("unsupported command succeeded")
+	}
+}
+
+func TestScripts(t *testing.T) {
+	ctx := context.Background()
+	script := redis.NewScript(`return ARGV[1] + #KEYS`)
+
+	if err := script.Run(ctx, New(t), []string{"key"}, 1).Err(); err == nil {
+		t.Fatal("script without a script function succeeded")
+	}
+
+	client := NewWithScripts(t, func(source string, keys, args []string) (interface{}, error) {
+		if source != `return ARGV[1] + #KEYS` || len(keys) != 1 || keys[0] != "key" || len(args) != 1 {
+			return nil, errors.New("unexpected script call")
+		}
+		n, err := strconv.ParseInt(args[0], 10, 64)
+		return n + int64(len(keys)), err
+	})
+	if n, err := script.Run(ctx, client, []string{"key"}, 1).Int64(); err != nil || n != 2 {
+		t.Fatalf("script: got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type apiKeys struct {
+	s *Store
+}
+
+// apiKey is a stored key with the hash of its secret.
+type apiKey struct {
+	models.APIKey
+	hash string
+}
+
+func (r apiKeys) List(userID string) ([]models.APIKey, error) {
+	var list []models.APIKey
+	r.s.do(func(d *data) error {
+		for _, key := range d.apiKeys {
+			if key.UserID == userID {
+				list = append(list, copyAPIKey(key.APIKey))
+			}
+		}
+		return nil
+	})
+
+	newestFirst(list, func(key models.APIKey) (time.Time, string) { return key.CreatedAt, key.ID })
+	return list, nil
+}
+
+func (r apiKeys) GetByHash(hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.s.do(func(d *data) error {
+		for _, row := range d.apiKeys {
+			if row.hash == hash {
+				key = copyAPIKey(row.APIKey)
+				return nil
+			}
+		}
+		return sql.ErrNoRows
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r apiKeys) Create(key *models.APIKey, hash string) error {
+	row := apiKey{
+		APIKey: models.APIKey{
+			ID:        key.ID,
+			UserID:    key.UserID,
+			Name:      key.Name,
+			Prefix:    key.Prefix,
+			Scopes:    copyStrings(key.Scopes),
+			ExpiresAt: stamp(key.ExpiresAt),
+			CreatedAt: stamp(key.CreatedAt),
+		},
+		hash: hash,
+	}
+
+	return r.s.do(func(d *data) error {
+		for id, existing := range d.apiKeys {
+			if id == row.ID || existing.hash == row.hash {
+				return repository.ErrDuplicate
+			}
+		}
+		d.apiKeys[row.ID] = row
+		return nil
+	})
+}
+
+func (r apiKeys) Delete(userID, id string) error {
+	return r.s.do(func(d *data) error {
+		key, ok := d.apiKeys[id]
+		if !ok || key.UserID != userID {
+			return sql.ErrNoRows
+		}
+		delete(d.apiKeys, id)
+		return nil
+	})
+}
+
+func (r apiKeys) Touch(id string, at time.Time) error {
+	return r.s.do(func(d *data) error {
+		key, ok := d.apiKeys[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		key.LastUsedAt = stampPtr(&at)
+		d.apiKeys[id] = key
+		return nil
+	})
+}
+
+func copyAPIKey(key models.APIKey) models.APIKey {
+	key.Scopes = copyStrings(key.Scopes)
+	return key
+}
@@ -0,0 +1,202 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type approvals struct {
+	s *Store
+}
+
+func (r approvals) Policies(userID string) ([]models.ApprovalPolicy, error) {
+	var list []models.ApprovalPolicy
+	r.s.do(func(d *data) error {
+		for _, policy := range d.policies {
+			if policy.UserID == userID {
+				list = append(list, copyPolicy(policy))
+			}
+		}
+		return nil
+	})
+
+	newestFirst(list, func(policy models.ApprovalPolicy) (time.Time, string) { return policy.CreatedAt, policy.ID })
+	return list, nil
+}
+
+func (r approvals) Policy(ownerID, id string) (*models.ApprovalPolicy, error) {
+	var policy models.ApprovalPolicy
+	err := r.s.do(func(d *data) error {
+		row, ok := d.policies[id]
+		if !ok || !ownedBy(row, ownerID) {
+			return sql.ErrNoRows
+		}
+		policy = copyPolicy(row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r approvals) CreatePolicy(policy *models.ApprovalPolicy) error {
+	row := copyPolicy(*policy)
+	row.CreatedAt = stamp(policy.CreatedAt)
+	row.UpdatedAt = stamp(policy.UpdatedAt)
+
+	return r.s.do(func(d *data) error {
+		if _, ok := d.policies[row.ID]; ok {
+			return repository.ErrDuplicate
+		}
+		d.policies[row.ID] = row
+		return nil
+	})
+}
+
+func (r approvals) UpdatePolicy(ownerID string, policy *models.ApprovalPolicy) error {
+	return r.s.do(func(d *data) error {
+		row, ok := d.policies[policy.ID]
+		if !ok || !ownedBy(row, ownerID) {
+			return sql.ErrNoRows
+		}
+		row.MediaAccountID = policy.MediaAccountID
+		row.TopicID = policy.TopicID
+		row.ApproverIDs = copyStrings(policy.ApproverIDs)
+		row.AllowAdmins = policy.AllowAdmins
+		row.UpdatedAt = stamp(policy.UpdatedAt)
+		d.policies[row.ID] = row
+		return nil
+	})
+}
+
+func (r approvals) DeletePolicy(ownerID, id string) error {
+	return r.s.do(func(d *data) error {
+		row, ok := d.policies[id]
+		if !ok || !ownedBy(row, ownerID) {
+			return sql.ErrNoRows
+		}
+		delete(d.policies, id)
+		return nil
+	})
+}
+
+func (r approvals) Covered(articleID, mediaAccountID string) (bool, error) {
+	var covered bool
+	r.s.do(func(d *data) error {
+		article, ok := d.articles[articleID]
+		for _, policy := range d.policies {
+			if equals(policy.MediaAccountID, mediaAccountID) || ok && equals(policy.TopicID, article.TopicID) {
+				covered = true
+			}
+		}
+		return nil
+	})
+	return covered, nil
+}
+
+func (r approvals) CanReview(repostID, reviewerID string, admin bool) (bool, error) {
+	var allowed bool
+	r.s.do(func(d *data) error {
+		repost, ok := d.reposts[repostID]
+		allowed = ok && d.reviewable(repost, reviewerID, admin)
+		return nil
+	})
+	return allowed, nil
+}
+
+func (r approvals) Pending(reviewerID string, admin bool) ([]models.Repost, error) {
+	var list []models.Repost
+	r.s.do(func(d *data) error {
+		for _, repost := range d.reposts {
+			if repost.ApprovalStatus == models.ApprovalPending && d.reviewable(repost, reviewerID, admin) {
+				list = append(list, repost)
+			}
+		}
+		return nil
+	})
+
+	oldestFirst(list, func(repost models.Repost) (time.Time, string) { return repost.CreatedAt, repost.ID })
+	return list, nil
+}
+
+func (r approvals) Review(decision *models.RepostApproval, status string) error {
+	row := *decision
+	row.CreatedAt = stamp(decision.CreatedAt)
+
+	return r.s.do(func(d *data) error {
+		repost, ok := d.reposts[row.RepostID]
+		if !ok || repost.ApprovalStatus != models.ApprovalPending {
+			return sql.ErrNoRows
+		}
+		if _, ok := d.decisions[row.ID]; ok {
+			return repository.ErrDuplicate
+		}
+
+		reviewerID, reviewedAt := row.ReviewerID, row.CreatedAt
+		repost.ApprovalStatus = row.Decision
+		repost.ApprovedBy = &reviewerID
+		repost.ApprovedAt = &reviewedAt
+		repost.Status = status
+		repost.UpdatedAt = row.CreatedAt
+		d.reposts[repost.ID] = repost
+		d.decisions[row.ID] = row
+		return nil
+	})
+}
+
+func (r approvals) Decisions(repostID string) ([]models.RepostApproval, error) {
+	var list []models.RepostApproval
+	r.s.do(func(d *data) error {
+		for _, decision := range d.decisions {
+			if decision.RepostID == repostID {
+				list = append(list, decision)
+			}
+		}
+		return nil
+	})
+
+	oldestFirst(list, func(decision models.RepostApproval) (time.Time, string) { return decision.CreatedAt, decision.ID })
+	return list, nil
+}
+
+// reviewable reports whether a policy covering the repost lists the
+// reviewer as an approver, or accepts admins and admin is true.
+func (d *data) reviewable(repost models.Repost, reviewerID string, admin bool) bool {
+	article, ok := d.articles[repost.ArticleID]
+	if !ok {
+		return false
+	}
+	for _, policy := range d.policies {
+		if !equals(policy.MediaAccountID, repost.MediaAccountID) && !equals(policy.TopicID, article.TopicID) {
+			continue
+		}
+		if policy.AllowAdmins && admin {
+			return true
+		}
+		for _, approverID := range policy.ApproverIDs {
+			if approverID == reviewerID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ownedBy reports whether the policy belongs to ownerID, which matches
+// every policy when empty.
+func ownedBy(policy models.ApprovalPolicy, ownerID string) bool {
+	return ownerID == "" || policy.UserID == ownerID
+}
+
+func equals(value *string, to string) bool {
+	return value != nil && *value == to
+}
+
+func copyPolicy(policy models.ApprovalPolicy) models.ApprovalPolicy {
+	policy.ApproverIDs = copyStrings(policy.ApproverIDs)
+	return policy
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type articles struct {
+	s *Store
+}
+
+func (r articles) List(workspaceID string, filter repository.ArticleFilter, page repository.Page) ([]models.Article, int64, error) {
+	var list []models.Article
+	r.s.do(func(d *data) error {
+		for _, article := range d.articles {
+			if article.WorkspaceID == workspaceID &&
+				(filter.TopicID == "" || article.TopicID == filter.TopicID) &&
+				(filter.State == "" || article.State == filter.State) {
+				list = append(list, article)
+			}
+		}
+		return nil
+	})
+
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].PublishedAt.Equal(list[j].PublishedAt) {
+			return list[i].PublishedAt.After(list[j].PublishedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	list, total := paginate(list, page)
+	return list, total, nil
+}
+
+func (r articles) Get(workspaceID, id string) (*models.Article, error) {
+	var article models.Article
+	err := r.s.do(func(d *data) error {
+		row, ok := d.articles[id]
+		if !ok || row.WorkspaceID != workspaceID {
+			return sql.ErrNoRows
+		}
+		article = row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+func (r articles) Create(article *models.Article) error {
+	row := *article
+	if row.State == "" {
+		row.State = models.ArticleStateNew
+	}
+	row.PublishedAt = stamp(row.PublishedAt)
+	row.StateChangedAt = stampPtr(row.StateChangedAt)
+	row.CreatedAt = stamp(row.CreatedAt)
+	row.UpdatedAt = stamp(row.UpdatedAt)
+
+	return r.s.do(func(d *data) error {
+		if _, ok := d.articles[row.ID]; ok {
+			return repository.ErrDuplicate
+		}
+		d.articles[row.ID] = row
+		return nil
+	})
+}
+
+func (r articles) Update(article *models.Article) error {
+	return r.s.do(func(d *data) error {
+		row, ok := d.articles[article.ID]
+		if !ok || row.WorkspaceID != article.WorkspaceID {
+			return sql.ErrNoRows
+		}
+		row.Title = article.Title
+		row.Content = article.Content
+		row.UpdatedAt = stamp(article.UpdatedAt)
+		d.articles[row.ID] = row
+		return nil
+	})
+}
+
+func (r articles) Delete(workspaceID, id string) error {
+	return r.s.do(func(d *data) error {
+		row, ok := d.articles[id]
+		if !ok || row.WorkspaceID != workspaceID {
+			return sql.ErrNoRows
+		}
+		d.deleteArticle(id)
+		return nil
+	})
+}
+
+func (r articles) SetState(workspaceID string, ids []string, state string, at time.Time) (int64, error) {
+	at = stamp(at)
+
+	var changed int64
+	r.s.do(func(d *data) error {
+		for _, id := range ids {
+			row, ok := d.articles[id]
+			if !ok || row.WorkspaceID != workspaceID || row.State == state {
+				continue
+			}
+			row.State = state
+			row.StateChangedAt = &at
+			row.UpdatedAt = at
+			d.articles[id] = row
+			changed++
+		}
+		return nil
+	})
+	return changed, nil
+}
+
+func (r articles) Count(filter repository.CountFilter) (int64, error) {
+	var total int64
+	r.s.do(func(d *data) error {
+		for _, article := range d.articles {
+			if counted(filter, article.UserID, article.CreatedAt) {
+				total++
+			}
+		}
+		return nil
+	})
+	return total, nil
+}
@@ -0,0 +1,22 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"smg/pkg/models"
+)
+
+type auditLog struct {
+	s *Store
+}
+
+func (r auditLog) Record(event models.AuditEvent) error {
+	event.ID = uuid.New().String()
+	event.CreatedAt = stamp(time.Now())
+
+	return r.s.do(func(d *data) error {
+		d.audit = append(d.audit, event)
+		return nil
+	})
+}
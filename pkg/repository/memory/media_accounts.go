@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type mediaAccounts struct {
+	s *Store
+}
+
+func (r mediaAccounts) List(workspaceID, platform string) ([]models.MediaAccount, error) {
+	var list []models.MediaAccount
+	r.s.do(func(d *data) error {
+		for _, account := range d.mediaAccounts {
+			if account.WorkspaceID == workspaceID && (platform == "" || account.Platform == platform) {
+				list = append(list, withoutTokens(account))
+			}
+		}
+		return nil
+	})
+
+	newestFirst(list, func(account models.MediaAccount) (time.Time, string) { return account.CreatedAt, account.ID })
+	return list, nil
+}
+
+func (r mediaAccounts) Get(workspaceID, id string) (*models.MediaAccount, error) {
+	var account models.MediaAccount
+	err := r.s.do(func(d *data) error {
+		row, ok := d.mediaAccounts[id]
+		if !ok || row.WorkspaceID != workspaceID {
+			return sql.ErrNoRows
+		}
+		account = withoutTokens(row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r mediaAccounts) Create(account *models.MediaAccount) error {
+	row := *account
+	row.ExpiresAt = stampPtr(row.ExpiresAt)
+	row.CreatedAt = stamp(row.CreatedAt)
+	row.UpdatedAt = stamp(row.UpdatedAt)
+
+	return r.s.do(func(d *data) error {
+		if _, ok := d.mediaAccounts[row.ID]; ok {
+			return repository.ErrDuplicate
+		}
+		for _, existing := range d.mediaAccounts {
+			if existing.Platform == row.Platform && existing.AccountID == row.AccountID && existing.UserID == row.UserID {
+				return repository.ErrDuplicate
+			}
+		}
+		d.mediaAccounts[row.ID] = row
+		return nil
+	})
+}
+
+func (r mediaAccounts) Update(account *models.MediaAccount) error {
+	return r.s.do(func(d *data) error {
+		row, ok := d.mediaAccounts[account.ID]
+		if !ok || row.WorkspaceID != account.WorkspaceID {
+			return sql.ErrNoRows
+		}
+		row.AccountName = account.AccountName
+		row.UpdatedAt = stamp(account.UpdatedAt)
+		d.mediaAccounts[row.ID] = row
+		return nil
+	})
+}
+
+func (r mediaAccounts) Delete(workspaceID, id string) error {
+	return r.s.do(func(d *data) error {
+		row, ok := d.mediaAccounts[id]
+		if !ok || row.WorkspaceID != workspaceID {
+			return sql.ErrNoRows
+		}
+		d.deleteMediaAccount(id)
+		return nil
+	})
+}
+
+func (r mediaAccounts) Count(filter repository.CountFilter) (int64, error) {
+	var total int64
+	r.s.do(func(d *data) error {
+		for _, account := range d.mediaAccounts {
+			if counted(filter, account.UserID, account.CreatedAt) {
+				total++
+			}
+		}
+		return nil
+	})
+	return total, nil
+}
+
+// withoutTokens returns the account as the repositories read it back,
+// without its tokens.
+func withoutTokens(account models.MediaAccount) models.MediaAccount {
+	account.AccessToken = nil
+	account.RefreshToken = nil
+	return account
+}
@@ -0,0 +1,314 @@
+// Package memory implements the repositories in memory, for tests of the
+// services and handlers that use them. Times are stored like the database
+// stores them: as wall clock times rounded to the millisecond. References
+// between rows are not checked, but deletes cascade like the foreign keys
+// of the schema.
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+// Store is a repository.Store in memory. The zero value is not usable;
+// call New.
+type Store struct {
+	mu *sync.Mutex
+	// data is the committed state, guarded by mu.
+	data *data
+	// tx is the working copy of a transaction, which already holds mu.
+	tx *data
+}
+
+type data struct {
+	users         map[string]models.User
+	topics        map[string]models.Topic
+	mediaAccounts map[string]models.MediaAccount
+	articles      map[string]models.Article
+	reposts       map[string]models.Repost
+	settings      map[string]string
+	platforms     map[string]models.Platform
+	apiKeys       map[string]apiKey
+	policies      map[string]models.ApprovalPolicy
+	decisions     map[string]models.RepostApproval
+	audit         []models.AuditEvent
+}
+
+// New returns an empty store.
+func New() *Store {
+	return &Store{
+		mu: &sync.Mutex{},
+		data: &data{
+			users:         make(map[string]models.User),
+			topics:        make(map[string]models.Topic),
+			mediaAccounts: make(map[string]models.MediaAccount),
+			articles:      make(map[string]models.Article),
+			reposts:       make(map[string]models.Repost),
+			settings:      make(map[string]string),
+			platforms:     make(map[string]models.Platform),
+			apiKeys:       make(map[string]apiKey),
+			policies:      make(map[string]models.ApprovalPolicy),
+			decisions:     make(map[string]models.RepostApproval),
+		},
+	}
+}
+
+var _ repository.Store = (*Store)(nil)
+
+func (s *Store) Users() repository.UserRepository                 { return users{s} }
+func (s *Store) Topics() repository.TopicRepository               { return topics{s} }
+func (s *Store) MediaAccounts() repository.MediaAccountRepository { return mediaAccounts{s} }
+func (s *Store) Articles() repository.ArticleRepository           { return articles{s} }
+func (s *Store) Reposts() repository.RepostRepository             { return reposts{s} }
+func (s *Store) Settings() repository.SettingsRepository          { return settings{s} }
+func (s *Store) Platforms() repository.PlatformRepository         { return platforms{s} }
+func (s *Store) APIKeys() repository.APIKeyRepository             { return apiKeys{s} }
+func (s *Store) Approvals() repository.ApprovalRepository         { return approvals{s} }
+func (s *Store) Audit() repository.AuditLog                       { return auditLog{s} }
+
+// InTx runs fn on a copy of the data and keeps the copy if fn returns nil.
+// Other callers wait until the transaction ends.
+func (s *Store) InTx(fn func(repository.Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.data.clone()
+	if err := fn(&Store{mu: s.mu, data: s.data, tx: tx}); err != nil {
+		return err
+	}
+
+	*s.data = *tx
+	return nil
+}
+
+// AuditEvents returns the recorded audit events, oldest first.
+func (s *Store) AuditEvents() []models.AuditEvent {
+	var events []models.AuditEvent
+	s.do(func(d *data) error {
+		events = append(events, d.audit...)
+		return nil
+	})
+	return events
+}
+
+// do runs fn on the data, locking it unless s is in a transaction.
+func (s *Store) do(fn func(d *data) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.data)
+}
+
+func (d *data) clone() *data {
+	c := &data{
+		users:         make(map[string]models.User, len(d.users)),
+		topics:        make(map[string]models.Topic, len(d.topics)),
+		mediaAccounts: make(map[string]models.MediaAccount, len(d.mediaAccounts)),
+		articles:      make(map[string]models.Article, len(d.articles)),
+		reposts:       make(map[string]models.Repost, len(d.reposts)),
+		settings:      make(map[string]string, len(d.settings)),
+		platforms:     make(map[string]models.Platform, len(d.platforms)),
+		apiKeys:       make(map[string]apiKey, len(d.apiKeys)),
+		policies:      make(map[string]models.ApprovalPolicy, len(d.policies)),
+		decisions:     make(map[string]models.RepostApproval, len(d.decisions)),
+		audit:         append([]models.AuditEvent(nil), d.audit...),
+	}
+	for id, row := range d.users {
+		c.users[id] = row
+	}
+	for id, row := range d.topics {
+		c.topics[id] = row
+	}
+	for id, row := range d.mediaAccounts {
+		c.mediaAccounts[id] = row
+	}
+	for id, row := range d.articles {
+		c.articles[id] = row
+	}
+	for id, row := range d.reposts {
+		c.reposts[id] = row
+	}
+	for key, value := range d.settings {
+		c.settings[key] = value
+	}
+	for id, row := range d.platforms {
+		c.platforms[id] = row
+	}
+	for id, row := range d.apiKeys {
+		c.apiKeys[id] = row
+	}
+	for id, row := range d.policies {
+		c.policies[id] = row
+	}
+	for id, row := range d.decisions {
+		c.decisions[id] = row
+	}
+	return c
+}
+
+// deleteUser removes the user's rows from the content tables, their API
+// keys and approval policies, and their decisions on reposts.
+func (d *data) deleteUser(id string) {
+	for topicID, topic := range d.topics {
+		if topic.UserID == id {
+			d.deleteTopic(topicID)
+		}
+	}
+	for accountID, account := range d.mediaAccounts {
+		if account.UserID == id {
+			d.deleteMediaAccount(accountID)
+		}
+	}
+	for articleID, article := range d.articles {
+		if article.UserID == id {
+			d.deleteArticle(articleID)
+		}
+	}
+	for repostID, repost := range d.reposts {
+		if repost.UserID == id {
+			d.deleteRepost(repostID)
+		}
+	}
+	for keyID, key := range d.apiKeys {
+		if key.UserID == id {
+			delete(d.apiKeys, keyID)
+		}
+	}
+	for policyID, policy := range d.policies {
+		if policy.UserID == id {
+			delete(d.policies, policyID)
+		}
+	}
+	for decisionID, decision := range d.decisions {
+		if decision.ReviewerID == id {
+			delete(d.decisions, decisionID)
+		}
+	}
+	delete(d.users, id)
+}
+
+func (d *data) deleteTopic(id string) {
+	for articleID, article := range d.articles {
+		if article.TopicID == id {
+			d.deleteArticle(articleID)
+		}
+	}
+	for policyID, policy := range d.policies {
+		if policy.TopicID != nil && *policy.TopicID == id {
+			delete(d.policies, policyID)
+		}
+	}
+	delete(d.topics, id)
+}
+
+func (d *data) deleteMediaAccount(id string) {
+	for repostID, repost := range d.reposts {
+		if repost.MediaAccountID == id {
+			d.deleteRepost(repostID)
+		}
+	}
+	for policyID, policy := range d.policies {
+		if policy.MediaAccountID != nil && *policy.MediaAccountID == id {
+			delete(d.policies, policyID)
+		}
+	}
+	delete(d.mediaAccounts, id)
+}
+
+func (d *data) deleteArticle(id string) {
+	for repostID, repost := range d.reposts {
+		if repost.ArticleID == id {
+			d.deleteRepost(repostID)
+		}
+	}
+	delete(d.articles, id)
+}
+
+func (d *data) deleteRepost(id string) {
+	for decisionID, decision := range d.decisions {
+		if decision.RepostID == id {
+			delete(d.decisions, decisionID)
+		}
+	}
+	delete(d.reposts, id)
+}
+
+// stamp returns t as the database returns a stored timestamp: its wall
+// clock time in UTC, rounded to the millisecond.
+func stamp(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).
+		Round(time.Millisecond)
+}
+
+func stampPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	stamped := stamp(*t)
+	return &stamped
+}
+
+// since reports whether t is at or after the filter time, which is zero
+// when it does not filter.
+func since(t, filter time.Time) bool {
+	return filter.IsZero() || !t.Before(stamp(filter))
+}
+
+func counted(filter repository.CountFilter, userID string, createdAt time.Time) bool {
+	return (filter.UserID == "" || userID == filter.UserID) && since(createdAt, filter.CreatedSince)
+}
+
+// newestFirst sorts rows by creation time, newest first, and then by ID.
+func newestFirst[T any](rows []T, key func(T) (time.Time, string)) {
+	sort.Slice(rows, func(i, j int) bool {
+		ti, idi := key(rows[i])
+		tj, idj := key(rows[j])
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return idi < idj
+	})
+}
+
+// oldestFirst sorts rows by creation time, oldest first, and then by ID.
+func oldestFirst[T any](rows []T, key func(T) (time.Time, string)) {
+	sort.Slice(rows, func(i, j int) bool {
+		ti, idi := key(rows[i])
+		tj, idj := key(rows[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return idi < idj
+	})
+}
+
+// paginate returns the page of rows and their total.
+func paginate[T any](rows []T, page repository.Page) ([]T, int64) {
+	total := int64(len(rows))
+	if page.Offset >= len(rows) {
+		return nil, total
+	}
+	rows = rows[page.Offset:]
+	if page.Limit < len(rows) {
+		rows = rows[:page.Limit]
+	}
+	if len(rows) == 0 {
+		return nil, total
+	}
+	return rows, total
+}
+
+func copyStrings(values []string) []string {
+	return append([]string{}, values...)
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"smg/pkg/repository"
+	"smg/pkg/repository/repotest"
+)
+
+func TestContract(t *testing.T) {
+	repotest.Run(t, repotest.Harness{
+		New: func(t *testing.T) repository.Store { return New() },
+	})
+}
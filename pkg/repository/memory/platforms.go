@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"database/sql"
+	"sort"
+
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type platforms struct {
+	s *Store
+}
+
+func (r platforms) List() ([]models.Platform, error) {
+	var list []models.Platform
+	r.s.do(func(d *data) error {
+		for _, platform := range d.platforms {
+			list = append(list, platform)
+		}
+		return nil
+	})
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].DisplayName != list[j].DisplayName {
+			return list[i].DisplayName < list[j].DisplayName
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (r platforms) Get(id string) (*models.Platform, error) {
+	var platform models.Platform
+	err := r.s.do(func(d *data) error {
+		row, ok := d.platforms[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		platform = row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &platform, nil
+}
+
+func (r platforms) Create(platform *models.Platform) error {
+	row := *platform
+	row.CreatedAt = stamp(row.CreatedAt)
+	row.UpdatedAt = stamp(row.UpdatedAt)
+
+	return r.s.do(func(d *data) error {
+		if _, ok := d.platforms[row.ID]; ok || nameTaken(d, row) {
+			return repository.ErrDuplicate
+		}
+		d.platforms[row.ID] = row
+		return nil
+	})
+}
+
+func (r platforms) Update(platform *models.Platform) error {
+	return r.s.do(func(d *data) error {
+		row, ok := d.platforms[platform.ID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		if nameTaken(d, *platform) {
+			return repository.ErrDuplicate
+		}
+		row.Name = platform.Name
+		row.DisplayName = platform.DisplayName
+		row.Enabled = platform.Enabled
+		row.Config = platform.Config
+		row.UpdatedAt = stamp(platform.UpdatedAt)
+		d.platforms[row.ID] = row
+		return nil
+	})
+}
+
+func (r platforms) Delete(id string) error {
+	return r.s.do(func(d *data) error {
+		if _, ok := d.platforms[id]; !ok {
+			return sql.ErrNoRows
+		}
+		delete(d.platforms, id)
+		return nil
+	})
+}
+
+// nameTaken reports whether another platform has the platform's name.
+func nameTaken(d *data, platform models.Platform) bool {
+	for _, existing := range d.platforms {
+		if existing.ID != platform.ID && existing.Name == platform.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type reposts struct {
+	s *Store
+}
+
+func (r reposts) List(workspaceID string, page repository.Page) ([]models.Repost, int64, error) {
+	var list []models.Repost
+	r.s.do(func(d *data) error {
+		for _, repost := range d.reposts {
+			if repost.WorkspaceID == workspaceID {
+				list = append(list, repost)
+			}
+		}
+		return nil
+	})
+
+	newestFirst(list, func(repost models.Repost) (time.Time, string) { return repost.CreatedAt, repost.ID })
+	list, total := paginate(list, page)
+	return list, total, nil
+}
+
+func (r reposts) Get(id string) (*models.Repost, error) {
+	var repost models.Repost
+	err := r.s.do(func(d *data) error {
+		row, ok := d.reposts[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		repost = row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &repost, nil
+}
+
+// Create stores a new repost. The store does not keep the request ID and
+// trace context of ctx, which are never read back.
+func (r reposts) Create(ctx context.Context, repost *models.Repost) error {
+	row := models.Repost{
+		ID:             repost.ID,
+		ArticleID:      repost.ArticleID,
+		MediaAccountID: repost.MediaAccountID,
+		CustomCaption:  repost.CustomCaption,
+		Status:         repost.Status,
+		ScheduledAt:    stampPtr(repost.ScheduledAt),
+		ApprovalStatus: repost.ApprovalStatus,
+		WorkspaceID:    repost.WorkspaceID,
+		UserID:         repost.UserID,
+		CreatedAt:      stamp(repost.CreatedAt),
+		UpdatedAt:      stamp(repost.UpdatedAt),
+	}
+
+	return r.s.do(func(d *data) error {
+		if _, ok := d.reposts[row.ID]; ok {
+			return repository.ErrDuplicate
+		}
+		d.reposts[row.ID] = row
+		return nil
+	})
+}
+
+func (r reposts) Count(filter repository.CountFilter) (int64, error) {
+	var total int64
+	r.s.do(func(d *data) error {
+		for _, repost := range d.reposts {
+			if counted(filter, repost.UserID, repost.CreatedAt) {
+				total++
+			}
+		}
+		return nil
+	})
+	return total, nil
+}
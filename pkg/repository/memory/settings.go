@@ -0,0 +1,36 @@
+package memory
+
+type settings struct {
+	s *Store
+}
+
+func (r settings) All() (map[string]string, error) {
+	values := make(map[string]string)
+	r.s.do(func(d *data) error {
+		for key, value := range d.settings {
+			values[key] = value
+		}
+		return nil
+	})
+	return values, nil
+}
+
+func (r settings) Lookup(keys []string) (map[string]string, error) {
+	values := make(map[string]string)
+	r.s.do(func(d *data) error {
+		for _, key := range keys {
+			if value, ok := d.settings[key]; ok {
+				values[key] = value
+			}
+		}
+		return nil
+	})
+	return values, nil
+}
+
+func (r settings) Put(key, value string) error {
+	return r.s.do(func(d *data) error {
+		d.settings[key] = value
+		return nil
+	})
+}
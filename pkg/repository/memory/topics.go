@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"database/sql"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type topics struct {
+	s *Store
+}
+
+func (r topics) List(workspaceID string, page repository.Page) ([]models.Topic, int64, error) {
+	var list []models.Topic
+	r.s.do(func(d *data) error {
+		for _, topic := range d.topics {
+			if topic.WorkspaceID == workspaceID {
+				list = append(list, copyTopic(topic))
+			}
+		}
+		return nil
+	})
+
+	newestFirst(list, func(topic models.Topic) (time.Time, string) { return topic.CreatedAt, topic.ID })
+	list, total := paginate(list, page)
+	return list, total, nil
+}
+
+func (r topics) Get(workspaceID, id string) (*models.Topic, error) {
+	var topic models.Topic
+	err := r.s.do(func(d *data) error {
+		row, ok := d.topics[id]
+		if !ok || row.WorkspaceID != workspaceID {
+			return sql.ErrNoRows
+		}
+		topic = copyTopic(row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &topic, nil
+}
+
+func (r topics) Create(topic *models.Topic) error {
+	row := copyTopic(*topic)
+	row.CreatedAt = stamp(row.CreatedAt)
+	row.UpdatedAt = stamp(row.UpdatedAt)
+
+	return r.s.do(func(d *data) error {
+		if _, ok := d.topics[row.ID]; ok {
+			return repository.ErrDuplicate
+		}
+		d.topics[row.ID] = row
+		return nil
+	})
+}
+
+func (r topics) Update(topic *models.Topic) error {
+	return r.s.do(func(d *data) error {
+		row, ok := d.topics[topic.ID]
+		if !ok || row.WorkspaceID != topic.WorkspaceID {
+			return sql.ErrNoRows
+		}
+		row.Name = topic.Name
+		row.Description = topic.Description
+		row.Keywords = copyStrings(topic.Keywords)
+		row.Platforms = copyStrings(topic.Platforms)
+		row.UpdatedAt = stamp(topic.UpdatedAt)
+		d.topics[row.ID] = row
+		return nil
+	})
+}
+
+func (r topics) Delete(workspaceID, id string) error {
+	return r.s.do(func(d *data) error {
+		row, ok := d.topics[id]
+		if !ok || row.WorkspaceID != workspaceID {
+			return sql.ErrNoRows
+		}
+		d.deleteTopic(id)
+		return nil
+	})
+}
+
+// sourceHost matches the host of an article URL, like the expression the
+// postgres repository uses.
+var sourceHost = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://([^/:?#]+)`)
+
+func (r topics) Activity(topicID string, from time.Time, days, limit int) (*models.TopicStatsResponse, error) {
+	from = stamp(from)
+	stats := &models.TopicStatsResponse{
+		ArticlesPerDay:  []models.DailyCount{},
+		RepostsByStatus: make(map[string]int64),
+	}
+
+	perDay := make(map[string]int64)
+	sources := make(map[string]int64)
+	authors := make(map[string]int64)
+	r.s.do(func(d *data) error {
+		for _, article := range d.articles {
+			if article.TopicID != topicID {
+				continue
+			}
+			perDay[article.PublishedAt.Format("2006-01-02")]++
+			if article.PublishedAt.Before(from) {
+				continue
+			}
+			if match := sourceHost.FindStringSubmatch(article.OriginalURL); match != nil {
+				sources[strings.ToLower(match[1])]++
+			}
+			if article.AuthorName != nil {
+				authors[*article.AuthorName]++
+			}
+		}
+
+		for _, repost := range d.reposts {
+			article, ok := d.articles[repost.ArticleID]
+			if ok && article.TopicID == topicID && !repost.CreatedAt.Before(from) {
+				stats.RepostsByStatus[repost.Status]++
+			}
+		}
+		return nil
+	})
+
+	for i := 0; i < days; i++ {
+		day := from.AddDate(0, 0, i).Format("2006-01-02")
+		stats.ArticlesPerDay = append(stats.ArticlesPerDay, models.DailyCount{Date: day, Count: perDay[day]})
+	}
+	stats.TopSources = topCounts(sources, limit)
+	stats.TopAuthors = topCounts(authors, limit)
+
+	return stats, nil
+}
+
+// topCounts ranks names by count, then by name, and returns at most limit.
+func topCounts(counts map[string]int64, limit int) []models.NamedCount {
+	ranked := []models.NamedCount{}
+	for name, count := range counts {
+		ranked = append(ranked, models.NamedCount{Name: name, Count: count})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+func (r topics) Count(filter repository.CountFilter) (int64, error) {
+	var total int64
+	r.s.do(func(d *data) error {
+		for _, topic := range d.topics {
+			if counted(filter, topic.UserID, topic.CreatedAt) {
+				total++
+			}
+		}
+		return nil
+	})
+	return total, nil
+}
+
+func copyTopic(topic models.Topic) models.Topic {
+	topic.Keywords = copyStrings(topic.Keywords)
+	topic.Platforms = copyStrings(topic.Platforms)
+	return topic
+}
@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type users struct {
+	s *Store
+}
+
+func (r users) Get(id string) (*models.User, error) {
+	var user models.User
+	err := r.s.do(func(d *data) error {
+		row, ok := d.users[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		user = row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r users) List(page repository.Page) ([]models.User, int64, error) {
+	var list []models.User
+	r.s.do(func(d *data) error {
+		for _, user := range d.users {
+			list = append(list, user)
+		}
+		return nil
+	})
+
+	newestFirst(list, func(user models.User) (time.Time, string) { return user.CreatedAt, user.ID })
+	list, total := paginate(list, page)
+	return list, total, nil
+}
+
+func (r users) Create(user *models.User) error {
+	row := models.User{
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		EmailVerified: stampPtr(user.EmailVerified),
+		Image:         user.Image,
+		IsAdmin:       user.IsAdmin,
+		Role:          user.Role,
+		CreatedAt:     stamp(user.CreatedAt),
+		UpdatedAt:     stamp(user.UpdatedAt),
+	}
+
+	return r.s.do(func(d *data) error {
+		if _, ok := d.users[row.ID]; ok {
+			return repository.ErrDuplicate
+		}
+		for _, existing := range d.users {
+			if existing.Email == row.Email {
+				return repository.ErrDuplicate
+			}
+		}
+		d.users[row.ID] = row
+		return nil
+	})
+}
+
+func (r users) UpdateProfile(id string, name, image *string, updatedAt time.Time) error {
+	return r.s.do(func(d *data) error {
+		user, ok := d.users[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		if name != nil {
+			value := *name
+			user.Name = &value
+		}
+		if image != nil {
+			value := *image
+			user.Image = &value
+		}
+		user.UpdatedAt = stamp(updatedAt)
+		d.users[id] = user
+		return nil
+	})
+}
+
+func (r users) SetRole(id, role string, isAdmin bool, updatedAt time.Time) error {
+	return r.s.do(func(d *data) error {
+		user, ok := d.users[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		user.Role = role
+		user.IsAdmin = isAdmin
+		user.UpdatedAt = stamp(updatedAt)
+		d.users[id] = user
+		return nil
+	})
+}
+
+func (r users) Delete(id string) error {
+	return r.s.do(func(d *data) error {
+		if _, ok := d.users[id]; !ok {
+			return sql.ErrNoRows
+		}
+		d.deleteUser(id)
+		return nil
+	})
+}
+
+func (r users) Count(filter repository.CountFilter) (int64, error) {
+	var total int64
+	r.s.do(func(d *data) error {
+		for _, user := range d.users {
+			if since(user.CreatedAt, filter.CreatedSince) {
+				total++
+			}
+		}
+		return nil
+	})
+	return total, nil
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"smg/pkg/models"
+)
+
+type apiKeys struct {
+	q querier
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at"
+
+func scanAPIKey(row scanner) (*models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(
+		&key.ID, &key.UserID, &key.Name, &key.Prefix, pq.Array(&key.Scopes),
+		&key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r apiKeys) List(userID string) ([]models.APIKey, error) {
+	rows, err := r.q.Query(
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC", userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *key)
+	}
+
+	return list, rows.Err()
+}
+
+func (r apiKeys) GetByHash(hash string) (*models.APIKey, error) {
+	return scanAPIKey(r.q.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", hash))
+}
+
+func (r apiKeys) Create(key *models.APIKey, hash string) error {
+	_, err := r.q.Exec(`
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, key.ID, key.UserID, key.Name, key.Prefix, hash, pq.Array(key.Scopes), key.ExpiresAt, key.CreatedAt)
+	return duplicate(err)
+}
+
+func (r apiKeys) Delete(userID, id string) error {
+	return requireRows(r.q.Exec("DELETE FROM api_keys WHERE id = $1 AND user_id = $2", id, userID))
+}
+
+func (r apiKeys) Touch(id string, at time.Time) error {
+	return requireRows(r.q.Exec("UPDATE api_keys SET last_used_at = $2 WHERE id = $1", id, at))
+}
@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"github.com/lib/pq"
+	"smg/pkg/models"
+)
+
+type approvals struct {
+	q querier
+}
+
+const policyColumns = "id, media_account_id, topic_id, approver_ids, allow_admins, user_id, created_at, updated_at"
+
+// policyOwner is the WHERE condition matching the policy with ID $1 that
+// belongs to the owner bound to $2, or to anyone when $2 is empty.
+const policyOwner = "id = $1 AND ($2 = '' OR user_id = $2)"
+
+// reviewableReposts selects the IDs of reposts covered by a policy that
+// lists $1 as an approver, or that accepts admins when $2 is true.
+const reviewableReposts = `
+	SELECT r.id
+	FROM reposts r
+	JOIN articles a ON a.id = r.article_id
+	JOIN approval_policies p ON p.media_account_id = r.media_account_id OR p.topic_id = a.topic_id
+	WHERE $1 = ANY(p.approver_ids) OR (p.allow_admins AND $2)
+`
+
+func scanPolicy(row scanner) (*models.ApprovalPolicy, error) {
+	var policy models.ApprovalPolicy
+	err := row.Scan(
+		&policy.ID, &policy.MediaAccountID, &policy.TopicID, pq.Array(&policy.ApproverIDs),
+		&policy.AllowAdmins, &policy.UserID, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r approvals) Policies(userID string) ([]models.ApprovalPolicy, error) {
+	rows, err := r.q.Query(
+		"SELECT "+policyColumns+" FROM approval_policies WHERE user_id = $1 ORDER BY created_at DESC", userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.ApprovalPolicy
+	for rows.Next() {
+		policy, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *policy)
+	}
+
+	return list, rows.Err()
+}
+
+func (r approvals) Policy(ownerID, id string) (*models.ApprovalPolicy, error) {
+	return scanPolicy(r.q.QueryRow("SELECT "+policyColumns+" FROM approval_policies WHERE "+policyOwner, id, ownerID))
+}
+
+func (r approvals) CreatePolicy(policy *models.ApprovalPolicy) error {
+	_, err := r.q.Exec(`
+		INSERT INTO approval_policies (id, media_account_id, topic_id, approver_ids, allow_admins, user_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, policy.ID, policy.MediaAccountID, policy.TopicID, pq.Array(policy.ApproverIDs), policy.AllowAdmins,
+		policy.UserID, policy.CreatedAt, policy.UpdatedAt)
+	return duplicate(err)
+}
+
+func (r approvals) UpdatePolicy(ownerID string, policy *models.ApprovalPolicy) error {
+	return requireRows(r.q.Exec(`
+		UPDATE approval_policies
+		SET media_account_id = $3, topic_id = $4, approver_ids = $5, allow_admins = $6, updated_at = $7
+		WHERE `+policyOwner,
+		policy.ID, ownerID, policy.MediaAccountID, policy.TopicID, pq.Array(policy.ApproverIDs),
+		policy.AllowAdmins, policy.UpdatedAt))
+}
+
+func (r approvals) DeletePolicy(ownerID, id string) error {
+	return requireRows(r.q.Exec("DELETE FROM approval_policies WHERE "+policyOwner, id, ownerID))
+}
+
+func (r approvals) Covered(articleID, mediaAccountID string) (bool, error) {
+	var covered bool
+	err := r.q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM approval_policies
+			WHERE media_account_id = $2
+			OR topic_id = (SELECT topic_id FROM articles WHERE id = $1)
+		)
+	`, articleID, mediaAccountID).Scan(&covered)
+	return covered, err
+}
+
+func (r approvals) CanReview(repostID, reviewerID string, admin bool) (bool, error) {
+	var allowed bool
+	err := r.q.QueryRow("SELECT $3 IN ("+reviewableReposts+")", reviewerID, admin, repostID).Scan(&allowed)
+	return allowed, err
+}
+
+func (r approvals) Pending(reviewerID string, admin bool) ([]models.Repost, error) {
+	rows, err := r.q.Query(`
+		SELECT `+repostColumns+`
+		FROM reposts
+		WHERE approval_status = $3
+		AND id IN (`+reviewableReposts+`)
+		ORDER BY created_at
+	`, reviewerID, admin, models.ApprovalPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Repost
+	for rows.Next() {
+		repost, err := scanRepost(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *repost)
+	}
+
+	return list, rows.Err()
+}
+
+func (r approvals) Review(decision *models.RepostApproval, status string) error {
+	err := requireRows(r.q.Exec(`
+		UPDATE reposts
+		SET approval_status = $2, approved_by = $3, approved_at = $4, status = $5, updated_at = $4
+		WHERE id = $1 AND approval_status = $6
+	`, decision.RepostID, decision.Decision, decision.ReviewerID, decision.CreatedAt, status, models.ApprovalPending))
+	if err != nil {
+		return err
+	}
+
+	_, err = r.q.Exec(`
+		INSERT INTO repost_approvals (id, repost_id, reviewer_id, decision, comment, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, decision.ID, decision.RepostID, decision.ReviewerID, decision.Decision, decision.Comment, decision.CreatedAt)
+	return err
+}
+
+func (r approvals) Decisions(repostID string) ([]models.RepostApproval, error) {
+	rows, err := r.q.Query(`
+		SELECT id, repost_id, reviewer_id, decision, comment, created_at
+		FROM repost_approvals
+		WHERE repost_id = $1
+		ORDER BY created_at
+	`, repostID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.RepostApproval
+	for rows.Next() {
+		var decision models.RepostApproval
+		err := rows.Scan(
+			&decision.ID, &decision.RepostID, &decision.ReviewerID,
+			&decision.Decision, &decision.Comment, &decision.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, decision)
+	}
+
+	return list, rows.Err()
+}
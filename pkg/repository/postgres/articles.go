@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type articles struct {
+	q querier
+}
+
+const articleColumns = `id, title, content, original_url, platform, author_name, author_id,
+	published_at, topic_id, workspace_id, user_id, state, state_changed_at, created_at, updated_at`
+
+func scanArticle(row scanner) (*models.Article, error) {
+	var article models.Article
+	err := row.Scan(
+		&article.ID, &article.Title, &article.Content, &article.OriginalURL,
+		&article.Platform, &article.AuthorName, &article.AuthorID,
+		&article.PublishedAt, &article.TopicID, &article.WorkspaceID, &article.UserID,
+		&article.State, &article.StateChangedAt, &article.CreatedAt, &article.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+// articleFilter is the WHERE condition of List, bound to $1 to $3.
+const articleFilter = "workspace_id = $1 AND ($2 = '' OR topic_id = $2) AND ($3 = '' OR state = $3)"
+
+func (r articles) List(workspaceID string, filter repository.ArticleFilter, page repository.Page) ([]models.Article, int64, error) {
+	total, err := count(r.q, "SELECT COUNT(*) FROM articles WHERE "+articleFilter,
+		workspaceID, filter.TopicID, filter.State)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.q.Query(`
+		SELECT `+articleColumns+`
+		FROM articles
+		WHERE `+articleFilter+`
+		ORDER BY published_at DESC
+		LIMIT $4 OFFSET $5
+	`, workspaceID, filter.TopicID, filter.State, page.Limit, page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var list []models.Article
+	for rows.Next() {
+		article, err := scanArticle(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		list = append(list, *article)
+	}
+
+	return list, total, rows.Err()
+}
+
+func (r articles) Get(workspaceID, id string) (*models.Article, error) {
+	return scanArticle(r.q.QueryRow(
+		"SELECT "+articleColumns+" FROM articles WHERE id = $1 AND workspace_id = $2", id, workspaceID,
+	))
+}
+
+func (r articles) Create(article *models.Article) error {
+	state := article.State
+	if state == "" {
+		state = models.ArticleStateNew
+	}
+
+	_, err := r.q.Exec(`
+		INSERT INTO articles (id, title, content, original_url, platform, author_name, author_id,
+			published_at, topic_id, workspace_id, user_id, state, state_changed_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`, article.ID, article.Title, article.Content, article.OriginalURL, article.Platform,
+		article.AuthorName, article.AuthorID, article.PublishedAt, article.TopicID,
+		article.WorkspaceID, article.UserID, state, article.StateChangedAt, article.CreatedAt, article.UpdatedAt)
+	return err
+}
+
+func (r articles) Update(article *models.Article) error {
+	return requireRows(r.q.Exec(`
+		UPDATE articles
+		SET title = $2, content = $3, updated_at = $4
+		WHERE id = $1 AND workspace_id = $5
+	`, article.ID, article.Title, article.Content, article.UpdatedAt, article.WorkspaceID))
+}
+
+func (r articles) Delete(workspaceID, id string) error {
+	return requireRows(r.q.Exec("DELETE FROM articles WHERE id = $1 AND workspace_id = $2", id, workspaceID))
+}
+
+func (r articles) SetState(workspaceID string, ids []string, state string, at time.Time) (int64, error) {
+	result, err := r.q.Exec(`
+		UPDATE articles
+		SET state = $3, state_changed_at = $4, updated_at = $4
+		WHERE id = ANY($1) AND workspace_id = $2 AND state <> $3
+	`, pq.Array(ids), workspaceID, state, at)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r articles) Count(filter repository.CountFilter) (int64, error) {
+	return count(r.q, "SELECT COUNT(*) FROM articles WHERE "+countFilter, countFilterArgs(filter)...)
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"smg/pkg/models"
+)
+
+type auditLog struct {
+	q querier
+}
+
+// Record stores the event, filling in its ID and time.
+func (r auditLog) Record(event models.AuditEvent) error {
+	before, err := jsonColumn(event.Before)
+	if err != nil {
+		return err
+	}
+	after, err := jsonColumn(event.After)
+	if err != nil {
+		return err
+	}
+	metadata, err := jsonColumn(event.Metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.q.Exec(`
+		INSERT INTO audit_events (id, action, actor_id, actor_email, resource_type, resource_id,
+			before, after, ip_address, request_id, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, uuid.New().String(), event.Action, event.ActorID, event.ActorEmail, event.ResourceType,
+		event.ResourceID, before, after, nullIfEmpty(event.IPAddress), nullIfEmpty(event.RequestID),
+		metadata, time.Now())
+	return err
+}
+
+// jsonColumn encodes value for a JSONB column, or NULL when it is nil.
+func jsonColumn(value map[string]interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
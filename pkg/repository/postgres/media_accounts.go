@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type mediaAccounts struct {
+	q querier
+}
+
+const mediaAccountColumns = "id, platform, account_id, account_name, expires_at, workspace_id, user_id, created_at, updated_at"
+
+func scanMediaAccount(row scanner) (*models.MediaAccount, error) {
+	var account models.MediaAccount
+	err := row.Scan(
+		&account.ID, &account.Platform, &account.AccountID, &account.AccountName,
+		&account.ExpiresAt, &account.WorkspaceID, &account.UserID, &account.CreatedAt, &account.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r mediaAccounts) List(workspaceID, platform string) ([]models.MediaAccount, error) {
+	rows, err := r.q.Query(`
+		SELECT `+mediaAccountColumns+`
+		FROM media_accounts
+		WHERE workspace_id = $1 AND ($2 = '' OR platform = $2)
+		ORDER BY created_at DESC
+	`, workspaceID, platform)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.MediaAccount
+	for rows.Next() {
+		account, err := scanMediaAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *account)
+	}
+
+	return list, rows.Err()
+}
+
+func (r mediaAccounts) Get(workspaceID, id string) (*models.MediaAccount, error) {
+	return scanMediaAccount(r.q.QueryRow(
+		"SELECT "+mediaAccountColumns+" FROM media_accounts WHERE id = $1 AND workspace_id = $2", id, workspaceID,
+	))
+}
+
+func (r mediaAccounts) Create(account *models.MediaAccount) error {
+	_, err := r.q.Exec(`
+		INSERT INTO media_accounts (id, platform, account_id, account_name, access_token, refresh_token, expires_at,
+			workspace_id, user_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, account.ID, account.Platform, account.AccountID, account.AccountName, account.AccessToken,
+		account.RefreshToken, account.ExpiresAt, account.WorkspaceID, account.UserID, account.CreatedAt, account.UpdatedAt)
+	return duplicate(err)
+}
+
+func (r mediaAccounts) Update(account *models.MediaAccount) error {
+	return requireRows(r.q.Exec(`
+		UPDATE media_accounts
+		SET account_name = $2, updated_at = $3
+		WHERE id = $1 AND workspace_id = $4
+	`, account.ID, account.AccountName, account.UpdatedAt, account.WorkspaceID))
+}
+
+func (r mediaAccounts) Delete(workspaceID, id string) error {
+	return requireRows(r.q.Exec("DELETE FROM media_accounts WHERE id = $1 AND workspace_id = $2", id, workspaceID))
+}
+
+func (r mediaAccounts) Count(filter repository.CountFilter) (int64, error) {
+	return count(r.q, "SELECT COUNT(*) FROM media_accounts WHERE "+countFilter, countFilterArgs(filter)...)
+}
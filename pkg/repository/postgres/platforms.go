@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"smg/pkg/models"
+)
+
+type platforms struct {
+	q querier
+}
+
+const platformColumns = "id, name, display_name, enabled, config, created_at, updated_at"
+
+func scanPlatform(row scanner) (*models.Platform, error) {
+	var platform models.Platform
+	err := row.Scan(
+		&platform.ID, &platform.Name, &platform.DisplayName,
+		&platform.Enabled, &platform.Config, &platform.CreatedAt, &platform.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &platform, nil
+}
+
+func (r platforms) List() ([]models.Platform, error) {
+	rows, err := r.q.Query("SELECT " + platformColumns + " FROM platforms ORDER BY display_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Platform
+	for rows.Next() {
+		platform, err := scanPlatform(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *platform)
+	}
+
+	return list, rows.Err()
+}
+
+func (r platforms) Get(id string) (*models.Platform, error) {
+	return scanPlatform(r.q.QueryRow("SELECT "+platformColumns+" FROM platforms WHERE id = $1", id))
+}
+
+func (r platforms) Create(platform *models.Platform) error {
+	_, err := r.q.Exec(`
+		INSERT INTO platforms (id, name, display_name, enabled, config, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, platform.ID, platform.Name, platform.DisplayName, platform.Enabled, platform.Config,
+		platform.CreatedAt, platform.UpdatedAt)
+	return duplicate(err)
+}
+
+func (r platforms) Update(platform *models.Platform) error {
+	result, err := r.q.Exec(`
+		UPDATE platforms
+		SET name = $2, display_name = $3, enabled = $4, config = $5, updated_at = $6
+		WHERE id = $1
+	`, platform.ID, platform.Name, platform.DisplayName, platform.Enabled, platform.Config, platform.UpdatedAt)
+	return requireRows(result, duplicate(err))
+}
+
+func (r platforms) Delete(id string) error {
+	return requireRows(r.q.Exec("DELETE FROM platforms WHERE id = $1", id))
+}
@@ -0,0 +1,124 @@
+// Package postgres implements the repositories on the application
+// database.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"smg/pkg/repository"
+)
+
+// uniqueViolation is the PostgreSQL error code for a duplicate key.
+const uniqueViolation = "23505"
+
+// querier is satisfied by both *sql.DB and *sql.Tx, so repositories run the
+// same queries in and out of a transaction.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Store is a repository.Store on a database.
+type Store struct {
+	db *sql.DB
+	q  querier
+}
+
+// New returns a store on db.
+func New(db *sql.DB) *Store {
+	return &Store{db: db, q: db}
+}
+
+var _ repository.Store = (*Store)(nil)
+
+func (s *Store) Users() repository.UserRepository                 { return users{s.q} }
+func (s *Store) Topics() repository.TopicRepository               { return topics{s.q} }
+func (s *Store) MediaAccounts() repository.MediaAccountRepository { return mediaAccounts{s.q} }
+func (s *Store) Articles() repository.ArticleRepository           { return articles{s.q} }
+func (s *Store) Reposts() repository.RepostRepository             { return reposts{s.q} }
+func (s *Store) Settings() repository.SettingsRepository          { return settings{s.q} }
+func (s *Store) Platforms() repository.PlatformRepository         { return platforms{s.q} }
+func (s *Store) APIKeys() repository.APIKeyRepository             { return apiKeys{s.q} }
+func (s *Store) Approvals() repository.ApprovalRepository         { return approvals{s.q} }
+func (s *Store) Audit() repository.AuditLog                       { return auditLog{s.q} }
+
+// InTx runs fn in a database transaction.
+func (s *Store) InTx(fn func(repository.Store) error) error {
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&Store{db: s.db, q: tx}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// requireRows turns an UPDATE or DELETE that matched nothing into
+// sql.ErrNoRows.
+func requireRows(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// duplicate turns a unique violation into repository.ErrDuplicate.
+func duplicate(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return repository.ErrDuplicate
+	}
+	return err
+}
+
+// count runs a COUNT query.
+func count(q querier, query string, args ...interface{}) (int64, error) {
+	var total int64
+	err := q.QueryRow(query, args...).Scan(&total)
+	return total, err
+}
+
+// countFilter is the WHERE condition of CountFilter on a table with
+// user_id and created_at columns, bound to $1 and $2.
+const countFilter = "($1 = '' OR user_id = $1) AND ($2::timestamp IS NULL OR created_at >= $2)"
+
+func countFilterArgs(filter repository.CountFilter) []interface{} {
+	return []interface{}{filter.UserID, nullIfZero(filter.CreatedSince)}
+}
+
+func nullIfZero(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func nullIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"smg/pkg/dbtest"
+	"smg/pkg/repository"
+	"smg/pkg/repository/repotest"
+)
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
+
+// TestContract runs the contract suite against a database created by
+// dbtest; it is skipped unless TEST_DATABASE_URL is set, and fails instead
+// under CI or TEST_DATABASE_REQUIRED.
+func TestContract(t *testing.T) {
+	var db *sql.DB
+	repotest.Run(t, repotest.Harness{
+		New: func(t *testing.T) repository.Store {
+			db = dbtest.DB(t)
+			return New(db)
+		},
+		AddWorkspace: func(t *testing.T, store repository.Store, id, ownerID string) {
+			now := time.Now()
+			_, err := db.Exec(`
+				INSERT INTO workspaces (id, name, personal, owner_id, created_at, updated_at)
+				VALUES ($1, $2, false, $3, $4, $4)
+			`, id, "Workspace", ownerID, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+		},
+	})
+}
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+
+	"smg/pkg/logging"
+	"smg/pkg/models"
+	"smg/pkg/repository"
+	"smg/pkg/tracing"
+)
+
+type reposts struct {
+	q querier
+}
+
+const repostColumns = `id, article_id, media_account_id, custom_caption, ai_caption, status,
+	scheduled_at, posted_at, external_id, approval_status, approved_by, approved_at,
+	workspace_id, user_id, created_at, updated_at`
+
+func scanRepost(row scanner) (*models.Repost, error) {
+	var repost models.Repost
+	err := row.Scan(
+		&repost.ID, &repost.ArticleID, &repost.MediaAccountID, &repost.CustomCaption,
+		&repost.AICaption, &repost.Status, &repost.ScheduledAt, &repost.PostedAt,
+		&repost.ExternalID, &repost.ApprovalStatus, &repost.ApprovedBy, &repost.ApprovedAt,
+		&repost.WorkspaceID, &repost.UserID, &repost.CreatedAt, &repost.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &repost, nil
+}
+
+func (r reposts) List(workspaceID string, page repository.Page) ([]models.Repost, int64, error) {
+	total, err := count(r.q, "SELECT COUNT(*) FROM reposts WHERE workspace_id = $1", workspaceID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.q.Query(`
+		SELECT `+repostColumns+`
+		FROM reposts
+		WHERE workspace_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, workspaceID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var list []models.Repost
+	for rows.Next() {
+		repost, err := scanRepost(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		list = append(list, *repost)
+	}
+
+	return list, total, rows.Err()
+}
+
+func (r reposts) Get(id string) (*models.Repost, error) {
+	return scanRepost(r.q.QueryRow("SELECT "+repostColumns+" FROM reposts WHERE id = $1", id))
+}
+
+func (r reposts) Create(ctx context.Context, repost *models.Repost) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO reposts (id, article_id, media_account_id, custom_caption, status,
+			scheduled_at, approval_status, workspace_id, user_id, request_id, trace_context,
+			created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`, repost.ID, repost.ArticleID, repost.MediaAccountID, repost.CustomCaption, repost.Status,
+		repost.ScheduledAt, repost.ApprovalStatus, repost.WorkspaceID, repost.UserID,
+		nullIfEmpty(logging.RequestID(ctx)), nullIfEmpty(tracing.TraceParent(ctx)),
+		repost.CreatedAt, repost.UpdatedAt)
+	return err
+}
+
+func (r reposts) Count(filter repository.CountFilter) (int64, error) {
+	return count(r.q, "SELECT COUNT(*) FROM reposts WHERE "+countFilter, countFilterArgs(filter)...)
+}
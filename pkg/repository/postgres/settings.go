@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type settings struct {
+	q querier
+}
+
+func (r settings) All() (map[string]string, error) {
+	return r.query("SELECT key, value FROM system_settings")
+}
+
+func (r settings) Lookup(keys []string) (map[string]string, error) {
+	return r.query("SELECT key, value FROM system_settings WHERE key = ANY($1) FOR UPDATE", pq.Array(keys))
+}
+
+func (r settings) query(query string, args ...interface{}) (map[string]string, error) {
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+
+	return values, rows.Err()
+}
+
+func (r settings) Put(key, value string) error {
+	now := time.Now()
+	_, err := r.q.Exec(`
+		INSERT INTO system_settings (id, key, value, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (key) DO UPDATE SET
+		value = EXCLUDED.value,
+		updated_at = EXCLUDED.updated_at
+	`, uuid.New().String(), key, value, now, now)
+	return err
+}
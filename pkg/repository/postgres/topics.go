@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type topics struct {
+	q querier
+}
+
+const topicColumns = "id, name, description, keywords, platforms, workspace_id, user_id, created_at, updated_at"
+
+func scanTopic(row scanner) (*models.Topic, error) {
+	var topic models.Topic
+	err := row.Scan(
+		&topic.ID, &topic.Name, &topic.Description,
+		pq.Array(&topic.Keywords), pq.Array(&topic.Platforms),
+		&topic.WorkspaceID, &topic.UserID, &topic.CreatedAt, &topic.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &topic, nil
+}
+
+func (r topics) List(workspaceID string, page repository.Page) ([]models.Topic, int64, error) {
+	total, err := count(r.q, "SELECT COUNT(*) FROM topics WHERE workspace_id = $1", workspaceID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.q.Query(`
+		SELECT `+topicColumns+`
+		FROM topics
+		WHERE workspace_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, workspaceID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var list []models.Topic
+	for rows.Next() {
+		topic, err := scanTopic(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		list = append(list, *topic)
+	}
+
+	return list, total, rows.Err()
+}
+
+func (r topics) Get(workspaceID, id string) (*models.Topic, error) {
+	return scanTopic(r.q.QueryRow(
+		"SELECT "+topicColumns+" FROM topics WHERE id = $1 AND workspace_id = $2", id, workspaceID,
+	))
+}
+
+func (r topics) Create(topic *models.Topic) error {
+	_, err := r.q.Exec(`
+		INSERT INTO topics (id, name, description, keywords, platforms, workspace_id, user_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, topic.ID, topic.Name, topic.Description, pq.Array(topic.Keywords), pq.Array(topic.Platforms),
+		topic.WorkspaceID, topic.UserID, topic.CreatedAt, topic.UpdatedAt)
+	return err
+}
+
+func (r topics) Update(topic *models.Topic) error {
+	return requireRows(r.q.Exec(`
+		UPDATE topics
+		SET name = $2, description = $3, keywords = $4, platforms = $5, updated_at = $6
+		WHERE id = $1 AND workspace_id = $7
+	`, topic.ID, topic.Name, topic.Description, pq.Array(topic.Keywords), pq.Array(topic.Platforms),
+		topic.UpdatedAt, topic.WorkspaceID))
+}
+
+func (r topics) Delete(workspaceID, id string) error {
+	return requireRows(r.q.Exec("DELETE FROM topics WHERE id = $1 AND workspace_id = $2", id, workspaceID))
+}
+
+func (r topics) Activity(topicID string, since time.Time, days, limit int) (*models.TopicStatsResponse, error) {
+	stats := &models.TopicStatsResponse{
+		ArticlesPerDay:  []models.DailyCount{},
+		RepostsByStatus: make(map[string]int64),
+	}
+
+	rows, err := r.q.Query(`
+		SELECT to_char(d.day, 'YYYY-MM-DD'), COUNT(a.id)
+		FROM generate_series($2::timestamp, $2::timestamp + ($3::int - 1) * INTERVAL '1 day', INTERVAL '1 day') AS d(day)
+		LEFT JOIN articles a ON a.topic_id = $1 AND a.published_at::date = d.day::date
+		GROUP BY d.day
+		ORDER BY d.day
+	`, topicID, since, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day models.DailyCount
+		if err := rows.Scan(&day.Date, &day.Count); err != nil {
+			return nil, err
+		}
+		stats.ArticlesPerDay = append(stats.ArticlesPerDay, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statusRows, err := r.q.Query(`
+		SELECT r.status, COUNT(*)
+		FROM reposts r
+		JOIN articles a ON a.id = r.article_id
+		WHERE a.topic_id = $1 AND r.created_at >= $2
+		GROUP BY r.status
+	`, topicID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer statusRows.Close()
+
+	for statusRows.Next() {
+		var status string
+		var count int64
+		if err := statusRows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		stats.RepostsByStatus[status] = count
+	}
+	if err := statusRows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats.TopSources, err = r.topCounts(`
+		SELECT source, COUNT(*)
+		FROM (
+			SELECT lower(substring(original_url FROM '^[a-zA-Z][a-zA-Z0-9+.-]*://([^/:?#]+)')) AS source
+			FROM articles
+			WHERE topic_id = $1 AND published_at >= $2
+		) sources
+		WHERE source IS NOT NULL
+		GROUP BY source
+		ORDER BY COUNT(*) DESC, source
+		LIMIT $3
+	`, topicID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.TopAuthors, err = r.topCounts(`
+		SELECT author_name AS name, COUNT(*)
+		FROM articles
+		WHERE topic_id = $1 AND published_at >= $2 AND author_name IS NOT NULL
+		GROUP BY author_name
+		ORDER BY COUNT(*) DESC, author_name
+		LIMIT $3
+	`, topicID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (r topics) topCounts(query string, args ...interface{}) ([]models.NamedCount, error) {
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.NamedCount{}
+	for rows.Next() {
+		var count models.NamedCount
+		if err := rows.Scan(&count.Name, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+func (r topics) Count(filter repository.CountFilter) (int64, error) {
+	return count(r.q, "SELECT COUNT(*) FROM topics WHERE "+countFilter, countFilterArgs(filter)...)
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"time"
+
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+type users struct {
+	q querier
+}
+
+const userColumns = `id, name, email, email_verified, image, is_admin, role,
+	two_factor_enabled_at IS NOT NULL, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (*models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID, &user.Name, &user.Email, &user.EmailVerified,
+		&user.Image, &user.IsAdmin, &user.Role, &user.TwoFactorEnabled, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r users) Get(id string) (*models.User, error) {
+	return scanUser(r.q.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1", id))
+}
+
+func (r users) List(page repository.Page) ([]models.User, int64, error) {
+	total, err := count(r.q, "SELECT COUNT(*) FROM users")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.q.Query(
+		"SELECT "+userColumns+" FROM users ORDER BY created_at DESC LIMIT $1 OFFSET $2", page.Limit, page.Offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var list []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		list = append(list, *user)
+	}
+
+	return list, total, rows.Err()
+}
+
+func (r users) Create(user *models.User) error {
+	_, err := r.q.Exec(`
+		INSERT INTO users (id, name, email, email_verified, image, is_admin, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, user.ID, user.Name, user.Email, user.EmailVerified, user.Image, user.IsAdmin, user.Role,
+		user.CreatedAt, user.UpdatedAt)
+	return duplicate(err)
+}
+
+func (r users) UpdateProfile(id string, name, image *string, updatedAt time.Time) error {
+	return requireRows(r.q.Exec(`
+		UPDATE users
+		SET name = COALESCE($2, name),
+			image = COALESCE($3, image),
+			updated_at = $4
+		WHERE id = $1
+	`, id, name, image, updatedAt))
+}
+
+func (r users) SetRole(id, role string, isAdmin bool, updatedAt time.Time) error {
+	return requireRows(r.q.Exec(
+		"UPDATE users SET role = $2, is_admin = $3, updated_at = $4 WHERE id = $1", id, role, isAdmin, updatedAt,
+	))
+}
+
+func (r users) Delete(id string) error {
+	return requireRows(r.q.Exec("DELETE FROM users WHERE id = $1", id))
+}
+
+func (r users) Count(filter repository.CountFilter) (int64, error) {
+	return count(r.q, "SELECT COUNT(*) FROM users WHERE ($1::timestamp IS NULL OR created_at >= $1)",
+		nullIfZero(filter.CreatedSince))
+}
@@ -0,0 +1,208 @@
+// Package repository defines how services load and store users, content
+// and system configuration. The postgres package implements it on the
+// application database and the memory package keeps everything in maps, so
+// service and handler logic can be tested without a database; both pass the
+// contract tests in repotest.
+//
+// Methods that read or change a single row return sql.ErrNoRows when it
+// does not exist or, for content, belongs to another workspace, so a row
+// outside the caller's reach is indistinguishable from a missing one.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"smg/pkg/models"
+)
+
+// ErrDuplicate is returned when a row would repeat a value that must be
+// unique, such as a user's email or a platform's name.
+var ErrDuplicate = errors.New("duplicate value")
+
+// Store gives access to the repositories.
+type Store interface {
+	Users() UserRepository
+	Topics() TopicRepository
+	MediaAccounts() MediaAccountRepository
+	Articles() ArticleRepository
+	Reposts() RepostRepository
+	Settings() SettingsRepository
+	Platforms() PlatformRepository
+	APIKeys() APIKeyRepository
+	Approvals() ApprovalRepository
+	Audit() AuditLog
+
+	// InTx runs fn with a store whose changes are kept if fn returns nil
+	// and discarded otherwise. Calling InTx on the store passed to fn runs
+	// in the same transaction.
+	InTx(fn func(Store) error) error
+}
+
+// Page selects a slice of a list.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// CountFilter narrows a count. Zero fields do not filter.
+type CountFilter struct {
+	// UserID counts the rows created by the user.
+	UserID string
+	// CreatedSince counts the rows created at or after it.
+	CreatedSince time.Time
+}
+
+// ArticleFilter narrows an article list. Zero fields do not filter.
+type ArticleFilter struct {
+	TopicID string
+	State   string
+}
+
+type UserRepository interface {
+	Get(id string) (*models.User, error)
+	// List returns users, newest first, and the total number of users.
+	List(page Page) ([]models.User, int64, error)
+	// Create stores a user without a password.
+	Create(user *models.User) error
+	// UpdateProfile sets the name and image that are not nil.
+	UpdateProfile(id string, name, image *string, updatedAt time.Time) error
+	// SetRole sets the user's role and whether it makes them an admin.
+	SetRole(id, role string, isAdmin bool, updatedAt time.Time) error
+	// Delete removes the user together with everything they created.
+	Delete(id string) error
+	// Count counts users; only filter.CreatedSince applies.
+	Count(filter CountFilter) (int64, error)
+}
+
+type TopicRepository interface {
+	// List returns the workspace's topics, newest first, and their total.
+	List(workspaceID string, page Page) ([]models.Topic, int64, error)
+	Get(workspaceID, id string) (*models.Topic, error)
+	Create(topic *models.Topic) error
+	// Update stores the topic's name, description, keywords, platforms and
+	// UpdatedAt.
+	Update(topic *models.Topic) error
+	// Delete removes the topic together with its articles and their
+	// reposts.
+	Delete(workspaceID, id string) error
+	// Activity counts the topic's articles on each of the days starting at
+	// since and its reposts since then by status, and ranks the article
+	// sources and authors since then, returning at most limit of each.
+	// Sources are the hosts of the article URLs.
+	Activity(topicID string, since time.Time, days, limit int) (*models.TopicStatsResponse, error)
+	Count(filter CountFilter) (int64, error)
+}
+
+type MediaAccountRepository interface {
+	// List returns the workspace's accounts, newest first. A non-empty
+	// platform returns only accounts on it.
+	List(workspaceID, platform string) ([]models.MediaAccount, error)
+	Get(workspaceID, id string) (*models.MediaAccount, error)
+	Create(account *models.MediaAccount) error
+	// Update stores the account's name and UpdatedAt.
+	Update(account *models.MediaAccount) error
+	// Delete removes the account together with its reposts.
+	Delete(workspaceID, id string) error
+	Count(filter CountFilter) (int64, error)
+}
+
+type ArticleRepository interface {
+	// List returns the workspace's articles matching filter, most recently
+	// published first, and their total.
+	List(workspaceID string, filter ArticleFilter, page Page) ([]models.Article, int64, error)
+	Get(workspaceID, id string) (*models.Article, error)
+	// Create stores a new article. An empty State is stored as
+	// models.ArticleStateNew.
+	Create(article *models.Article) error
+	// Update stores the article's title, content and UpdatedAt.
+	Update(article *models.Article) error
+	// Delete removes the article together with its reposts.
+	Delete(workspaceID, id string) error
+	// SetState moves the workspace's articles with the given IDs into
+	// state and returns how many changed. Articles already in state and
+	// IDs from other workspaces are skipped.
+	SetState(workspaceID string, ids []string, state string, at time.Time) (int64, error)
+	Count(filter CountFilter) (int64, error)
+}
+
+type RepostRepository interface {
+	// List returns the workspace's reposts, newest first, and their total.
+	List(workspaceID string, page Page) ([]models.Repost, int64, error)
+	Get(id string) (*models.Repost, error)
+	// Create stores a new repost. The request ID and trace context in ctx
+	// are stored with it, so the publish attempt can be traced back to
+	// the request that queued it.
+	Create(ctx context.Context, repost *models.Repost) error
+	Count(filter CountFilter) (int64, error)
+}
+
+type SettingsRepository interface {
+	All() (map[string]string, error)
+	// Lookup returns the values of the keys that are set. In a
+	// transaction the rows stay locked until it ends.
+	Lookup(keys []string) (map[string]string, error)
+	Put(key, value string) error
+}
+
+type PlatformRepository interface {
+	// List returns the platforms ordered by display name.
+	List() ([]models.Platform, error)
+	Get(id string) (*models.Platform, error)
+	Create(platform *models.Platform) error
+	// Update stores every field but the ID and CreatedAt.
+	Update(platform *models.Platform) error
+	Delete(id string) error
+}
+
+type APIKeyRepository interface {
+	// List returns the user's keys, newest first.
+	List(userID string) ([]models.APIKey, error)
+	// GetByHash returns the key whose secret has the given hash.
+	GetByHash(hash string) (*models.APIKey, error)
+	// Create stores the key with the hash of its secret.
+	Create(key *models.APIKey, hash string) error
+	// Delete removes one of the user's keys.
+	Delete(userID, id string) error
+	// Touch records that the key was used at the given time.
+	Touch(id string, at time.Time) error
+}
+
+// ApprovalRepository stores approval policies and the decisions taken on
+// the reposts they cover. A policy covers the reposts to its media account
+// and of the articles in its topic. Policies belong to the user who
+// created them; methods taking an ownerID only find that user's policies,
+// or any policy when it is empty.
+type ApprovalRepository interface {
+	// Policies returns the user's policies, newest first.
+	Policies(userID string) ([]models.ApprovalPolicy, error)
+	Policy(ownerID, id string) (*models.ApprovalPolicy, error)
+	CreatePolicy(policy *models.ApprovalPolicy) error
+	// UpdatePolicy stores the policy's targets, approvers, AllowAdmins
+	// and UpdatedAt.
+	UpdatePolicy(ownerID string, policy *models.ApprovalPolicy) error
+	DeletePolicy(ownerID, id string) error
+
+	// Covered reports whether any policy covers a repost of the article
+	// to the media account.
+	Covered(articleID, mediaAccountID string) (bool, error)
+	// CanReview reports whether a policy covering the repost lists the
+	// reviewer as an approver, or accepts admins and admin is true.
+	CanReview(repostID, reviewerID string, admin bool) (bool, error)
+	// Pending returns the reposts awaiting approval that the reviewer can
+	// review, oldest first.
+	Pending(reviewerID string, admin bool) ([]models.Repost, error)
+	// Review records the decision on a repost awaiting approval and moves
+	// the repost to status. It returns sql.ErrNoRows, changing nothing,
+	// when the repost is missing or no longer awaiting approval.
+	Review(decision *models.RepostApproval, status string) error
+	// Decisions returns the decisions taken on the repost, oldest first.
+	Decisions(repostID string) ([]models.RepostApproval, error)
+}
+
+// AuditLog records audit events. Recording through the store passed to
+// InTx keeps an event with the change it describes.
+type AuditLog interface {
+	Record(event models.AuditEvent) error
+}
@@ -0,0 +1,946 @@
+// Package repotest is the contract test suite of the repositories. Each
+// implementation of repository.Store runs it from its own tests with Run,
+// so they stay interchangeable.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+// Harness adapts an implementation to the suite.
+type Harness struct {
+	// New returns an empty store for one test.
+	New func(t *testing.T) repository.Store
+	// AddWorkspace creates a workspace owned by the user, for stores that
+	// check references. It may be nil.
+	AddWorkspace func(t *testing.T, store repository.Store, id, ownerID string)
+}
+
+// Run runs the suite against the stores h returns.
+func Run(t *testing.T, h Harness) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, f *fixture)
+	}{
+		{"Users", testUsers},
+		{"UserCascade", testUserCascade},
+		{"Topics", testTopics},
+		{"TopicActivity", testTopicActivity},
+		{"MediaAccounts", testMediaAccounts},
+		{"Articles", testArticles},
+		{"Reposts", testReposts},
+		{"APIKeys", testAPIKeys},
+		{"ApprovalPolicies", testApprovalPolicies},
+		{"ApprovalReviews", testApprovalReviews},
+		{"Settings", testSettings},
+		{"Platforms", testPlatforms},
+		{"InTx", testInTx},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, &fixture{h: h, store: h.New(t)})
+		})
+	}
+}
+
+// base is the time the fixtures are created at. Times are in UTC and
+// whole milliseconds, which every store returns unchanged.
+var base = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+type fixture struct {
+	h     Harness
+	store repository.Store
+	// clock advances by a minute for every row, so lists ordered by
+	// creation time have no ties.
+	clock int
+}
+
+func (f *fixture) now() time.Time {
+	f.clock++
+	return base.Add(time.Duration(f.clock) * time.Minute)
+}
+
+func (f *fixture) user(t *testing.T, email string) *models.User {
+	t.Helper()
+	now := f.now()
+	user := &models.User{ID: uuid.New().String(), Email: email, Role: "editor", CreatedAt: now, UpdatedAt: now}
+	if err := f.store.Users().Create(user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// workspace creates a workspace owned by the user.
+func (f *fixture) workspace(t *testing.T, owner *models.User) string {
+	t.Helper()
+	id := uuid.New().String()
+	if f.h.AddWorkspace != nil {
+		f.h.AddWorkspace(t, f.store, id, owner.ID)
+	}
+	return id
+}
+
+func (f *fixture) topic(t *testing.T, workspaceID string, user *models.User) *models.Topic {
+	t.Helper()
+	now := f.now()
+	topic := &models.Topic{
+		ID: uuid.New().String(), Name: "Topic", Keywords: []string{"go"}, Platforms: []string{"twitter"},
+		WorkspaceID: workspaceID, UserID: user.ID, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := f.store.Topics().Create(topic); err != nil {
+		t.Fatalf("create topic: %v", err)
+	}
+	return topic
+}
+
+func (f *fixture) account(t *testing.T, workspaceID string, user *models.User, platform string) *models.MediaAccount {
+	t.Helper()
+	now := f.now()
+	token := "token"
+	account := &models.MediaAccount{
+		ID: uuid.New().String(), Platform: platform, AccountID: uuid.New().String(), AccountName: "Account",
+		AccessToken: &token, WorkspaceID: workspaceID, UserID: user.ID, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := f.store.MediaAccounts().Create(account); err != nil {
+		t.Fatalf("create media account: %v", err)
+	}
+	return account
+}
+
+func (f *fixture) article(t *testing.T, topic *models.Topic, user *models.User, publishedAt time.Time, url string, author *string) *models.Article {
+	t.Helper()
+	now := f.now()
+	article := &models.Article{
+		ID: uuid.New().String(), Title: "Title", Content: "Content", OriginalURL: url, Platform: "rss",
+		AuthorName: author, PublishedAt: publishedAt, TopicID: topic.ID, WorkspaceID: topic.WorkspaceID,
+		UserID: user.ID, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := f.store.Articles().Create(article); err != nil {
+		t.Fatalf("create article: %v", err)
+	}
+	return article
+}
+
+func (f *fixture) repost(t *testing.T, article *models.Article, account *models.MediaAccount, user *models.User, status string) *models.Repost {
+	t.Helper()
+	return f.repostAwaiting(t, article, account, user, status, models.ApprovalNotRequired)
+}
+
+func (f *fixture) repostAwaiting(t *testing.T, article *models.Article, account *models.MediaAccount, user *models.User, status, approvalStatus string) *models.Repost {
+	t.Helper()
+	now := f.now()
+	repost := &models.Repost{
+		ID: uuid.New().String(), ArticleID: article.ID, MediaAccountID: account.ID, Status: status,
+		ApprovalStatus: approvalStatus, WorkspaceID: article.WorkspaceID, UserID: user.ID,
+		CreatedAt: now, UpdatedAt: now,
+	}
+	if err := f.store.Reposts().Create(context.Background(), repost); err != nil {
+		t.Fatalf("create repost: %v", err)
+	}
+	return repost
+}
+
+func wantErr(t *testing.T, what string, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("%s: got error %v, want %v", what, err, want)
+	}
+}
+
+func wantNoErr(t *testing.T, what string, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+}
+
+func wantCount(t *testing.T, what string, count func(repository.CountFilter) (int64, error), filter repository.CountFilter, want int64) {
+	t.Helper()
+	got, err := count(filter)
+	wantNoErr(t, what, err)
+	if got != want {
+		t.Fatalf("%s: got %d, want %d", what, got, want)
+	}
+}
+
+func wantIDs[T any](t *testing.T, what string, rows []T, id func(T) string, want ...string) {
+	t.Helper()
+	var got []string
+	for _, row := range rows {
+		got = append(got, id(row))
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %v, want %v", what, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s: got %v, want %v", what, got, want)
+		}
+	}
+}
+
+func userID(user models.User) string               { return user.ID }
+func topicID(topic models.Topic) string            { return topic.ID }
+func accountID(account models.MediaAccount) string { return account.ID }
+func articleID(article models.Article) string      { return article.ID }
+func repostID(repost models.Repost) string         { return repost.ID }
+func platformID(platform models.Platform) string   { return platform.ID }
+func apiKeyID(key models.APIKey) string            { return key.ID }
+func policyID(policy models.ApprovalPolicy) string { return policy.ID }
+
+func testUsers(t *testing.T, f *fixture) {
+	users := f.store.Users()
+	alice := f.user(t, "alice@example.com")
+	bob := f.user(t, "bob@example.com")
+
+	got, err := users.Get(alice.ID)
+	wantNoErr(t, "get user", err)
+	if got.Email != alice.Email || got.Role != "editor" || got.TwoFactorEnabled || !got.CreatedAt.Equal(alice.CreatedAt) {
+		t.Fatalf("get user: got %+v, want %+v", got, alice)
+	}
+
+	now := f.now()
+	duplicate := &models.User{ID: uuid.New().String(), Email: alice.Email, Role: "editor", CreatedAt: now, UpdatedAt: now}
+	wantErr(t, "create user with taken email", users.Create(duplicate), repository.ErrDuplicate)
+
+	list, total, err := users.List(repository.Page{Limit: 1})
+	wantNoErr(t, "list users", err)
+	if total != 2 {
+		t.Fatalf("list users: got total %d, want 2", total)
+	}
+	wantIDs(t, "list users", list, userID, bob.ID)
+	list, _, err = users.List(repository.Page{Limit: 10, Offset: 1})
+	wantNoErr(t, "list users", err)
+	wantIDs(t, "list users from offset", list, userID, alice.ID)
+
+	name := "Alice"
+	updatedAt := f.now()
+	wantNoErr(t, "update profile", users.UpdateProfile(alice.ID, &name, nil, updatedAt))
+	got, err = users.Get(alice.ID)
+	wantNoErr(t, "get user", err)
+	if got.Name == nil || *got.Name != name || got.Image != nil || !got.UpdatedAt.Equal(updatedAt) {
+		t.Fatalf("update profile: got %+v", got)
+	}
+	wantErr(t, "update missing profile", users.UpdateProfile(uuid.New().String(), &name, nil, updatedAt), sql.ErrNoRows)
+
+	updatedAt = f.now()
+	wantNoErr(t, "set role", users.SetRole(alice.ID, "admin", true, updatedAt))
+	got, err = users.Get(alice.ID)
+	wantNoErr(t, "get user", err)
+	if got.Role != "admin" || !got.IsAdmin || !got.UpdatedAt.Equal(updatedAt) {
+		t.Fatalf("set role: got %+v", got)
+	}
+	wantErr(t, "set missing user's role", users.SetRole(uuid.New().String(), "admin", true, updatedAt), sql.ErrNoRows)
+
+	wantCount(t, "count users", users.Count, repository.CountFilter{}, 2)
+	wantCount(t, "count new users", users.Count, repository.CountFilter{CreatedSince: bob.CreatedAt}, 1)
+
+	wantNoErr(t, "delete user", users.Delete(bob.ID))
+	_, err = users.Get(bob.ID)
+	wantErr(t, "get deleted user", err, sql.ErrNoRows)
+	wantErr(t, "delete missing user", users.Delete(bob.ID), sql.ErrNoRows)
+}
+
+func testUserCascade(t *testing.T, f *fixture) {
+	owner := f.user(t, "owner@example.com")
+	other := f.user(t, "other@example.com")
+	workspace := f.workspace(t, owner)
+	otherWorkspace := f.workspace(t, other)
+
+	topic := f.topic(t, workspace, owner)
+	account := f.account(t, workspace, owner, "twitter")
+	f.repost(t, f.article(t, topic, owner, base, "https://example.com/a", nil), account, owner, "pending")
+	kept := f.topic(t, otherWorkspace, other)
+
+	wantNoErr(t, "delete user", f.store.Users().Delete(owner.ID))
+
+	wantCount(t, "count topics", f.store.Topics().Count, repository.CountFilter{}, 1)
+	wantCount(t, "count media accounts", f.store.MediaAccounts().Count, repository.CountFilter{}, 0)
+	wantCount(t, "count articles", f.store.Articles().Count, repository.CountFilter{}, 0)
+	wantCount(t, "count reposts", f.store.Reposts().Count, repository.CountFilter{}, 0)
+	if _, err := f.store.Topics().Get(otherWorkspace, kept.ID); err != nil {
+		t.Fatalf("get other user's topic: %v", err)
+	}
+}
+
+func testTopics(t *testing.T, f *fixture) {
+	topics := f.store.Topics()
+	owner := f.user(t, "owner@example.com")
+	workspace := f.workspace(t, owner)
+	otherWorkspace := f.workspace(t, owner)
+
+	first := f.topic(t, workspace, owner)
+	second := f.topic(t, workspace, owner)
+	foreign := f.topic(t, otherWorkspace, owner)
+
+	got, err := topics.Get(workspace, first.ID)
+	wantNoErr(t, "get topic", err)
+	if got.Name != first.Name || len(got.Keywords) != 1 || got.Keywords[0] != "go" || got.WorkspaceID != workspace {
+		t.Fatalf("get topic: got %+v, want %+v", got, first)
+	}
+	_, err = topics.Get(workspace, foreign.ID)
+	wantErr(t, "get topic of another workspace", err, sql.ErrNoRows)
+
+	list, total, err := topics.List(workspace, repository.Page{Limit: 10})
+	wantNoErr(t, "list topics", err)
+	if total != 2 {
+		t.Fatalf("list topics: got total %d, want 2", total)
+	}
+	wantIDs(t, "list topics", list, topicID, second.ID, first.ID)
+
+	description := "Updated"
+	update := &models.Topic{
+		ID: first.ID, Name: "Renamed", Description: &description, Keywords: []string{"go", "sql"},
+		Platforms: []string{"twitter"}, WorkspaceID: workspace, UpdatedAt: f.now(),
+	}
+	wantNoErr(t, "update topic", topics.Update(update))
+	got, err = topics.Get(workspace, first.ID)
+	wantNoErr(t, "get topic", err)
+	if got.Name != "Renamed" || got.Description == nil || *got.Description != description ||
+		len(got.Keywords) != 2 || !got.UpdatedAt.Equal(update.UpdatedAt) || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("update topic: got %+v", got)
+	}
+	update.ID = foreign.ID
+	wantErr(t, "update topic of another workspace", topics.Update(update), sql.ErrNoRows)
+
+	wantCount(t, "count topics", topics.Count, repository.CountFilter{UserID: owner.ID}, 3)
+	wantCount(t, "count new topics", topics.Count, repository.CountFilter{CreatedSince: second.CreatedAt}, 2)
+
+	account := f.account(t, workspace, owner, "twitter")
+	f.repost(t, f.article(t, first, owner, base, "https://example.com/a", nil), account, owner, "pending")
+	f.article(t, second, owner, base, "https://example.com/b", nil)
+
+	wantErr(t, "delete topic of another workspace", topics.Delete(otherWorkspace, first.ID), sql.ErrNoRows)
+	wantNoErr(t, "delete topic", topics.Delete(workspace, first.ID))
+	_, err = topics.Get(workspace, first.ID)
+	wantErr(t, "get deleted topic", err, sql.ErrNoRows)
+	wantCount(t, "count articles", f.store.Articles().Count, repository.CountFilter{}, 1)
+	wantCount(t, "count reposts", f.store.Reposts().Count, repository.CountFilter{}, 0)
+}
+
+func testTopicActivity(t *testing.T, f *fixture) {
+	owner := f.user(t, "owner@example.com")
+	workspace := f.workspace(t, owner)
+	topic := f.topic(t, workspace, owner)
+	other := f.topic(t, workspace, owner)
+	account := f.account(t, workspace, owner, "twitter")
+
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	ann, bob := "Ann", "Bob"
+	f.article(t, topic, owner, since.Add(-time.Hour), "https://old.example.com/1", &ann)
+	first := f.article(t, topic, owner, since.Add(2*time.Hour), "https://News.Example.com/1", &ann)
+	f.article(t, topic, owner, since.Add(26*time.Hour), "http://news.example.com:8080/2?x=1", &bob)
+	f.article(t, topic, owner, since.Add(27*time.Hour), "https://blog.example.org/3", &ann)
+	f.article(t, topic, owner, since.Add(28*time.Hour), "not a url", nil)
+	f.article(t, other, owner, since.Add(time.Hour), "https://other.example.com/1", &bob)
+
+	// f.now() is after since, so the reposts fall into the window.
+	f.repost(t, first, account, owner, "pending")
+	f.repost(t, first, account, owner, "pending")
+	f.repost(t, first, account, owner, "posted")
+
+	stats, err := f.store.Topics().Activity(topic.ID, since, 3, 1)
+	wantNoErr(t, "topic activity", err)
+
+	wantDays := []models.DailyCount{
+		{Date: "2026-03-01", Count: 1}, {Date: "2026-03-02", Count: 3}, {Date: "2026-03-03", Count: 0},
+	}
+	if len(stats.ArticlesPerDay) != len(wantDays) {
+		t.Fatalf("articles per day: got %v, want %v", stats.ArticlesPerDay, wantDays)
+	}
+	for i, day := range wantDays {
+		if stats.ArticlesPerDay[i] != day {
+			t.Fatalf("articles per day: got %v, want %v", stats.ArticlesPerDay, wantDays)
+		}
+	}
+
+	if len(stats.RepostsByStatus) != 2 || stats.RepostsByStatus["pending"] != 2 || stats.RepostsByStatus["posted"] != 1 {
+		t.Fatalf("reposts by status: got %v", stats.RepostsByStatus)
+	}
+	if len(stats.TopSources) != 1 || stats.TopSources[0] != (models.NamedCount{Name: "news.example.com", Count: 2}) {
+		t.Fatalf("top sources: got %v", stats.TopSources)
+	}
+	if len(stats.TopAuthors) != 1 || stats.TopAuthors[0] != (models.NamedCount{Name: "Ann", Count: 2}) {
+		t.Fatalf("top authors: got %v", stats.TopAuthors)
+	}
+
+	stats, err = f.store.Topics().Activity(uuid.New().String(), since, 2, 5)
+	wantNoErr(t, "activity of a topic without articles", err)
+	if len(stats.ArticlesPerDay) != 2 || stats.ArticlesPerDay[1].Count != 0 ||
+		stats.TopSources == nil || len(stats.TopSources) != 0 || stats.TopAuthors == nil || len(stats.RepostsByStatus) != 0 {
+		t.Fatalf("activity of a topic without articles: got %+v", stats)
+	}
+}
+
+func testMediaAccounts(t *testing.T, f *fixture) {
+	accounts := f.store.MediaAccounts()
+	owner := f.user(t, "owner@example.com")
+	workspace := f.workspace(t, owner)
+	otherWorkspace := f.workspace(t, owner)
+
+	twitter := f.account(t, workspace, owner, "twitter")
+	weibo := f.account(t, workspace, owner, "weibo")
+	foreign := f.account(t, otherWorkspace, owner, "twitter")
+
+	got, err := accounts.Get(workspace, twitter.ID)
+	wantNoErr(t, "get media account", err)
+	if got.AccountID != twitter.AccountID || got.Platform != "twitter" || got.AccessToken != nil {
+		t.Fatalf("get media account: got %+v", got)
+	}
+	_, err = accounts.Get(workspace, foreign.ID)
+	wantErr(t, "get media account of another workspace", err, sql.ErrNoRows)
+
+	now := f.now()
+	duplicate := *twitter
+	duplicate.ID = uuid.New().String()
+	duplicate.CreatedAt, duplicate.UpdatedAt = now, now
+	wantErr(t, "create duplicate media account", accounts.Create(&duplicate), repository.ErrDuplicate)
+
+	list, err := accounts.List(workspace, "")
+	wantNoErr(t, "list media accounts", err)
+	wantIDs(t, "list media accounts", list, accountID, weibo.ID, twitter.ID)
+	list, err = accounts.List(workspace, "twitter")
+	wantNoErr(t, "list media accounts of a platform", err)
+	wantIDs(t, "list media accounts of a platform", list, accountID, twitter.ID)
+
+	update := &models.MediaAccount{ID: twitter.ID, AccountName: "Renamed", WorkspaceID: workspace, UpdatedAt: f.now()}
+	wantNoErr(t, "update media account", accounts.Update(update))
+	got, err = accounts.Get(workspace, twitter.ID)
+	wantNoErr(t, "get media account", err)
+	if got.AccountName != "Renamed" || !got.UpdatedAt.Equal(update.UpdatedAt) {
+		t.Fatalf("update media account: got %+v", got)
+	}
+	update.WorkspaceID = otherWorkspace
+	wantErr(t, "update media account of another workspace", accounts.Update(update), sql.ErrNoRows)
+
+	wantCount(t, "count media accounts", accounts.Count, repository.CountFilter{UserID: owner.ID}, 3)
+
+	topic := f.topic(t, workspace, owner)
+	article := f.article(t, topic, owner, base, "https://example.com/a", nil)
+	f.repost(t, article, twitter, owner, "pending")
+	kept := f.repost(t, article, weibo, owner, "pending")
+
+	wantErr(t, "delete media account of another workspace", accounts.Delete(otherWorkspace, twitter.ID), sql.ErrNoRows)
+	wantNoErr(t, "delete media account", accounts.Delete(workspace, twitter.ID))
+	_, err = accounts.Get(workspace, twitter.ID)
+	wantErr(t, "get deleted media account", err, sql.ErrNoRows)
+	reposts, _, err := f.store.Reposts().List(workspace, repository.Page{Limit: 10})
+	wantNoErr(t, "list reposts", err)
+	wantIDs(t, "reposts after deleting a media account", reposts, repostID, kept.ID)
+}
+
+func testArticles(t *testing.T, f *fixture) {
+	articles := f.store.Articles()
+	owner := f.user(t, "owner@example.com")
+	workspace := f.workspace(t, owner)
+	otherWorkspace := f.workspace(t, owner)
+	topic := f.topic(t, workspace, owner)
+	other := f.topic(t, workspace, owner)
+
+	older := f.article(t, topic, owner, base.Add(-2*time.Hour), "https://example.com/1", nil)
+	newer := f.article(t, topic, owner, base.Add(-time.Hour), "https://example.com/2", nil)
+	elsewhere := f.article(t, other, owner, base.Add(-3*time.Hour), "https://example.com/3", nil)
+	foreign := f.article(t, f.topic(t, otherWorkspace, owner), owner, base, "https://example.com/4", nil)
+
+	got, err := articles.Get(workspace, older.ID)
+	wantNoErr(t, "get article", err)
+	if got.State != models.ArticleStateNew || got.StateChangedAt != nil || !got.PublishedAt.Equal(older.PublishedAt) {
+		t.Fatalf("get article: got %+v", got)
+	}
+	_, err = articles.Get(workspace, foreign.ID)
+	wantErr(t, "get article of another workspace", err, sql.ErrNoRows)
+
+	list, total, err := articles.List(workspace, repository.ArticleFilter{}, repository.Page{Limit: 2})
+	wantNoErr(t, "list articles", err)
+	if total != 3 {
+		t.Fatalf("list articles: got total %d, want 3", total)
+	}
+	wantIDs(t, "list articles", list, articleID, newer.ID, older.ID)
+	list, _, err = articles.List(workspace, repository.ArticleFilter{TopicID: other.ID}, repository.Page{Limit: 10})
+	wantNoErr(t, "list articles of a topic", err)
+	wantIDs(t, "list articles of a topic", list, articleID, elsewhere.ID)
+
+	at := f.now()
+	changed, err := articles.SetState(workspace, []string{older.ID, newer.ID, foreign.ID, uuid.New().String()},
+		models.ArticleStateArchived, at)
+	wantNoErr(t, "set state", err)
+	if changed != 2 {
+		t.Fatalf("set state: changed %d, want 2", changed)
+	}
+	changed, err = articles.SetState(workspace, []string{older.ID, elsewhere.ID}, models.ArticleStateArchived, f.now())
+	wantNoErr(t, "set state", err)
+	if changed != 1 {
+		t.Fatalf("set state again: changed %d, want 1", changed)
+	}
+	got, err = articles.Get(workspace, older.ID)
+	wantNoErr(t, "get article", err)
+	if got.State != models.ArticleStateArchived || got.StateChangedAt == nil || !got.StateChangedAt.Equal(at) {
+		t.Fatalf("set state: got %+v", got)
+	}
+	got, err = articles.Get(otherWorkspace, foreign.ID)
+	wantNoErr(t, "get article", err)
+	if got.State != models.ArticleStateNew {
+		t.Fatalf("set state changed an article of another workspace")
+	}
+
+	list, total, err = articles.List(workspace, repository.ArticleFilter{State: models.ArticleStateNew}, repository.Page{Limit: 10})
+	wantNoErr(t, "list new articles", err)
+	if total != 0 || len(list) != 0 {
+		t.Fatalf("list new articles: got %d of %d", len(list), total)
+	}
+
+	update := &models.Article{ID: newer.ID, Title: "Edited", Content: "Edited", WorkspaceID: workspace, UpdatedAt: f.now()}
+	wantNoErr(t, "update article", articles.Update(update))
+	got, err = articles.Get(workspace, newer.ID)
+	wantNoErr(t, "get article", err)
+	if got.Title != "Edited" || got.Content != "Edited" || got.OriginalURL != newer.OriginalURL {
+		t.Fatalf("update article: got %+v", got)
+	}
+	update.WorkspaceID = otherWorkspace
+	wantErr(t, "update article of another workspace", articles.Update(update), sql.ErrNoRows)
+
+	wantCount(t, "count articles", articles.Count, repository.CountFilter{UserID: owner.ID}, 4)
+	wantCount(t, "count new articles", articles.Count, repository.CountFilter{CreatedSince: elsewhere.CreatedAt}, 2)
+
+	f.repost(t, newer, f.account(t, workspace, owner, "twitter"), owner, "pending")
+	wantErr(t, "delete article of another workspace", articles.Delete(otherWorkspace, newer.ID), sql.ErrNoRows)
+	wantNoErr(t, "delete article", articles.Delete(workspace, newer.ID))
+	_, err = articles.Get(workspace, newer.ID)
+	wantErr(t, "get deleted article", err, sql.ErrNoRows)
+	wantCount(t, "count reposts", f.store.Reposts().Count, repository.CountFilter{}, 0)
+}
+
+func testReposts(t *testing.T, f *fixture) {
+	reposts := f.store.Reposts()
+	owner := f.user(t, "owner@example.com")
+	editor := f.user(t, "editor@example.com")
+	workspace := f.workspace(t, owner)
+	otherWorkspace := f.workspace(t, owner)
+
+	article := f.article(t, f.topic(t, workspace, owner), owner, base, "https://example.com/a", nil)
+	account := f.account(t, workspace, owner, "twitter")
+	first := f.repost(t, article, account, owner, "pending")
+	second := f.repost(t, article, account, editor, "posted")
+	foreignArticle := f.article(t, f.topic(t, otherWorkspace, owner), owner, base, "https://example.com/b", nil)
+	foreign := f.repost(t, foreignArticle, f.account(t, otherWorkspace, owner, "twitter"), owner, "pending")
+
+	got, err := reposts.Get(first.ID)
+	wantNoErr(t, "get repost", err)
+	if got.ArticleID != article.ID || got.MediaAccountID != account.ID || got.Status != "pending" ||
+		got.ApprovalStatus != models.ApprovalNotRequired || got.PostedAt != nil || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("get repost: got %+v", got)
+	}
+	if _, err := reposts.Get(foreign.ID); err != nil {
+		t.Fatalf("get repost of another workspace: %v", err)
+	}
+	_, err = reposts.Get(uuid.New().String())
+	wantErr(t, "get missing repost", err, sql.ErrNoRows)
+
+	list, total, err := reposts.List(workspace, repository.Page{Limit: 10})
+	wantNoErr(t, "list reposts", err)
+	if total != 2 {
+		t.Fatalf("list reposts: got total %d, want 2", total)
+	}
+	wantIDs(t, "list reposts", list, repostID, second.ID, first.ID)
+
+	wantCount(t, "count reposts", reposts.Count, repository.CountFilter{}, 3)
+	wantCount(t, "count reposts of a user", reposts.Count, repository.CountFilter{UserID: editor.ID}, 1)
+	wantCount(t, "count new reposts of a user", reposts.Count,
+		repository.CountFilter{UserID: owner.ID, CreatedSince: second.CreatedAt}, 1)
+}
+
+func (f *fixture) apiKey(t *testing.T, user *models.User, hash string) *models.APIKey {
+	t.Helper()
+	now := f.now()
+	key := &models.APIKey{
+		ID: uuid.New().String(), UserID: user.ID, Name: "CI", Prefix: "smg_" + hash[:8],
+		Scopes: []string{"articles:read"}, ExpiresAt: now.Add(24 * time.Hour), CreatedAt: now,
+	}
+	if err := f.store.APIKeys().Create(key, hash); err != nil {
+		t.Fatalf("create API key: %v", err)
+	}
+	return key
+}
+
+func testAPIKeys(t *testing.T, f *fixture) {
+	keys := f.store.APIKeys()
+	owner := f.user(t, "owner@example.com")
+	other := f.user(t, "other@example.com")
+	first := f.apiKey(t, owner, "hash-first")
+	second := f.apiKey(t, owner, "hash-second")
+	foreign := f.apiKey(t, other, "hash-foreign")
+
+	got, err := keys.GetByHash("hash-first")
+	wantNoErr(t, "get API key by hash", err)
+	if got.ID != first.ID || got.UserID != owner.ID || len(got.Scopes) != 1 || got.Scopes[0] != "articles:read" ||
+		got.LastUsedAt != nil || !got.ExpiresAt.Equal(first.ExpiresAt) || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("get API key by hash: got %+v, want %+v", got, first)
+	}
+	_, err = keys.GetByHash("hash-missing")
+	wantErr(t, "get API key by unknown hash", err, sql.ErrNoRows)
+
+	now := f.now()
+	duplicate := &models.APIKey{ID: uuid.New().String(), UserID: owner.ID, Name: "CI", Prefix: "smg_dup",
+		ExpiresAt: now, CreatedAt: now}
+	wantErr(t, "create API key with taken hash", keys.Create(duplicate, "hash-first"), repository.ErrDuplicate)
+
+	list, err := keys.List(owner.ID)
+	wantNoErr(t, "list API keys", err)
+	wantIDs(t, "list API keys", list, apiKeyID, second.ID, first.ID)
+
+	usedAt := f.now()
+	wantNoErr(t, "touch API key", keys.Touch(first.ID, usedAt))
+	got, err = keys.GetByHash("hash-first")
+	wantNoErr(t, "get API key by hash", err)
+	if got.LastUsedAt == nil || !got.LastUsedAt.Equal(usedAt) {
+		t.Fatalf("touch API key: got last used at %v, want %v", got.LastUsedAt, usedAt)
+	}
+	wantErr(t, "touch missing API key", keys.Touch(uuid.New().String(), usedAt), sql.ErrNoRows)
+
+	wantErr(t, "delete another user's API key", keys.Delete(owner.ID, foreign.ID), sql.ErrNoRows)
+	wantNoErr(t, "delete API key", keys.Delete(owner.ID, first.ID))
+	_, err = keys.GetByHash("hash-first")
+	wantErr(t, "get deleted API key", err, sql.ErrNoRows)
+
+	wantNoErr(t, "delete user", f.store.Users().Delete(owner.ID))
+	list, err = keys.List(owner.ID)
+	wantNoErr(t, "list deleted user's API keys", err)
+	wantIDs(t, "list deleted user's API keys", list, apiKeyID)
+	if _, err := keys.GetByHash("hash-foreign"); err != nil {
+		t.Fatalf("get other user's API key: %v", err)
+	}
+}
+
+func (f *fixture) policy(t *testing.T, user *models.User, account *models.MediaAccount, topic *models.Topic, approverIDs ...string) *models.ApprovalPolicy {
+	t.Helper()
+	now := f.now()
+	policy := &models.ApprovalPolicy{
+		ID: uuid.New().String(), ApproverIDs: approverIDs, UserID: user.ID, CreatedAt: now, UpdatedAt: now,
+	}
+	if account != nil {
+		policy.MediaAccountID = &account.ID
+	}
+	if topic != nil {
+		policy.TopicID = &topic.ID
+	}
+	if err := f.store.Approvals().CreatePolicy(policy); err != nil {
+		t.Fatalf("create approval policy: %v", err)
+	}
+	return policy
+}
+
+func testApprovalPolicies(t *testing.T, f *fixture) {
+	approvals := f.store.Approvals()
+	owner := f.user(t, "owner@example.com")
+	other := f.user(t, "other@example.com")
+	workspace := f.workspace(t, owner)
+	topic := f.topic(t, workspace, owner)
+	account := f.account(t, workspace, owner, "twitter")
+
+	first := f.policy(t, owner, account, nil, other.ID)
+	second := f.policy(t, owner, nil, topic)
+	foreign := f.policy(t, other, account, nil)
+
+	got, err := approvals.Policy(owner.ID, first.ID)
+	wantNoErr(t, "get policy", err)
+	if got.MediaAccountID == nil || *got.MediaAccountID != account.ID || got.TopicID != nil ||
+		len(got.ApproverIDs) != 1 || got.ApproverIDs[0] != other.ID || got.AllowAdmins ||
+		got.UserID != owner.ID || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("get policy: got %+v, want %+v", got, first)
+	}
+	_, err = approvals.Policy(owner.ID, foreign.ID)
+	wantErr(t, "get another user's policy", err, sql.ErrNoRows)
+	if _, err := approvals.Policy("", foreign.ID); err != nil {
+		t.Fatalf("get another user's policy without an owner: %v", err)
+	}
+
+	list, err := approvals.Policies(owner.ID)
+	wantNoErr(t, "list policies", err)
+	wantIDs(t, "list policies", list, policyID, second.ID, first.ID)
+
+	update := *first
+	update.MediaAccountID = nil
+	update.TopicID = &topic.ID
+	update.ApproverIDs = []string{owner.ID, other.ID}
+	update.AllowAdmins = true
+	update.UpdatedAt = f.now()
+	wantNoErr(t, "update policy", approvals.UpdatePolicy(owner.ID, &update))
+	got, err = approvals.Policy(owner.ID, first.ID)
+	wantNoErr(t, "get policy", err)
+	if got.MediaAccountID != nil || got.TopicID == nil || *got.TopicID != topic.ID || len(got.ApproverIDs) != 2 ||
+		!got.AllowAdmins || !got.UpdatedAt.Equal(update.UpdatedAt) || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("update policy: got %+v", got)
+	}
+	update.ID = foreign.ID
+	wantErr(t, "update another user's policy", approvals.UpdatePolicy(owner.ID, &update), sql.ErrNoRows)
+
+	wantErr(t, "delete another user's policy", approvals.DeletePolicy(owner.ID, foreign.ID), sql.ErrNoRows)
+	wantNoErr(t, "delete policy", approvals.DeletePolicy(owner.ID, first.ID))
+	_, err = approvals.Policy(owner.ID, first.ID)
+	wantErr(t, "get deleted policy", err, sql.ErrNoRows)
+	wantNoErr(t, "delete another user's policy without an owner", approvals.DeletePolicy("", foreign.ID))
+
+	wantNoErr(t, "delete topic", f.store.Topics().Delete(workspace, topic.ID))
+	_, err = approvals.Policy(owner.ID, second.ID)
+	wantErr(t, "get policy of deleted topic", err, sql.ErrNoRows)
+}
+
+func testApprovalReviews(t *testing.T, f *fixture) {
+	approvals := f.store.Approvals()
+	owner := f.user(t, "owner@example.com")
+	approver := f.user(t, "approver@example.com")
+	workspace := f.workspace(t, owner)
+	topic := f.topic(t, workspace, owner)
+	otherTopic := f.topic(t, workspace, owner)
+	account := f.account(t, workspace, owner, "twitter")
+	otherAccount := f.account(t, workspace, owner, "twitter")
+	article := f.article(t, topic, owner, base, "https://example.com/a", nil)
+	otherArticle := f.article(t, otherTopic, owner, base, "https://example.com/b", nil)
+
+	covered, err := approvals.Covered(article.ID, account.ID)
+	wantNoErr(t, "covered without policies", err)
+	if covered {
+		t.Fatal("covered without policies: got true")
+	}
+	f.policy(t, owner, account, nil, approver.ID)
+	admins := f.policy(t, owner, nil, topic)
+	admins.AllowAdmins = true
+	wantNoErr(t, "update policy", approvals.UpdatePolicy("", admins))
+
+	for _, c := range []struct {
+		article, account string
+		want             bool
+	}{
+		{article.ID, account.ID, true},
+		{article.ID, otherAccount.ID, true},
+		{otherArticle.ID, account.ID, true},
+		{otherArticle.ID, otherAccount.ID, false},
+	} {
+		covered, err := approvals.Covered(c.article, c.account)
+		wantNoErr(t, "covered", err)
+		if covered != c.want {
+			t.Fatalf("covered(%s, %s): got %v, want %v", c.article, c.account, covered, c.want)
+		}
+	}
+
+	byAccount := f.repostAwaiting(t, otherArticle, account, owner, "pending", models.ApprovalPending)
+	byTopic := f.repostAwaiting(t, article, otherAccount, owner, "pending", models.ApprovalPending)
+	uncovered := f.repostAwaiting(t, otherArticle, otherAccount, owner, "pending", models.ApprovalPending)
+	f.repost(t, article, account, owner, "pending")
+
+	for _, c := range []struct {
+		repost   string
+		reviewer string
+		admin    bool
+		want     bool
+	}{
+		{byAccount.ID, approver.ID, false, true},
+		{byAccount.ID, owner.ID, true, false},
+		{byTopic.ID, approver.ID, false, false},
+		{byTopic.ID, owner.ID, true, true},
+		{uncovered.ID, approver.ID, true, false},
+		{uuid.New().String(), approver.ID, true, false},
+	} {
+		allowed, err := approvals.CanReview(c.repost, c.reviewer, c.admin)
+		wantNoErr(t, "can review", err)
+		if allowed != c.want {
+			t.Fatalf("can review(%s, %s, %v): got %v, want %v", c.repost, c.reviewer, c.admin, allowed, c.want)
+		}
+	}
+
+	pending, err := approvals.Pending(approver.ID, false)
+	wantNoErr(t, "pending for approver", err)
+	wantIDs(t, "pending for approver", pending, repostID, byAccount.ID)
+	pending, err = approvals.Pending(approver.ID, true)
+	wantNoErr(t, "pending for admin approver", err)
+	wantIDs(t, "pending for admin approver", pending, repostID, byAccount.ID, byTopic.ID)
+
+	comment := "Looks good"
+	approval := &models.RepostApproval{
+		ID: uuid.New().String(), RepostID: byAccount.ID, ReviewerID: approver.ID,
+		Decision: models.ApprovalApproved, Comment: &comment, CreatedAt: f.now(),
+	}
+	wantNoErr(t, "review repost", approvals.Review(approval, "pending"))
+	got, err := f.store.Reposts().Get(byAccount.ID)
+	wantNoErr(t, "get reviewed repost", err)
+	if got.ApprovalStatus != models.ApprovalApproved || got.Status != "pending" || got.ApprovedBy == nil ||
+		*got.ApprovedBy != approver.ID || got.ApprovedAt == nil || !got.ApprovedAt.Equal(approval.CreatedAt) ||
+		!got.UpdatedAt.Equal(approval.CreatedAt) {
+		t.Fatalf("review repost: got %+v", got)
+	}
+
+	again := *approval
+	again.ID = uuid.New().String()
+	again.Decision = models.ApprovalRejected
+	wantErr(t, "review reviewed repost", approvals.Review(&again, "rejected"), sql.ErrNoRows)
+	again.RepostID = uuid.New().String()
+	wantErr(t, "review missing repost", approvals.Review(&again, "rejected"), sql.ErrNoRows)
+
+	rejection := &models.RepostApproval{
+		ID: uuid.New().String(), RepostID: byTopic.ID, ReviewerID: owner.ID,
+		Decision: models.ApprovalRejected, CreatedAt: f.now(),
+	}
+	wantNoErr(t, "reject repost", approvals.Review(rejection, "rejected"))
+	got, err = f.store.Reposts().Get(byTopic.ID)
+	wantNoErr(t, "get rejected repost", err)
+	if got.ApprovalStatus != models.ApprovalRejected || got.Status != "rejected" {
+		t.Fatalf("reject repost: got %+v", got)
+	}
+
+	pending, err = approvals.Pending(owner.ID, true)
+	wantNoErr(t, "pending after reviews", err)
+	wantIDs(t, "pending after reviews", pending, repostID)
+
+	decisions, err := approvals.Decisions(byAccount.ID)
+	wantNoErr(t, "decisions", err)
+	if len(decisions) != 1 || decisions[0].ID != approval.ID || decisions[0].ReviewerID != approver.ID ||
+		decisions[0].Decision != models.ApprovalApproved || decisions[0].Comment == nil ||
+		*decisions[0].Comment != comment || !decisions[0].CreatedAt.Equal(approval.CreatedAt) {
+		t.Fatalf("decisions: got %+v", decisions)
+	}
+
+	wantNoErr(t, "delete reviewer", f.store.Users().Delete(approver.ID))
+	decisions, err = approvals.Decisions(byAccount.ID)
+	wantNoErr(t, "decisions of deleted reviewer", err)
+	if len(decisions) != 0 {
+		t.Fatalf("decisions of deleted reviewer: got %+v", decisions)
+	}
+	wantNoErr(t, "delete article", f.store.Articles().Delete(workspace, article.ID))
+	decisions, err = approvals.Decisions(byTopic.ID)
+	wantNoErr(t, "decisions of deleted repost", err)
+	if len(decisions) != 0 {
+		t.Fatalf("decisions of deleted repost: got %+v", decisions)
+	}
+}
+
+func testSettings(t *testing.T, f *fixture) {
+	settings := f.store.Settings()
+
+	all, err := settings.All()
+	wantNoErr(t, "all settings", err)
+	if len(all) != 0 {
+		t.Fatalf("all settings: got %v, want none", all)
+	}
+
+	wantNoErr(t, "put setting", settings.Put("site_name", "SMG"))
+	wantNoErr(t, "put setting", settings.Put("signup", "open"))
+	wantNoErr(t, "overwrite setting", settings.Put("signup", "closed"))
+
+	all, err = settings.All()
+	wantNoErr(t, "all settings", err)
+	if len(all) != 2 || all["site_name"] != "SMG" || all["signup"] != "closed" {
+		t.Fatalf("all settings: got %v", all)
+	}
+
+	found, err := settings.Lookup([]string{"signup", "missing"})
+	wantNoErr(t, "look up settings", err)
+	if len(found) != 1 || found["signup"] != "closed" {
+		t.Fatalf("look up settings: got %v", found)
+	}
+}
+
+func testPlatforms(t *testing.T, f *fixture) {
+	platforms := f.store.Platforms()
+	create := func(name, displayName string) *models.Platform {
+		t.Helper()
+		now := f.now()
+		platform := &models.Platform{
+			ID: uuid.New().String(), Name: name, DisplayName: displayName, Enabled: true, Config: "{}",
+			CreatedAt: now, UpdatedAt: now,
+		}
+		wantNoErr(t, "create platform", platforms.Create(platform))
+		return platform
+	}
+
+	weibo := create("weibo", "Weibo")
+	twitter := create("twitter", "Twitter")
+
+	now := f.now()
+	duplicate := &models.Platform{ID: uuid.New().String(), Name: "weibo", DisplayName: "Other", CreatedAt: now, UpdatedAt: now}
+	wantErr(t, "create platform with taken name", platforms.Create(duplicate), repository.ErrDuplicate)
+
+	list, err := platforms.List()
+	wantNoErr(t, "list platforms", err)
+	wantIDs(t, "list platforms", list, platformID, twitter.ID, weibo.ID)
+
+	update := &models.Platform{ID: weibo.ID, Name: "sina", DisplayName: "Sina Weibo", Config: `{"a":1}`, UpdatedAt: f.now()}
+	wantNoErr(t, "update platform", platforms.Update(update))
+	got, err := platforms.Get(weibo.ID)
+	wantNoErr(t, "get platform", err)
+	if got.Name != "sina" || got.DisplayName != "Sina Weibo" || got.Enabled || got.Config != `{"a":1}` ||
+		!got.CreatedAt.Equal(weibo.CreatedAt) || !got.UpdatedAt.Equal(update.UpdatedAt) {
+		t.Fatalf("update platform: got %+v", got)
+	}
+
+	update.Name = "twitter"
+	wantErr(t, "rename platform to a taken name", platforms.Update(update), repository.ErrDuplicate)
+	update.ID = uuid.New().String()
+	update.Name = "new"
+	wantErr(t, "update missing platform", platforms.Update(update), sql.ErrNoRows)
+
+	wantNoErr(t, "delete platform", platforms.Delete(weibo.ID))
+	_, err = platforms.Get(weibo.ID)
+	wantErr(t, "get deleted platform", err, sql.ErrNoRows)
+	wantErr(t, "delete missing platform", platforms.Delete(weibo.ID), sql.ErrNoRows)
+}
+
+func testInTx(t *testing.T, f *fixture) {
+	owner := f.user(t, "owner@example.com")
+	workspace := f.workspace(t, owner)
+
+	var committed *models.Topic
+	err := f.store.InTx(func(tx repository.Store) error {
+		committed = f.topicIn(t, tx, workspace, owner)
+		wantNoErr(t, "put setting", tx.Settings().Put("signup", "open"))
+		return tx.Audit().Record(models.AuditEvent{Action: "test.committed", ResourceType: "topic", ResourceID: committed.ID})
+	})
+	wantNoErr(t, "committed transaction", err)
+	if _, err := f.store.Topics().Get(workspace, committed.ID); err != nil {
+		t.Fatalf("get topic created in a committed transaction: %v", err)
+	}
+
+	failure := errors.New("failure")
+	var discarded *models.Topic
+	err = f.store.InTx(func(tx repository.Store) error {
+		discarded = f.topicIn(t, tx, workspace, owner)
+		if _, err := tx.Topics().Get(workspace, discarded.ID); err != nil {
+			t.Fatalf("get topic in its transaction: %v", err)
+		}
+		wantNoErr(t, "delete topic", tx.Topics().Delete(workspace, committed.ID))
+		return tx.InTx(func(nested repository.Store) error {
+			wantNoErr(t, "put setting", nested.Settings().Put("signup", "closed"))
+			return failure
+		})
+	})
+	wantErr(t, "rolled back transaction", err, failure)
+
+	_, err = f.store.Topics().Get(workspace, discarded.ID)
+	wantErr(t, "get topic created in a rolled back transaction", err, sql.ErrNoRows)
+	if _, err := f.store.Topics().Get(workspace, committed.ID); err != nil {
+		t.Fatalf("get topic deleted in a rolled back transaction: %v", err)
+	}
+	all, err := f.store.Settings().All()
+	wantNoErr(t, "all settings", err)
+	if all["signup"] != "open" {
+		t.Fatalf("setting changed in a rolled back transaction: got %q", all["signup"])
+	}
+}
+
+// topicIn creates a topic through store, which may be a transaction.
+func (f *fixture) topicIn(t *testing.T, store repository.Store, workspaceID string, user *models.User) *models.Topic {
+	t.Helper()
+	outer := f.store
+	f.store = store
+	defer func() { f.store = outer }()
+	return f.topic(t, workspaceID, user)
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/rbac"
+	"smg/pkg/repository"
+)
+
+// APIKeyPrefix starts every API key, so keys are recognisable in an
+// Authorization header and by secret scanners.
+const APIKeyPrefix = "smg_"
+
+const (
+	defaultAPIKeyExpiry = time.Hour * 24 * 90
+	// apiKeyDisplayLength is how much of the key is kept as its prefix.
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+)
+
+var (
+	// ErrInvalidAPIKey is returned for unknown, expired or revoked keys.
+	ErrInvalidAPIKey = apperr.Unauthorized("invalid_api_key", "Invalid API key")
+	// ErrAPIKeyScope is returned when a key would get a scope its owner's
+	// role does not grant.
+	ErrAPIKeyScope = apperr.BadRequest("api_key_scope_not_granted", "Scope {scope} not granted by your role")
+)
+
+// APIKeyService manages personal API keys. Only a SHA-256 hash of each key
+// is stored. A key acts as its owner, limited to the key's scopes.
+type APIKeyService struct {
+	store       repository.Store
+	redisClient *redis.Client
+}
+
+func NewAPIKeyService(store repository.Store, redisClient *redis.Client) *APIKeyService {
+	return &APIKeyService{
+		store:       store,
+		redisClient: redisClient,
+	}
+}
+
+// CreateAPIKey creates a key for the actor and returns it with the key
+// itself, which cannot be retrieved again.
+func (s *APIKeyService) CreateAPIKey(actor *models.User, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	granted := rbac.Permissions(actor.Role)
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !rbac.Has(granted, scope) {
+			return nil, ErrAPIKeyScope.With("scope", scope)
+		}
+		if !rbac.Has(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	key := APIKeyPrefix + secret
+
+	expiry := defaultAPIKeyExpiry
+	if req.ExpiresInDays > 0 {
+		expiry = time.Duration(req.ExpiresInDays) * time.Hour * 24
+	}
+
+	now := time.Now()
+	apiKey := models.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    actor.ID,
+		Name:      req.Name,
+		Prefix:    key[:apiKeyDisplayLength],
+		Scopes:    scopes,
+		ExpiresAt: now.Add(expiry),
+		CreatedAt: now,
+	}
+
+	err = s.store.InTx(func(store repository.Store) error {
+		if err := store.APIKeys().Create(&apiKey, hashAPIKey(key)); err != nil {
+			return err
+		}
+
+		event := actorEvent(actor, AuditAuthAPIKeyCreated, "api_key", apiKey.ID)
+		event.After = map[string]interface{}{
+			"name":       apiKey.Name,
+			"prefix":     apiKey.Prefix,
+			"scopes":     apiKey.Scopes,
+			"expires_at": apiKey.ExpiresAt,
+		}
+		return store.Audit().Record(event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CreateAPIKeyResponse{APIKey: apiKey, Key: key}, nil
+}
+
+// GetAPIKeys lists the actor's keys, newest first.
+func (s *APIKeyService) GetAPIKeys(actor *models.User) ([]models.APIKey, error) {
+	keys, err := s.store.APIKeys().List(actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey deletes one of the actor's keys.
+func (s *APIKeyService) RevokeAPIKey(actor *models.User, keyID string) error {
+	return s.store.InTx(func(store repository.Store) error {
+		if err := store.APIKeys().Delete(actor.ID, keyID); err != nil {
+			return notFound("api_key", err)
+		}
+
+		return store.Audit().Record(actorEvent(actor, AuditAuthAPIKeyRevoked, "api_key", keyID))
+	})
+}
+
+// Authenticate resolves a key to its owner and the key's record.
+func (s *APIKeyService) Authenticate(key string) (*models.User, *models.APIKey, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.store.APIKeys().GetByHash(hashAPIKey(key))
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if time.Now().After(apiKey.ExpiresAt) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := NewUserService(s.store).GetProfile(apiKey.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Last-used tracking is best effort and must not fail the request.
+	s.touchAPIKey(apiKey.ID)
+
+	return user, apiKey, nil
+}
+
+// touchAPIKey records that the key was used, at most once per
+// lastSeenInterval.
+func (s *APIKeyService) touchAPIKey(keyID string) error {
+	ctx := context.Background()
+	first, err := s.redisClient.SetNX(ctx, apiKeySeenKey(keyID), "1", lastSeenInterval).Result()
+	if err != nil || !first {
+		return err
+	}
+
+	return s.store.APIKeys().Touch(keyID, time.Now())
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func apiKeySeenKey(keyID string) string {
+	return fmt.Sprintf("auth:apikey:seen:%s", keyID)
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/rbac"
+	"smg/pkg/repository/memory"
+)
+
+func TestAPIKeyServiceLifecycle(t *testing.T) {
+	store := memory.New()
+	keys := NewAPIKeyService(store, offlineRedis(t))
+	viewer := storedUser(t, store, "viewer", rbac.RoleViewer)
+
+	_, err := keys.CreateAPIKey(viewer, &models.CreateAPIKeyRequest{Name: "CI", Scopes: []string{rbac.ArticlesWrite}})
+	if !errors.Is(err, ErrAPIKeyScope) {
+		t.Fatalf("key with a scope the role lacks: got %v, want %v", err, ErrAPIKeyScope)
+	}
+
+	created, err := keys.CreateAPIKey(viewer, &models.CreateAPIKeyRequest{
+		Name: "CI", Scopes: []string{rbac.ArticlesRead, rbac.ArticlesRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created.Scopes) != 1 || created.Prefix != created.Key[:apiKeyDisplayLength] {
+		t.Fatalf("created key: got %+v", created.APIKey)
+	}
+
+	user, key, err := keys.Authenticate(created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != viewer.ID || key.ID != created.ID {
+		t.Fatalf("authenticated as user %s with key %s", user.ID, key.ID)
+	}
+	if _, _, err := keys.Authenticate(created.Key + "x"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("unknown key: got %v, want %v", err, ErrInvalidAPIKey)
+	}
+
+	list, err := keys.GetAPIKeys(viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != created.ID {
+		t.Fatalf("listed keys: got %+v", list)
+	}
+
+	other := storedUser(t, store, "other", rbac.RoleEditor)
+	if err := keys.RevokeAPIKey(other, created.ID); !errors.Is(err, apperr.NotFound("api_key")) {
+		t.Fatalf("revoke by another user: got %v, want api_key_not_found", err)
+	}
+	if err := keys.RevokeAPIKey(viewer, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := keys.Authenticate(created.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("revoked key: got %v, want %v", err, ErrInvalidAPIKey)
+	}
+
+	events := store.AuditEvents()
+	if len(events) != 2 || events[0].Action != AuditAuthAPIKeyCreated || events[1].Action != AuditAuthAPIKeyRevoked {
+		t.Fatalf("unexpected audit events %+v", events)
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"smg/pkg/apperr"
+	"smg/pkg/logging"
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+var (
+	ErrPolicyTargetRequired = apperr.New(apperr.KindValidation, "policy_target_required", "Approval policy needs a media_account_id or a topic_id")
+	ErrNotApprover          = apperr.Forbidden("not_approver", "User is not an approver for this repost")
+	ErrRepostNotPending     = apperr.Conflict("repost_not_pending", "Repost is not awaiting approval")
+)
+
+type ApprovalService struct {
+	store repository.Store
+}
+
+func NewApprovalService(store repository.Store) *ApprovalService {
+	return &ApprovalService{store: store}
+}
+
+func (s *ApprovalService) GetPolicies(userID string) ([]models.ApprovalPolicy, error) {
+	return s.store.Approvals().Policies(userID)
+}
+
+func (s *ApprovalService) CreatePolicy(actor *models.User, req *models.ApprovalPolicyRequest) (*models.ApprovalPolicy, error) {
+	if err := s.checkPolicyTargets(actor, req); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	policy := &models.ApprovalPolicy{
+		ID:             uuid.New().String(),
+		MediaAccountID: req.MediaAccountID,
+		TopicID:        req.TopicID,
+		ApproverIDs:    req.ApproverIDs,
+		// Like the column default, admins may approve unless told otherwise.
+		AllowAdmins: req.AllowAdmins == nil || *req.AllowAdmins,
+		UserID:      actor.ID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := s.store.Approvals().CreatePolicy(policy); err != nil {
+		return nil, err
+	}
+
+	return s.GetPolicy(actor, policy.ID)
+}
+
+func (s *ApprovalService) GetPolicy(actor *models.User, policyID string) (*models.ApprovalPolicy, error) {
+	policy, err := s.store.Approvals().Policy(policyOwner(actor), policyID)
+	if err != nil {
+		return nil, notFound("approval_policy", err)
+	}
+
+	return policy, nil
+}
+
+func (s *ApprovalService) UpdatePolicy(actor *models.User, policyID string, req *models.ApprovalPolicyRequest) (*models.ApprovalPolicy, error) {
+	policy, err := s.GetPolicy(actor, policyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPolicyTargets(actor, req); err != nil {
+		return nil, err
+	}
+
+	policy.MediaAccountID = req.MediaAccountID
+	policy.TopicID = req.TopicID
+	policy.ApproverIDs = req.ApproverIDs
+	if req.AllowAdmins != nil {
+		policy.AllowAdmins = *req.AllowAdmins
+	}
+	policy.UpdatedAt = time.Now()
+
+	if err := s.store.Approvals().UpdatePolicy(policyOwner(actor), policy); err != nil {
+		return nil, notFound("approval_policy", err)
+	}
+
+	return s.GetPolicy(actor, policyID)
+}
+
+func (s *ApprovalService) DeletePolicy(actor *models.User, policyID string) error {
+	return notFound("approval_policy", s.store.Approvals().DeletePolicy(policyOwner(actor), policyID))
+}
+
+// GetPendingApprovals returns the reposts awaiting a decision that the
+// reviewer is allowed to approve, oldest first.
+func (s *ApprovalService) GetPendingApprovals(reviewer *models.User) ([]models.Repost, error) {
+	return s.store.Approvals().Pending(reviewer.ID, reviewer.IsAdmin)
+}
+
+func (s *ApprovalService) ApproveRepost(ctx context.Context, repostID string, reviewer *models.User, comment *string) (*models.Repost, error) {
+	return s.reviewRepost(ctx, repostID, reviewer, models.ApprovalApproved, comment)
+}
+
+func (s *ApprovalService) RejectRepost(ctx context.Context, repostID string, reviewer *models.User, comment *string) (*models.Repost, error) {
+	return s.reviewRepost(ctx, repostID, reviewer, models.ApprovalRejected, comment)
+}
+
+// GetRepostApprovals returns the decision history of a repost. It is visible
+// to the repost owner and to anyone allowed to approve it.
+func (s *ApprovalService) GetRepostApprovals(actor *models.User, repostID string) ([]models.RepostApproval, error) {
+	repost, err := s.store.Reposts().Get(repostID)
+	if err != nil {
+		return nil, notFound("repost", err)
+	}
+
+	if requireSelf(actor, repost.UserID) != nil {
+		allowed, err := s.store.Approvals().CanReview(repostID, actor.ID, actor.IsAdmin)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, notFound("repost", sql.ErrNoRows)
+		}
+	}
+
+	return s.store.Approvals().Decisions(repostID)
+}
+
+func (s *ApprovalService) reviewRepost(ctx context.Context, repostID string, reviewer *models.User, decision string, comment *string) (*models.Repost, error) {
+	err := s.store.InTx(func(store repository.Store) error {
+		repost, err := store.Reposts().Get(repostID)
+		if err != nil {
+			return notFound("repost", err)
+		}
+
+		allowed, err := store.Approvals().CanReview(repostID, reviewer.ID, reviewer.IsAdmin)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			// Only the owner learns that the repost exists.
+			if err := requireSelf(reviewer, repost.UserID); err != nil {
+				return notFound("repost", err)
+			}
+			return ErrNotApprover
+		}
+		if repost.ApprovalStatus != models.ApprovalPending {
+			return ErrRepostNotPending
+		}
+
+		// A rejected repost also leaves the publishing queue for good.
+		status := "pending"
+		if decision == models.ApprovalRejected {
+			status = "rejected"
+		}
+
+		err = store.Approvals().Review(&models.RepostApproval{
+			ID:         uuid.New().String(),
+			RepostID:   repostID,
+			ReviewerID: reviewer.ID,
+			Decision:   decision,
+			Comment:    comment,
+			CreatedAt:  time.Now(),
+		}, status)
+		if err == sql.ErrNoRows {
+			// Another reviewer decided first.
+			return ErrRepostNotPending
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("repost reviewed", "repost_id", repostID, "decision", decision)
+
+	return NewArticleService(s.store, s).GetRepost(repostID)
+}
+
+// checkPolicyTargets validates that a policy names at least one target and
+// that every target is in the actor's active workspace.
+func (s *ApprovalService) checkPolicyTargets(actor *models.User, req *models.ApprovalPolicyRequest) error {
+	if req.MediaAccountID == nil && req.TopicID == nil {
+		return ErrPolicyTargetRequired
+	}
+	if req.MediaAccountID != nil {
+		if _, err := s.store.MediaAccounts().Get(actor.WorkspaceID, *req.MediaAccountID); err != nil {
+			return notFound("media_account", err)
+		}
+	}
+	if req.TopicID != nil {
+		if _, err := s.store.Topics().Get(actor.WorkspaceID, *req.TopicID); err != nil {
+			return notFound("topic", err)
+		}
+	}
+
+	return nil
+}
+
+// policyOwner restricts policy lookups to the actor's own policies, unless
+// the actor is an admin.
+func policyOwner(actor *models.User) string {
+	if actor.IsAdmin {
+		return ""
+	}
+	return actor.ID
+}
+
+// RequiresApproval reports whether a repost of the article to the media
+// account is covered by any approval policy.
+func (s *ApprovalService) RequiresApproval(articleID, mediaAccountID string) (bool, error) {
+	return s.store.Approvals().Covered(articleID, mediaAccountID)
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/repository/memory"
+)
+
+func TestApprovalPolicyAllowAdminsDefaultsAndPersists(t *testing.T) {
+	store := memory.New()
+	approvals := NewApprovalService(store)
+	owner := testActor("owner", "workspace")
+	_, media := articleFixture(t, store, owner)
+	account := media.ID
+
+	policy, err := approvals.CreatePolicy(owner, &models.ApprovalPolicyRequest{MediaAccountID: &account})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !policy.AllowAdmins {
+		t.Fatal("policy created without allow_admins does not allow admins")
+	}
+
+	denied := false
+	policy, err = approvals.UpdatePolicy(owner, policy.ID, &models.ApprovalPolicyRequest{
+		MediaAccountID: &account, AllowAdmins: &denied,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.AllowAdmins {
+		t.Fatal("allow_admins: false was not stored")
+	}
+
+	policy, err = approvals.UpdatePolicy(owner, policy.ID, &models.ApprovalPolicyRequest{
+		MediaAccountID: &account, ApproverIDs: []string{"admin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.AllowAdmins {
+		t.Fatal("update without allow_admins changed it")
+	}
+}
+
+func TestApprovalPolicyTargetsMustBeInTheWorkspace(t *testing.T) {
+	store := memory.New()
+	approvals := NewApprovalService(store)
+	owner := testActor("owner", "workspace")
+	outsider := testActor("outsider", "other")
+	_, account := articleFixture(t, store, owner)
+
+	_, err := approvals.CreatePolicy(outsider, &models.ApprovalPolicyRequest{MediaAccountID: &account.ID})
+	if !errors.Is(err, apperr.NotFound("media_account")) {
+		t.Fatalf("policy on another workspace's account: got %v, want media_account_not_found", err)
+	}
+	_, err = approvals.CreatePolicy(owner, &models.ApprovalPolicyRequest{})
+	if !errors.Is(err, ErrPolicyTargetRequired) {
+		t.Fatalf("policy without a target: got %v, want %v", err, ErrPolicyTargetRequired)
+	}
+
+	policy, err := approvals.CreatePolicy(owner, &models.ApprovalPolicyRequest{MediaAccountID: &account.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := approvals.GetPolicy(outsider, policy.ID); !errors.Is(err, apperr.NotFound("approval_policy")) {
+		t.Fatalf("GetPolicy of another user: got %v, want approval_policy_not_found", err)
+	}
+	if err := approvals.DeletePolicy(outsider, policy.ID); !errors.Is(err, apperr.NotFound("approval_policy")) {
+		t.Fatalf("DeletePolicy of another user: got %v, want approval_policy_not_found", err)
+	}
+	admin := testActor("admin", "admin-workspace")
+	admin.IsAdmin = true
+	if _, err := approvals.GetPolicy(admin, policy.ID); err != nil {
+		t.Fatalf("GetPolicy as admin: %v", err)
+	}
+}
+
+func TestApprovalServiceReviewsReposts(t *testing.T) {
+	store := memory.New()
+	approvals := NewApprovalService(store)
+	owner := testActor("owner", "workspace")
+	approver := testActor("approver", "approver-workspace")
+	outsider := testActor("outsider", "other")
+	article, account := articleFixture(t, store, owner)
+
+	_, err := approvals.CreatePolicy(owner, &models.ApprovalPolicyRequest{
+		MediaAccountID: &account.ID, ApproverIDs: []string{approver.ID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repost, err := NewArticleService(store, approvals).RepostArticle(
+		context.Background(), owner, article.ID, &models.RepostRequest{MediaAccountID: account.ID},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repost.ApprovalStatus != models.ApprovalPending {
+		t.Fatalf("repost covered by a policy: got approval status %q", repost.ApprovalStatus)
+	}
+
+	pending, err := approvals.GetPendingApprovals(approver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != repost.ID {
+		t.Fatalf("pending approvals: got %+v", pending)
+	}
+
+	ctx := context.Background()
+	if _, err := approvals.ApproveRepost(ctx, repost.ID, outsider, nil); !errors.Is(err, apperr.NotFound("repost")) {
+		t.Fatalf("approval by an outsider: got %v, want repost_not_found", err)
+	}
+	if _, err := approvals.ApproveRepost(ctx, repost.ID, owner, nil); !errors.Is(err, ErrNotApprover) {
+		t.Fatalf("approval by the owner: got %v, want %v", err, ErrNotApprover)
+	}
+	if _, err := approvals.GetRepostApprovals(outsider, repost.ID); !errors.Is(err, apperr.NotFound("repost")) {
+		t.Fatalf("history for an outsider: got %v, want repost_not_found", err)
+	}
+
+	comment := "Ship it"
+	approved, err := approvals.ApproveRepost(ctx, repost.ID, approver, &comment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.ApprovalStatus != models.ApprovalApproved || approved.Status != "pending" ||
+		approved.ApprovedBy == nil || *approved.ApprovedBy != approver.ID {
+		t.Fatalf("approved repost: got %+v", approved)
+	}
+	if _, err := approvals.RejectRepost(ctx, repost.ID, approver, nil); !errors.Is(err, ErrRepostNotPending) {
+		t.Fatalf("second review: got %v, want %v", err, ErrRepostNotPending)
+	}
+
+	for _, actor := range []*models.User{owner, approver} {
+		history, err := approvals.GetRepostApprovals(actor, repost.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 1 || history[0].Decision != models.ApprovalApproved || history[0].ReviewerID != approver.ID ||
+			history[0].Comment == nil || *history[0].Comment != comment {
+			t.Fatalf("history for %s: got %+v", actor.ID, history)
+		}
+	}
+}
@@ -1,211 +1,168 @@
 package services
 
 import (
-	"database/sql"
+	"context"
 	"time"
 
 	"github.com/google/uuid"
+	"smg/pkg/logging"
 	"smg/pkg/models"
+	"smg/pkg/repository"
 )
 
+// ApprovalChecker decides whether a repost has to be approved before it is
+// published.
+type ApprovalChecker interface {
+	// RequiresApproval reports whether a repost of the article to the media
+	// account is covered by an approval policy.
+	RequiresApproval(articleID, mediaAccountID string) (bool, error)
+}
+
 type ArticleService struct {
-	db *sql.DB
+	store     repository.Store
+	approvals ApprovalChecker
 }
 
-func NewArticleService(db *sql.DB) *ArticleService {
-	return &ArticleService{db: db}
+func NewArticleService(store repository.Store, approvals ApprovalChecker) *ArticleService {
+	return &ArticleService{store: store, approvals: approvals}
 }
 
-func (s *ArticleService) GetArticles(userID string, page, pageSize int) (*models.PaginatedResponse, error) {
-	offset := (page - 1) * pageSize
-	
-	var total int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM articles WHERE user_id = $1", userID).Scan(&total)
-	if err != nil {
-		return nil, err
-	}
-	
-	rows, err := s.db.Query(`
-		SELECT id, title, content, original_url, platform, author_name, author_id, 
-			   published_at, topic_id, user_id, created_at, updated_at
-		FROM articles 
-		WHERE user_id = $1
-		ORDER BY published_at DESC
-		LIMIT $2 OFFSET $3
-	`, userID, pageSize, offset)
-	
+// GetArticles lists the articles of the actor's workspace, newest first. An
+// empty state returns articles in every state.
+func (s *ArticleService) GetArticles(actor *models.User, state string, page, pageSize int) (*models.PaginatedResponse, error) {
+	articles, total, err := s.store.Articles().List(actor.WorkspaceID,
+		repository.ArticleFilter{State: state}, pageOf(page, pageSize))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	
-	var articles []models.Article
-	for rows.Next() {
-		var article models.Article
-		err := rows.Scan(
-			&article.ID, &article.Title, &article.Content, &article.OriginalURL,
-			&article.Platform, &article.AuthorName, &article.AuthorID,
-			&article.PublishedAt, &article.TopicID, &article.UserID,
-			&article.CreatedAt, &article.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		articles = append(articles, article)
-	}
-	
-	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
-	
-	return &models.PaginatedResponse{
-		Data:       articles,
-		Page:       page,
-		PageSize:   pageSize,
-		Total:      total,
-		TotalPages: totalPages,
-	}, nil
+
+	return paginated(articles, total, page, pageSize), nil
 }
 
-func (s *ArticleService) CreateArticle(userID string, article *models.Article) (*models.Article, error) {
-	articleID := uuid.New().String()
+func (s *ArticleService) CreateArticle(actor *models.User, article *models.Article) (*models.Article, error) {
+	if _, err := s.store.Topics().Get(actor.WorkspaceID, article.TopicID); err != nil {
+		return nil, notFound("topic", err)
+	}
+
 	now := time.Now()
-	
-	_, err := s.db.Exec(`
-		INSERT INTO articles (id, title, content, original_url, platform, author_name, author_id, 
-							published_at, topic_id, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	`, articleID, article.Title, article.Content, article.OriginalURL, article.Platform,
-		article.AuthorName, article.AuthorID, article.PublishedAt, article.TopicID, 
-		userID, now, now)
-	
-	if err != nil {
+	row := &models.Article{
+		ID:          uuid.New().String(),
+		Title:       article.Title,
+		Content:     article.Content,
+		OriginalURL: article.OriginalURL,
+		Platform:    article.Platform,
+		AuthorName:  article.AuthorName,
+		AuthorID:    article.AuthorID,
+		PublishedAt: article.PublishedAt,
+		TopicID:     article.TopicID,
+		WorkspaceID: actor.WorkspaceID,
+		UserID:      actor.ID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.store.Articles().Create(row); err != nil {
 		return nil, err
 	}
-	
-	return s.GetArticle(articleID)
+
+	return s.GetArticle(actor, row.ID)
 }
 
-func (s *ArticleService) GetArticle(articleID string) (*models.Article, error) {
-	var article models.Article
-	err := s.db.QueryRow(`
-		SELECT id, title, content, original_url, platform, author_name, author_id, 
-			   published_at, topic_id, user_id, created_at, updated_at
-		FROM articles WHERE id = $1
-	`, articleID).Scan(
-		&article.ID, &article.Title, &article.Content, &article.OriginalURL,
-		&article.Platform, &article.AuthorName, &article.AuthorID,
-		&article.PublishedAt, &article.TopicID, &article.UserID,
-		&article.CreatedAt, &article.UpdatedAt,
-	)
-	
+func (s *ArticleService) GetArticle(actor *models.User, articleID string) (*models.Article, error) {
+	article, err := s.store.Articles().Get(actor.WorkspaceID, articleID)
 	if err != nil {
-		return nil, err
+		return nil, notFound("article", err)
 	}
-	
-	return &article, nil
+
+	return article, nil
 }
 
-func (s *ArticleService) UpdateArticle(articleID string, article *models.Article) (*models.Article, error) {
-	now := time.Now()
-	
-	_, err := s.db.Exec(`
-		UPDATE articles 
-		SET title = $2, content = $3, updated_at = $4
-		WHERE id = $1
-	`, articleID, article.Title, article.Content, now)
-	
+func (s *ArticleService) UpdateArticle(actor *models.User, articleID string, article *models.Article) (*models.Article, error) {
+	err := s.store.Articles().Update(&models.Article{
+		ID:          articleID,
+		Title:       article.Title,
+		Content:     article.Content,
+		WorkspaceID: actor.WorkspaceID,
+		UpdatedAt:   time.Now(),
+	})
 	if err != nil {
-		return nil, err
+		return nil, notFound("article", err)
 	}
-	
-	return s.GetArticle(articleID)
+
+	return s.GetArticle(actor, articleID)
 }
 
-func (s *ArticleService) DeleteArticle(articleID string) error {
-	_, err := s.db.Exec("DELETE FROM articles WHERE id = $1", articleID)
-	return err
+func (s *ArticleService) DeleteArticle(actor *models.User, articleID string) error {
+	return notFound("article", s.store.Articles().Delete(actor.WorkspaceID, articleID))
 }
 
-func (s *ArticleService) RepostArticle(articleID, userID string, req *models.RepostRequest) (*models.Repost, error) {
-	repostID := uuid.New().String()
-	now := time.Now()
-	
-	_, err := s.db.Exec(`
-		INSERT INTO reposts (id, article_id, media_account_id, custom_caption, status, 
-						   scheduled_at, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`, repostID, articleID, req.MediaAccountID, req.CustomCaption, "pending", 
-		req.ScheduledAt, userID, now, now)
-	
-	if err != nil {
+// BulkUpdateState moves articles of the actor's workspace into the given
+// state and returns how many rows changed. IDs from other workspaces are
+// ignored.
+func (s *ArticleService) BulkUpdateState(actor *models.User, articleIDs []string, state string) (int64, error) {
+	return s.store.Articles().SetState(actor.WorkspaceID, articleIDs, state, time.Now())
+}
+
+// RepostArticle queues a repost of the article to a media account. Both must
+// belong to the actor's workspace. The request ID and trace context in ctx
+// are stored with the repost, so the scheduler's publish attempt can be
+// traced back to the API call that queued it.
+func (s *ArticleService) RepostArticle(ctx context.Context, actor *models.User, articleID string, req *models.RepostRequest) (*models.Repost, error) {
+	if _, err := s.GetArticle(actor, articleID); err != nil {
 		return nil, err
 	}
-	
-	return s.GetRepost(repostID)
-}
+	if _, err := s.store.MediaAccounts().Get(actor.WorkspaceID, req.MediaAccountID); err != nil {
+		return nil, notFound("media_account", err)
+	}
 
-func (s *ArticleService) GetRepost(repostID string) (*models.Repost, error) {
-	var repost models.Repost
-	err := s.db.QueryRow(`
-		SELECT id, article_id, media_account_id, custom_caption, ai_caption, status, 
-			   scheduled_at, posted_at, external_id, user_id, created_at, updated_at
-		FROM reposts WHERE id = $1
-	`, repostID).Scan(
-		&repost.ID, &repost.ArticleID, &repost.MediaAccountID, &repost.CustomCaption,
-		&repost.AICaption, &repost.Status, &repost.ScheduledAt, &repost.PostedAt,
-		&repost.ExternalID, &repost.UserID, &repost.CreatedAt, &repost.UpdatedAt,
-	)
-	
+	needsApproval, err := s.approvals.RequiresApproval(articleID, req.MediaAccountID)
 	if err != nil {
 		return nil, err
 	}
-	
-	return &repost, nil
+
+	approvalStatus := models.ApprovalNotRequired
+	if needsApproval {
+		approvalStatus = models.ApprovalPending
+	}
+
+	now := time.Now()
+	repost := &models.Repost{
+		ID:             uuid.New().String(),
+		ArticleID:      articleID,
+		MediaAccountID: req.MediaAccountID,
+		CustomCaption:  req.CustomCaption,
+		Status:         "pending",
+		ScheduledAt:    req.ScheduledAt,
+		ApprovalStatus: approvalStatus,
+		WorkspaceID:    actor.WorkspaceID,
+		UserID:         actor.ID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.store.Reposts().Create(ctx, repost); err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("repost queued",
+		"repost_id", repost.ID, "article_id", articleID, "media_account_id", req.MediaAccountID,
+		"approval_status", approvalStatus, "scheduled_at", req.ScheduledAt)
+
+	return s.GetRepost(repost.ID)
 }
 
-func (s *ArticleService) GetReposts(userID string, page, pageSize int) (*models.PaginatedResponse, error) {
-	offset := (page - 1) * pageSize
-	
-	var total int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM reposts WHERE user_id = $1", userID).Scan(&total)
+func (s *ArticleService) GetRepost(repostID string) (*models.Repost, error) {
+	repost, err := s.store.Reposts().Get(repostID)
 	if err != nil {
-		return nil, err
+		return nil, notFound("repost", err)
 	}
-	
-	rows, err := s.db.Query(`
-		SELECT id, article_id, media_account_id, custom_caption, ai_caption, status, 
-			   scheduled_at, posted_at, external_id, user_id, created_at, updated_at
-		FROM reposts 
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`, userID, pageSize, offset)
-	
+
+	return repost, nil
+}
+
+func (s *ArticleService) GetReposts(actor *models.User, page, pageSize int) (*models.PaginatedResponse, error) {
+	reposts, total, err := s.store.Reposts().List(actor.WorkspaceID, pageOf(page, pageSize))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	
-	var reposts []models.Repost
-	for rows.Next() {
-		var repost models.Repost
-		err := rows.Scan(
-			&repost.ID, &repost.ArticleID, &repost.MediaAccountID, &repost.CustomCaption,
-			&repost.AICaption, &repost.Status, &repost.ScheduledAt, &repost.PostedAt,
-			&repost.ExternalID, &repost.UserID, &repost.CreatedAt, &repost.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		reposts = append(reposts, repost)
-	}
-	
-	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
-	
-	return &models.PaginatedResponse{
-		Data:       reposts,
-		Page:       page,
-		PageSize:   pageSize,
-		Total:      total,
-		TotalPages: totalPages,
-	}, nil
-}
\ No newline at end of file
+
+	return paginated(reposts, total, page, pageSize), nil
+}
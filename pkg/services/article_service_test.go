@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/repository/memory"
+)
+
+// approvalsRequired is an ApprovalChecker with a fixed answer.
+type approvalsRequired bool
+
+func (required approvalsRequired) RequiresApproval(articleID, mediaAccountID string) (bool, error) {
+	return bool(required), nil
+}
+
+// articleFixture creates a topic, an article and a media account in the
+// actor's workspace.
+func articleFixture(t *testing.T, store *memory.Store, actor *models.User) (*models.Article, *models.MediaAccount) {
+	t.Helper()
+
+	topic, err := NewTopicService(store).CreateTopic(actor, &models.CreateTopicRequest{Name: "Go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	article, err := NewArticleService(store, approvalsRequired(false)).CreateArticle(actor, &models.Article{
+		Title: "Post", OriginalURL: "https://example.com/post", PublishedAt: time.Now(), TopicID: topic.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := NewMediaService(store).CreateAccount(actor, &models.ConnectPlatformRequest{Code: "handle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return article, account
+}
+
+func TestArticleServiceRepostApproval(t *testing.T) {
+	for _, test := range []struct {
+		required bool
+		want     string
+	}{
+		{false, models.ApprovalNotRequired},
+		{true, models.ApprovalPending},
+	} {
+		store := memory.New()
+		owner := testActor("owner", "workspace")
+		article, account := articleFixture(t, store, owner)
+
+		repost, err := NewArticleService(store, approvalsRequired(test.required)).RepostArticle(
+			context.Background(), owner, article.ID, &models.RepostRequest{MediaAccountID: account.ID},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if repost.ApprovalStatus != test.want || repost.Status != "pending" || repost.UserID != owner.ID {
+			t.Fatalf("approval required %v: got repost %+v", test.required, repost)
+		}
+	}
+}
+
+func TestArticleServiceRepostChecksTheWorkspace(t *testing.T) {
+	store := memory.New()
+	owner := testActor("owner", "workspace")
+	outsider := testActor("outsider", "other")
+	article, account := articleFixture(t, store, owner)
+	_, foreignAccount := articleFixture(t, store, outsider)
+	articles := NewArticleService(store, approvalsRequired(false))
+
+	_, err := articles.RepostArticle(context.Background(), outsider, article.ID, &models.RepostRequest{MediaAccountID: foreignAccount.ID})
+	if !errors.Is(err, apperr.NotFound("article")) {
+		t.Fatalf("repost of another workspace's article: got %v, want article_not_found", err)
+	}
+	_, err = articles.RepostArticle(context.Background(), owner, article.ID, &models.RepostRequest{MediaAccountID: foreignAccount.ID})
+	if !errors.Is(err, apperr.NotFound("media_account")) {
+		t.Fatalf("repost to another workspace's account: got %v, want media_account_not_found", err)
+	}
+	if _, err := articles.RepostArticle(context.Background(), owner, article.ID, &models.RepostRequest{MediaAccountID: account.ID}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArticleServiceBulkUpdateState(t *testing.T) {
+	store := memory.New()
+	owner := testActor("owner", "workspace")
+	outsider := testActor("outsider", "other")
+	article, _ := articleFixture(t, store, owner)
+	foreign, _ := articleFixture(t, store, outsider)
+	articles := NewArticleService(store, approvalsRequired(false))
+
+	ids := []string{article.ID, foreign.ID}
+	for _, want := range []int64{1, 0} {
+		changed, err := articles.BulkUpdateState(owner, ids, models.ArticleStateArchived)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != want {
+			t.Fatalf("changed %d articles, want %d", changed, want)
+		}
+	}
+
+	archived, err := articles.GetArticles(owner, models.ArticleStateArchived, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived.Total != 1 {
+		t.Fatalf("got %d archived articles, want 1", archived.Total)
+	}
+	untouched, err := articles.GetArticle(outsider, foreign.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if untouched.State != models.ArticleStateNew {
+		t.Fatalf("article of another workspace moved to %q", untouched.State)
+	}
+}
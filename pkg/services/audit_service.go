@@ -0,0 +1,325 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+// Audit actions.
+const (
+	AuditAuthLogin                = "auth.login"
+	AuditAuthLoginFailed          = "auth.login_failed"
+	AuditAuthLockout              = "auth.lockout"
+	AuditAuthRefreshReused        = "auth.refresh_token_reused"
+	AuditAuthPasswordReset        = "auth.password_reset"
+	AuditAuthSessionRevoked       = "auth.session_revoked"
+	AuditAuthMFAEnabled           = "auth.mfa_enabled"
+	AuditAuthMFADisabled          = "auth.mfa_disabled"
+	AuditAuthRecoveryCodesRenewed = "auth.recovery_codes_regenerated"
+	AuditAuthAPIKeyCreated        = "auth.api_key_created"
+	AuditAuthAPIKeyRevoked        = "auth.api_key_revoked"
+	AuditUserDeleted              = "user.deleted"
+	AuditUserRoleChanged          = "user.role_changed"
+	AuditTopicDeleted             = "topic.deleted"
+	AuditMediaAccountDeleted      = "media_account.deleted"
+	AuditSystemSettingsUpdated    = "system.settings_updated"
+)
+
+// auditColumns are the columns of an exported audit event, in order.
+var auditColumns = []string{
+	"id", "created_at", "action", "actor_id", "actor_email", "resource_type", "resource_id",
+	"ip_address", "request_id", "before", "after", "metadata",
+}
+
+// auditWhere filters audit events by the fields of models.AuditFilter,
+// bound to $1 to $6 in field order.
+const auditWhere = `
+	WHERE ($1 = '' OR action = $1)
+	AND ($2 = '' OR actor_id = $2)
+	AND ($3 = '' OR resource_type = $3)
+	AND ($4 = '' OR resource_id = $4)
+	AND ($5::timestamp IS NULL OR created_at >= $5)
+	AND ($6::timestamp IS NULL OR created_at < $6)
+`
+
+// AuditService records security- and content-relevant events in the
+// audit_events table. The table is append-only: the database rejects
+// updates and deletes, so events outlive the users and resources they
+// mention.
+type AuditService struct {
+	db *sql.DB
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx, so events can be
+// recorded in the transaction of the change they describe.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func NewAuditService(db *sql.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// Record stores the event, filling in its ID and time.
+func (s *AuditService) Record(event models.AuditEvent) error {
+	return recordAudit(s.db, event)
+}
+
+// GetEvents lists the events matching filter, newest first.
+func (s *AuditService) GetEvents(filter *models.AuditFilter, page, pageSize int) (*models.PaginatedResponse, error) {
+	offset := (page - 1) * pageSize
+	args := auditFilterArgs(filter)
+
+	var total int64
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM audit_events"+auditWhere, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(auditSelect+auditWhere+`
+		ORDER BY created_at DESC, id
+		LIMIT $7 OFFSET $8
+	`, append(args, pageSize, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+
+	return &models.PaginatedResponse{
+		Data:       events,
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// ExportEvents writes every event matching filter to w as CSV with a
+// header row, newest first. Rows are streamed, so large exports are not
+// held in memory.
+func (s *AuditService) ExportEvents(filter *models.AuditFilter, w io.Writer) error {
+	rows, err := s.db.Query(auditSelect+auditWhere+`
+		ORDER BY created_at DESC, id
+	`, auditFilterArgs(filter)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	out := csv.NewWriter(w)
+	if err := out.Write(auditColumns); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+
+		record := []string{
+			event.ID, event.CreatedAt.UTC().Format(time.RFC3339), event.Action,
+			stringValue(event.ActorID), stringValue(event.ActorEmail),
+			event.ResourceType, event.ResourceID, event.IPAddress, event.RequestID,
+			jsonCell(event.Before), jsonCell(event.After), jsonCell(event.Metadata),
+		}
+		for i := range record {
+			record[i] = csvSafe(record[i])
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+const auditSelect = `
+	SELECT id, action, actor_id, actor_email, resource_type, resource_id,
+		before, after, COALESCE(ip_address, ''), COALESCE(request_id, ''), metadata, created_at
+	FROM audit_events
+`
+
+func scanAuditEvent(rows *sql.Rows) (*models.AuditEvent, error) {
+	var event models.AuditEvent
+	var before, after, metadata []byte
+	err := rows.Scan(
+		&event.ID, &event.Action, &event.ActorID, &event.ActorEmail,
+		&event.ResourceType, &event.ResourceID, &before, &after,
+		&event.IPAddress, &event.RequestID, &metadata, &event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range []struct {
+		raw []byte
+		to  *map[string]interface{}
+	}{{before, &event.Before}, {after, &event.After}, {metadata, &event.Metadata}} {
+		if field.raw == nil {
+			continue
+		}
+		if err := json.Unmarshal(field.raw, field.to); err != nil {
+			return nil, err
+		}
+	}
+
+	return &event, nil
+}
+
+func recordAudit(exec execer, event models.AuditEvent) error {
+	before, err := jsonColumn(event.Before)
+	if err != nil {
+		return err
+	}
+	after, err := jsonColumn(event.After)
+	if err != nil {
+		return err
+	}
+	metadata, err := jsonColumn(event.Metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`
+		INSERT INTO audit_events (id, action, actor_id, actor_email, resource_type, resource_id,
+			before, after, ip_address, request_id, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, uuid.New().String(), event.Action, event.ActorID, event.ActorEmail, event.ResourceType,
+		event.ResourceID, before, after, nullIfEmpty(event.IPAddress), nullIfEmpty(event.RequestID),
+		metadata, time.Now())
+	return err
+}
+
+// deleteAudited runs del and records event in one transaction of store,
+// with before as the deleted resource. It records nothing when del fails.
+func deleteAudited(store repository.Store, event models.AuditEvent, before interface{}, del func(repository.Store) error) error {
+	snapshot, err := auditSnapshot(before)
+	if err != nil {
+		return err
+	}
+	event.Before = snapshot
+
+	return store.InTx(func(tx repository.Store) error {
+		if err := del(tx); err != nil {
+			return err
+		}
+		return tx.Audit().Record(event)
+	})
+}
+
+// actorEvent starts an event for an action the actor took in the current
+// request.
+func actorEvent(actor *models.User, action, resourceType, resourceID string) models.AuditEvent {
+	return models.AuditEvent{
+		Action:       action,
+		ActorID:      &actor.ID,
+		ActorEmail:   &actor.Email,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		IPAddress:    actor.IPAddress,
+		RequestID:    actor.RequestID,
+	}
+}
+
+// clientEvent starts an event for an action taken by the requesting client
+// on behalf of user, before the request is authenticated.
+func clientEvent(user *models.User, action string, client *models.ClientInfo) models.AuditEvent {
+	event := models.AuditEvent{
+		Action:       action,
+		ActorID:      &user.ID,
+		ActorEmail:   &user.Email,
+		ResourceType: "user",
+		ResourceID:   user.ID,
+	}
+	if client != nil {
+		event.IPAddress = client.IPAddress
+		event.RequestID = client.RequestID
+	}
+	return event
+}
+
+// auditSnapshot turns a resource into the field map stored as Before or
+// After. Fields hidden from JSON, such as tokens, are left out.
+func auditSnapshot(resource interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(encoded, &snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func auditFilterArgs(filter *models.AuditFilter) []interface{} {
+	return []interface{}{
+		filter.Action, filter.ActorID, filter.ResourceType, filter.ResourceID, filter.From, filter.To,
+	}
+}
+
+// jsonColumn encodes value for a JSONB column, or NULL when it is nil.
+func jsonColumn(value map[string]interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+func jsonCell(value map[string]interface{}) string {
+	if value == nil {
+		return ""
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// csvSafe keeps spreadsheet applications from evaluating a cell as a
+// formula.
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
@@ -3,7 +3,6 @@ package services
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"time"
 
@@ -11,72 +10,155 @@ import (
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
+	"smg/pkg/apperr"
+	"smg/pkg/jwtkeys"
 	"smg/pkg/models"
+	"smg/pkg/rbac"
+)
+
+const (
+	accessTokenTTL  = time.Hour * 24
+	refreshTokenTTL = time.Hour * 24 * 7
+)
+
+// Token types carried in the typ claim. Access tokens authenticate API
+// requests; refresh tokens can only be exchanged for a new token pair.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrInvalidToken = apperr.Unauthorized("invalid_token", "Invalid token")
+	// ErrTokenRevoked is returned for tokens whose family was revoked by a
+	// logout or by refresh token reuse.
+	ErrTokenRevoked = apperr.Unauthorized("token_revoked", "Token has been revoked")
+	// ErrRefreshTokenReused is returned when a refresh token that was
+	// already rotated is presented again. The whole family is revoked.
+	ErrRefreshTokenReused = apperr.Unauthorized("refresh_token_reused", "Refresh token reuse detected")
+	// ErrInvalidCredentials is returned for a wrong email or password. It
+	// does not say which, so logins cannot be used to probe for accounts.
+	ErrInvalidCredentials = apperr.Unauthorized("invalid_credentials", "Invalid email or password")
+	ErrEmailTaken         = apperr.Conflict("email_taken", "Email is already registered")
 )
 
 type AuthService struct {
 	db          *sql.DB
 	redisClient *redis.Client
-	jwtSecret   string
+	sessions    *SessionService
+	mfa         *MFAService
+	throttle    *LoginThrottleService
+	keys        *jwtkeys.KeySet
 }
 
 type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
 	IsAdmin bool  `json:"is_admin"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+	WorkspaceID string   `json:"workspace_id"`
+	TokenType   string   `json:"typ"`
+	// FamilyID ties together every token issued since a login. Rotating a
+	// refresh token keeps the family; logging out revokes it.
+	FamilyID string `json:"fid"`
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(db *sql.DB, redisClient *redis.Client) *AuthService {
+func NewAuthService(db *sql.DB, redisClient *redis.Client, keys *jwtkeys.KeySet) *AuthService {
 	return &AuthService{
 		db:          db,
 		redisClient: redisClient,
-		jwtSecret:   "your-secret-key", // Should be from config
+		sessions:    NewSessionService(db, redisClient),
+		mfa:         NewMFAService(db, redisClient),
+		throttle:    NewLoginThrottleService(redisClient, NewAuditService(db)),
+		keys:        keys,
 	}
 }
 
-func (s *AuthService) Login(email, password string) (*models.AuthResponse, error) {
+// Login checks the password. Users with two-factor authentication get an
+// MFA challenge instead of tokens, to be completed with VerifyMFA. Throttled
+// attempts fail with ErrTooManyAttempts.
+func (s *AuthService) Login(email, password string, client *models.ClientInfo) (*models.AuthResponse, *models.MFAChallenge, error) {
+	if err := s.throttle.Check(email); err != nil {
+		return nil, nil, err
+	}
+
 	var user models.User
-	var hashedPassword string
+	var hashedPassword sql.NullString
 	
 	err := s.db.QueryRow(`
-		SELECT id, name, email, email_verified, password, image, is_admin, created_at, updated_at
+		SELECT id, name, email, email_verified, password, image, is_admin, role,
+			two_factor_enabled_at IS NOT NULL, created_at, updated_at
 		FROM users WHERE email = $1
 	`, email).Scan(
 		&user.ID, &user.Name, &user.Email, &user.EmailVerified, 
-		&hashedPassword, &user.Image, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt,
+		&hashedPassword, &user.Image, &user.IsAdmin, &user.Role,
+		&user.TwoFactorEnabled, &user.CreatedAt, &user.UpdatedAt,
 	)
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("invalid credentials")
+			return nil, nil, s.loginFailed(email, client)
 		}
-		return nil, err
+		return nil, nil, err
+	}
+
+	// Users created through Google login have no password.
+	if !hashedPassword.Valid {
+		return nil, nil, s.loginFailed(email, client)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword.String), []byte(password)); err != nil {
+		return nil, nil, s.loginFailed(email, client)
+	}
+
+	if err := s.throttle.RecordSuccess(email); err != nil {
+		return nil, nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+	return s.completeLogin(&user, LoginMethodPassword, client)
+}
+
+// loginFailed counts a failed password login and returns the error to
+// report for it.
+func (s *AuthService) loginFailed(email string, client *models.ClientInfo) error {
+	if err := s.throttle.RecordFailure(email, client); err != nil {
+		return err
+	}
+
+	event := models.AuditEvent{
+		Action:       AuditAuthLoginFailed,
+		ResourceType: "email",
+		ResourceID:   normalizeEmail(email),
+	}
+	if client != nil {
+		event.IPAddress = client.IPAddress
+		event.RequestID = client.RequestID
+	}
+	if err := recordAudit(s.db, event); err != nil {
+		return err
 	}
 
-	accessToken, err := s.generateToken(user.ID, user.Email, user.IsAdmin, time.Hour*24)
+	return ErrInvalidCredentials
+}
+
+// VerifyMFA completes a login that was answered with an MFA challenge.
+func (s *AuthService) VerifyMFA(mfaToken, code string) (*models.AuthResponse, error) {
+	pending, err := s.mfa.completeChallenge(mfaToken, code)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := s.generateToken(user.ID, user.Email, user.IsAdmin, time.Hour*24*7)
+	user, err := s.getUser(pending.UserID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.AuthResponse{
-		User:         user,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    86400, // 24 hours
-	}, nil
+	return s.startSession(user, pending.LoginMethod, &pending.Client)
 }
 
-func (s *AuthService) Register(name, email, password string) (*models.AuthResponse, error) {
+func (s *AuthService) Register(name, email, password string, client *models.ClientInfo) (*models.AuthResponse, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
@@ -86,10 +168,13 @@ func (s *AuthService) Register(name, email, password string) (*models.AuthRespon
 	now := time.Now()
 	
 	_, err = s.db.Exec(`
-		INSERT INTO users (id, name, email, password, is_admin, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, userID, name, email, string(hashedPassword), false, now, now)
+		INSERT INTO users (id, name, email, password, is_admin, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, userID, name, email, string(hashedPassword), false, rbac.DefaultRole, now, now)
 	
+	if isUniqueViolation(err) {
+		return nil, ErrEmailTaken
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -99,138 +184,237 @@ func (s *AuthService) Register(name, email, password string) (*models.AuthRespon
 		Name:      &name,
 		Email:     email,
 		IsAdmin:   false,
+		Role:      rbac.DefaultRole,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 
-	accessToken, err := s.generateToken(user.ID, user.Email, user.IsAdmin, time.Hour*24)
-	if err != nil {
+	if err := NewWorkspaceService(s.db).EnsurePersonalWorkspace(&user); err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := s.generateToken(user.ID, user.Email, user.IsAdmin, time.Hour*24*7)
-	if err != nil {
-		return nil, err
-	}
+	return s.startSession(&user, LoginMethodPassword, client)
+}
 
-	return &models.AuthResponse{
-		User:         user,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    86400,
-	}, nil
+// JWKS returns the public keys tokens can be verified with.
+func (s *AuthService) JWKS() jwtkeys.JWKSet {
+	return s.keys.JWKS()
 }
 
+// ValidateToken authenticates an access token. Refresh tokens and tokens on
+// the revocation list are rejected.
 func (s *AuthService) ValidateToken(tokenString string) (*models.User, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.jwtSecret), nil
-	})
-
+	claims, err := s.parseToken(tokenString, TokenTypeAccess)
 	if err != nil {
 		return nil, err
 	}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+	if err := s.checkRevoked(claims); err != nil {
+		return nil, err
 	}
 
-	var user models.User
-	err = s.db.QueryRow(`
-		SELECT id, name, email, email_verified, image, is_admin, created_at, updated_at
-		FROM users WHERE id = $1
-	`, claims.UserID).Scan(
-		&user.ID, &user.Name, &user.Email, &user.EmailVerified, 
-		&user.Image, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt,
-	)
-	
+	user, err := s.getUser(claims.UserID)
 	if err != nil {
 		return nil, err
 	}
+	user.WorkspaceID = claims.WorkspaceID
+	user.SessionID = claims.FamilyID
 
-	return &user, nil
+	// Last-seen tracking is best effort and must not fail the request.
+	s.sessions.TouchSession(claims.FamilyID)
+
+	return user, nil
 }
 
-func (s *AuthService) GenerateQRCode(userID string) (*models.QRCodeResponse, error) {
-	token := uuid.New().String()
-	expires := time.Now().Add(time.Minute * 5).Unix()
-	
-	qrData := map[string]interface{}{
-		"token":   token,
-		"user_id": userID,
-		"expires": expires,
-	}
-	
-	qrJSON, err := json.Marshal(qrData)
+// RefreshToken rotates a refresh token: the presented token is consumed and
+// a new token pair in the same family is returned. Presenting a consumed
+// refresh token again revokes the whole family.
+func (s *AuthService) RefreshToken(tokenString string) (*models.AuthResponse, error) {
+	claims, err := s.parseToken(tokenString, TokenTypeRefresh)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := s.checkRevoked(claims); err != nil {
+		return nil, err
+	}
+
 	ctx := context.Background()
-	err = s.redisClient.Set(ctx, fmt.Sprintf("qr:%s", token), userID, time.Minute*5).Err()
+	consumed, err := s.redisClient.Del(ctx, refreshTokenKey(claims.ID)).Result()
 	if err != nil {
 		return nil, err
 	}
+	if consumed == 0 {
+		if err := s.RevokeFamily(claims.FamilyID); err != nil {
+			return nil, err
+		}
+		event := models.AuditEvent{
+			Action:       AuditAuthRefreshReused,
+			ActorID:      &claims.UserID,
+			ActorEmail:   &claims.Email,
+			ResourceType: "user",
+			ResourceID:   claims.UserID,
+		}
+		if err := recordAudit(s.db, event); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
 
-	return &models.QRCodeResponse{
-		Token:   token,
-		QRCode:  string(qrJSON),
-		Expires: expires,
-	}, nil
+	user, err := s.getUser(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessions.ExtendSession(claims.FamilyID); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, claims.FamilyID)
 }
 
-func (s *AuthService) VerifyQRCode(token string) (*models.AuthResponse, error) {
-	ctx := context.Background()
-	userID, err := s.redisClient.Get(ctx, fmt.Sprintf("qr:%s", token)).Result()
+// Logout revokes the family of the given access or refresh token, which
+// invalidates every token issued since the corresponding login.
+func (s *AuthService) Logout(tokenString string) error {
+	claims, err := s.parseToken(tokenString, "")
 	if err != nil {
-		return nil, fmt.Errorf("invalid or expired QR code")
+		return err
 	}
 
+	return s.RevokeFamily(claims.FamilyID)
+}
+
+// RevokeFamily revokes every token in the family and ends its session.
+func (s *AuthService) RevokeFamily(familyID string) error {
+	return s.sessions.RevokeFamily(familyID)
+}
+
+func (s *AuthService) getUser(userID string) (*models.User, error) {
 	var user models.User
-	err = s.db.QueryRow(`
-		SELECT id, name, email, email_verified, image, is_admin, created_at, updated_at
+	err := s.db.QueryRow(`
+		SELECT id, name, email, email_verified, image, is_admin, role,
+			two_factor_enabled_at IS NOT NULL, created_at, updated_at
 		FROM users WHERE id = $1
 	`, userID).Scan(
-		&user.ID, &user.Name, &user.Email, &user.EmailVerified, 
-		&user.Image, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Name, &user.Email, &user.EmailVerified,
+		&user.Image, &user.IsAdmin, &user.Role, &user.TwoFactorEnabled, &user.CreatedAt, &user.UpdatedAt,
 	)
-	
 	if err != nil {
 		return nil, err
 	}
 
-	accessToken, err := s.generateToken(user.ID, user.Email, user.IsAdmin, time.Hour*24)
+	return &user, nil
+}
+
+// completeLogin finishes a first-factor login: users with two-factor
+// authentication are challenged, everyone else gets a session.
+func (s *AuthService) completeLogin(user *models.User, loginMethod string, client *models.ClientInfo) (*models.AuthResponse, *models.MFAChallenge, error) {
+	if user.TwoFactorEnabled {
+		challenge, err := s.mfa.createChallenge(user.ID, loginMethod, client)
+		return nil, challenge, err
+	}
+
+	response, err := s.startSession(user, loginMethod, client)
+	return response, nil, err
+}
+
+// startSession records a new login and issues the first token pair of its
+// family.
+func (s *AuthService) startSession(user *models.User, loginMethod string, client *models.ClientInfo) (*models.AuthResponse, error) {
+	familyID := uuid.New().String()
+	if err := s.sessions.CreateSession(user.ID, familyID, loginMethod, client); err != nil {
+		return nil, err
+	}
+
+	event := clientEvent(user, AuditAuthLogin, client)
+	event.Metadata = map[string]interface{}{"login_method": loginMethod}
+	if err := recordAudit(s.db, event); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, familyID)
+}
+
+// issueTokens mints an access and refresh token pair in the given family and
+// records the refresh token as unused.
+func (s *AuthService) issueTokens(user *models.User, familyID string) (*models.AuthResponse, error) {
+	accessToken, _, err := s.generateToken(user, TokenTypeAccess, familyID, accessTokenTTL)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := s.generateToken(user.ID, user.Email, user.IsAdmin, time.Hour*24*7)
+	refreshToken, refreshID, err := s.generateToken(user, TokenTypeRefresh, familyID, refreshTokenTTL)
 	if err != nil {
 		return nil, err
 	}
 
-	// Delete the QR code token after use
-	s.redisClient.Del(ctx, fmt.Sprintf("qr:%s", token))
+	err = s.redisClient.Set(context.Background(), refreshTokenKey(refreshID), user.ID, refreshTokenTTL).Err()
+	if err != nil {
+		return nil, err
+	}
 
 	return &models.AuthResponse{
-		User:         user,
+		User:         *user,
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		ExpiresIn:    86400,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
 	}, nil
 }
 
-func (s *AuthService) generateToken(userID, email string, isAdmin bool, duration time.Duration) (string, error) {
+// parseToken verifies a token's signature and expiry. An empty tokenType
+// accepts any type.
+func (s *AuthService) parseToken(tokenString, tokenType string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keys.Keyfunc, jwt.WithValidMethods(s.keys.ValidMethods()))
+	if err != nil {
+		return nil, ErrInvalidToken.Wrap(err)
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid || claims.ID == "" || claims.FamilyID == "" {
+		return nil, ErrInvalidToken
+	}
+	if tokenType != "" && claims.TokenType != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func (s *AuthService) checkRevoked(claims *Claims) error {
+	revoked, err := s.sessions.IsRevoked(claims.FamilyID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+func (s *AuthService) generateToken(user *models.User, tokenType, familyID string, duration time.Duration) (string, string, error) {
+	tokenID := uuid.New().String()
 	claims := &Claims{
-		UserID:  userID,
-		Email:   email,
-		IsAdmin: isAdmin,
+		UserID:      user.ID,
+		Email:       user.Email,
+		IsAdmin:     user.IsAdmin,
+		Role:        user.Role,
+		Permissions: rbac.Permissions(user.Role),
+		WorkspaceID: PersonalWorkspaceID(user.ID),
+		TokenType:   tokenType,
+		FamilyID:    familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   user.ID,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
-}
\ No newline at end of file
+	signed, err := s.keys.Sign(claims)
+	return signed, tokenID, err
+}
+
+func refreshTokenKey(tokenID string) string {
+	return fmt.Sprintf("auth:refresh:%s", tokenID)
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"smg/pkg/dbtest"
+	"smg/pkg/jwtkeys"
+	"smg/pkg/models"
+	"smg/pkg/redistest"
+)
+
+// authFixture returns an auth service on the seeded test database and the
+// Redis client it keeps refresh tokens and revoked families in.
+func authFixture(t *testing.T) (*AuthService, *redis.Client, *sql.DB) {
+	t.Helper()
+	db := dbtest.DB(t)
+	dbtest.Seed(t, db)
+
+	keys, err := jwtkeys.Load(jwtkeys.Config{Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redisClient := redistest.New(t)
+	return NewAuthService(db, redisClient, keys), redisClient, db
+}
+
+// signIn starts a session for the user and returns its first token pair.
+func signIn(t *testing.T, auth *AuthService, userID string) *models.AuthResponse {
+	t.Helper()
+	user, err := auth.getUser(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := auth.startSession(user, LoginMethodPassword, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+func tokenClaims(t *testing.T, auth *AuthService, token string) *Claims {
+	t.Helper()
+	claims, err := auth.parseToken(token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	auth, redisClient, _ := authFixture(t)
+	first := signIn(t, auth, "user_editor")
+
+	second, err := auth.RefreshToken(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("rotation returned the same refresh token")
+	}
+
+	firstClaims, secondClaims := tokenClaims(t, auth, first.RefreshToken), tokenClaims(t, auth, second.RefreshToken)
+	if secondClaims.FamilyID != firstClaims.FamilyID {
+		t.Fatalf("rotated token family: got %s, want %s", secondClaims.FamilyID, firstClaims.FamilyID)
+	}
+	ctx := context.Background()
+	if n := redisClient.Exists(ctx, refreshTokenKey(firstClaims.ID)).Val(); n != 0 {
+		t.Fatal("rotated refresh token is still recorded as unused")
+	}
+	if n := redisClient.Exists(ctx, refreshTokenKey(secondClaims.ID)).Val(); n != 1 {
+		t.Fatal("new refresh token is not recorded")
+	}
+
+	// The new pair works: its access token authenticates and its refresh
+	// token rotates again.
+	if _, err := auth.ValidateToken(second.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.RefreshToken(second.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	// An access token is not a refresh token.
+	if _, err := auth.RefreshToken(second.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("refresh with an access token: got %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	auth, _, db := authFixture(t)
+	first := signIn(t, auth, "user_editor")
+	other := signIn(t, auth, "user_editor")
+
+	second, err := auth.RefreshToken(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := auth.RefreshToken(first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replayed refresh token: got %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	// Every token of the family is revoked, including the unused one that
+	// replaced the replayed token.
+	if _, err := auth.RefreshToken(second.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("refresh after reuse: got %v, want %v", err, ErrTokenRevoked)
+	}
+	for name, token := range map[string]string{"first": first.AccessToken, "second": second.AccessToken} {
+		if _, err := auth.ValidateToken(token); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("%s access token after reuse: got %v, want %v", name, err, ErrTokenRevoked)
+		}
+	}
+	family := tokenClaims(t, auth, first.RefreshToken).FamilyID
+	if n := countRows(t, db, "SELECT count(*) FROM sessions WHERE session_token = $1", family); n != 0 {
+		t.Fatalf("session of the revoked family: got %d rows, want 0", n)
+	}
+
+	if n := countRows(t, db,
+		"SELECT count(*) FROM audit_events WHERE action = $1 AND actor_id = $2 AND resource_type = 'user' AND resource_id = $2",
+		AuditAuthRefreshReused, "user_editor",
+	); n != 1 {
+		t.Fatalf("%s audit events: got %d, want 1", AuditAuthRefreshReused, n)
+	}
+
+	// Other sessions of the user are not affected.
+	if _, err := auth.ValidateToken(other.AccessToken); err != nil {
+		t.Fatalf("other session: %v", err)
+	}
+	if _, err := auth.RefreshToken(other.RefreshToken); err != nil {
+		t.Fatalf("other session: %v", err)
+	}
+}
+
+func TestValidateTokenRejectsRevokedFamily(t *testing.T) {
+	auth, _, _ := authFixture(t)
+	tokens := signIn(t, auth, "user_editor")
+
+	user, err := auth.ValidateToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	family := tokenClaims(t, auth, tokens.AccessToken).FamilyID
+	if user.ID != "user_editor" || user.SessionID != family {
+		t.Fatalf("validated user: got %s in session %s", user.ID, user.SessionID)
+	}
+	if _, err := auth.ValidateToken(tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("refresh token as access token: got %v, want %v", err, ErrInvalidToken)
+	}
+
+	if err := auth.Logout(tokens.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.ValidateToken(tokens.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("access token of a revoked family: got %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := auth.RefreshToken(tokens.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("refresh token of a revoked family: got %v, want %v", err, ErrTokenRevoked)
+	}
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"smg/pkg/apperr"
+)
+
+// uniqueViolation is the PostgreSQL error code for a duplicate key.
+const uniqueViolation = "23505"
+
+// notFound turns sql.ErrNoRows into a not found error for resource, such
+// as "topic", and returns any other error unchanged. sql.ErrNoRows stays
+// the cause, so callers inside the package may keep testing for it.
+func notFound(resource string, err error) error {
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) || !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return apperr.NotFound(resource).Wrap(err)
+}
+
+// isUniqueViolation reports whether err is a duplicate key error.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/ratelimit"
+)
+
+const (
+	// loginFailureWindow is how long failed logins are remembered.
+	loginFailureWindow = time.Minute * 15
+	// After loginDelayAfter consecutive failures each further attempt has to
+	// wait, starting at one second and doubling up to loginMaxDelay.
+	loginDelayAfter = 3
+	loginMaxDelay   = time.Minute
+	// loginLockoutAfter consecutive failures lock the account for
+	// loginLockoutDuration.
+	loginLockoutAfter    = 10
+	loginLockoutDuration = time.Minute * 15
+)
+
+// loginEmailLimit caps login attempts per email address, successful or not,
+// independent of where they come from.
+var loginEmailLimit = ratelimit.Limit{Name: "login-email", Requests: 20, Window: time.Minute * 15}
+
+// ErrTooManyAttempts is returned when a login is throttled. The returned
+// copies carry the time until the next attempt in RetryAfter.
+var ErrTooManyAttempts = apperr.New(apperr.KindRateLimited, "too_many_attempts", "Too many attempts, try again later")
+
+// LoginThrottleService protects password logins against guessing: it
+// limits attempts per email, slows down repeated failures and temporarily
+// locks the account after too many. Counters are kept per email whether or
+// not an account exists, so throttling does not reveal which emails are
+// registered.
+type LoginThrottleService struct {
+	redisClient *redis.Client
+	limiter     *ratelimit.Limiter
+	audit       *AuditService
+}
+
+func NewLoginThrottleService(redisClient *redis.Client, audit *AuditService) *LoginThrottleService {
+	return &LoginThrottleService{
+		redisClient: redisClient,
+		limiter:     ratelimit.New(redisClient),
+		audit:       audit,
+	}
+}
+
+// Check returns ErrTooManyAttempts if a login for email may not be attempted
+// now.
+func (s *LoginThrottleService) Check(email string) error {
+	ctx := context.Background()
+	email = normalizeEmail(email)
+
+	for _, key := range []string{loginLockKey(email), loginDelayKey(email)} {
+		wait, err := s.redisClient.PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if wait > 0 {
+			return tooManyAttempts(wait)
+		}
+	}
+
+	result, err := s.limiter.Allow(ctx, loginEmailLimit, email)
+	if err != nil {
+		return err
+	}
+	if !result.Allowed {
+		return tooManyAttempts(result.RetryAfter)
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed login and applies the delay or lockout it
+// earns.
+func (s *LoginThrottleService) RecordFailure(email string, client *models.ClientInfo) error {
+	ctx := context.Background()
+	email = normalizeEmail(email)
+
+	failures, err := s.redisClient.Incr(ctx, loginFailuresKey(email)).Result()
+	if err != nil {
+		return err
+	}
+	if err := s.redisClient.Expire(ctx, loginFailuresKey(email), loginFailureWindow).Err(); err != nil {
+		return err
+	}
+
+	if failures >= loginLockoutAfter {
+		return s.lock(email, failures, client)
+	}
+
+	if failures >= loginDelayAfter {
+		delay := time.Second << uint(failures-loginDelayAfter)
+		if delay > loginMaxDelay {
+			delay = loginMaxDelay
+		}
+		return s.redisClient.Set(ctx, loginDelayKey(email), failures, delay).Err()
+	}
+
+	return nil
+}
+
+// RecordSuccess clears the failures counted against email.
+func (s *LoginThrottleService) RecordSuccess(email string) error {
+	email = normalizeEmail(email)
+	return s.redisClient.Del(context.Background(), loginFailuresKey(email), loginDelayKey(email)).Err()
+}
+
+func (s *LoginThrottleService) lock(email string, failures int64, client *models.ClientInfo) error {
+	ctx := context.Background()
+	if err := s.redisClient.Set(ctx, loginLockKey(email), failures, loginLockoutDuration).Err(); err != nil {
+		return err
+	}
+	// The lockout starts a fresh count once it ends.
+	if err := s.redisClient.Del(ctx, loginFailuresKey(email), loginDelayKey(email)).Err(); err != nil {
+		return err
+	}
+
+	event := models.AuditEvent{
+		Action:       AuditAuthLockout,
+		ResourceType: "email",
+		ResourceID:   email,
+		Metadata: map[string]interface{}{
+			"failures":        failures,
+			"lockout_seconds": int64(loginLockoutDuration.Seconds()),
+		},
+	}
+	if client != nil {
+		event.IPAddress = client.IPAddress
+		event.RequestID = client.RequestID
+	}
+	return s.audit.Record(event)
+}
+
+func tooManyAttempts(wait time.Duration) error {
+	err := ErrTooManyAttempts.Wrap(nil)
+	err.RetryAfter = wait
+	return err
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginFailuresKey(email string) string {
+	return fmt.Sprintf("auth:login:failures:%s", email)
+}
+
+func loginDelayKey(email string) string {
+	return fmt.Sprintf("auth:login:delay:%s", email)
+}
+
+func loginLockKey(email string) string {
+	return fmt.Sprintf("auth:login:lock:%s", email)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"smg/pkg/apperr"
+	"smg/pkg/dbtest"
+	"smg/pkg/models"
+	"smg/pkg/redistest"
+)
+
+// retryAfter returns the wait carried by a throttled login's error.
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var throttled *apperr.Error
+	if !errors.Is(err, ErrTooManyAttempts) || !errors.As(err, &throttled) {
+		t.Fatalf("got %v, want %v", err, ErrTooManyAttempts)
+	}
+	return throttled.RetryAfter
+}
+
+func TestLoginThrottleDelaysFailures(t *testing.T) {
+	throttle := NewLoginThrottleService(redistest.NewWithScripts(t, allowRequests), NewAuditService(nil))
+
+	// The delay starts at the third failure, at one second, and doubles up
+	// to a minute.
+	delays := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+		16 * time.Second, 32 * time.Second, time.Minute}
+	for i, want := range delays {
+		if err := throttle.RecordFailure("editor@example.com", nil); err != nil {
+			t.Fatal(err)
+		}
+		// Addresses are counted however they are typed.
+		err := throttle.Check(" Editor@Example.com")
+		if want == 0 {
+			if err != nil {
+				t.Fatalf("after %d failures: got %v, want no delay", i+1, err)
+			}
+			continue
+		}
+		if got := retryAfter(t, err); got > want || got <= want-time.Second {
+			t.Fatalf("after %d failures: got RetryAfter %v, want %v", i+1, got, want)
+		}
+	}
+
+	if err := throttle.Check("someone@example.com"); err != nil {
+		t.Fatalf("another address: %v", err)
+	}
+
+	if err := throttle.RecordSuccess("editor@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Check("editor@example.com"); err != nil {
+		t.Fatalf("after a successful login: %v", err)
+	}
+	if err := throttle.RecordFailure("editor@example.com", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Check("editor@example.com"); err != nil {
+		t.Fatalf("first failure after a successful login: %v", err)
+	}
+}
+
+func TestLoginThrottleLimitsAttempts(t *testing.T) {
+	var limited []string
+	denyRequests := func(_ string, keys, _ []string) (interface{}, error) {
+		limited = append(limited, keys...)
+		return int64(90000), nil
+	}
+	throttle := NewLoginThrottleService(redistest.NewWithScripts(t, denyRequests), NewAuditService(nil))
+
+	if got := retryAfter(t, throttle.Check("Editor@Example.com")); got != 90*time.Second {
+		t.Fatalf("RetryAfter: got %v, want 1m30s", got)
+	}
+	if len(limited) != 1 || limited[0] != "ratelimit:login-email:editor@example.com" {
+		t.Fatalf("limited keys: got %v", limited)
+	}
+}
+
+func TestLoginThrottleLocksOut(t *testing.T) {
+	db := dbtest.DB(t)
+	throttle := NewLoginThrottleService(redistest.NewWithScripts(t, allowRequests), NewAuditService(db))
+	client := &models.ClientInfo{IPAddress: "203.0.113.7", RequestID: "req-lockout"}
+	lockouts := func() int {
+		return countRows(t, db, "SELECT count(*) FROM audit_events WHERE action = $1 AND resource_type = 'email' AND resource_id = $2",
+			AuditAuthLockout, "editor@example.com")
+	}
+
+	for i := 1; i < loginLockoutAfter; i++ {
+		if err := throttle.RecordFailure("Editor@Example.com", client); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := lockouts(); n != 0 {
+		t.Fatalf("after %d failures: got %d lockout events", loginLockoutAfter-1, n)
+	}
+	if got := retryAfter(t, throttle.Check("editor@example.com")); got > loginMaxDelay {
+		t.Fatalf("before the lockout: got RetryAfter %v, want at most %v", got, loginMaxDelay)
+	}
+
+	if err := throttle.RecordFailure("Editor@Example.com", client); err != nil {
+		t.Fatal(err)
+	}
+	if got := retryAfter(t, throttle.Check("editor@example.com")); got > loginLockoutDuration || got <= loginLockoutDuration-time.Second {
+		t.Fatalf("locked out: got RetryAfter %v, want %v", got, loginLockoutDuration)
+	}
+	// A successful login elsewhere does not lift the lock.
+	if err := throttle.RecordSuccess("editor@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got := retryAfter(t, throttle.Check("editor@example.com")); got <= loginMaxDelay {
+		t.Fatalf("locked out after RecordSuccess: got RetryAfter %v", got)
+	}
+
+	if n := lockouts(); n != 1 {
+		t.Fatalf("lockout events: got %d, want 1", n)
+	}
+	var ip, requestID, failures, seconds string
+	err := db.QueryRow(`
+		SELECT ip_address, request_id, metadata->>'failures', metadata->>'lockout_seconds'
+		FROM audit_events WHERE action = $1
+	`, AuditAuthLockout).Scan(&ip, &requestID, &failures, &seconds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != client.IPAddress || requestID != client.RequestID || failures != "10" || seconds != "900" {
+		t.Fatalf("lockout event: got ip %s, request %s, failures %s, lockout %ss", ip, requestID, failures, seconds)
+	}
+}
@@ -1,149 +1,91 @@
 package services
 
 import (
-	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 	"smg/pkg/models"
+	"smg/pkg/repository"
 )
 
 type MediaService struct {
-	db *sql.DB
+	store repository.Store
 }
 
-func NewMediaService(db *sql.DB) *MediaService {
-	return &MediaService{db: db}
+func NewMediaService(store repository.Store) *MediaService {
+	return &MediaService{store: store}
 }
 
-func (s *MediaService) GetAccounts(userID string) ([]models.MediaAccount, error) {
-	rows, err := s.db.Query(`
-		SELECT id, platform, account_id, account_name, expires_at, user_id, created_at, updated_at
-		FROM media_accounts 
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-	`, userID)
-	
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	
-	var accounts []models.MediaAccount
-	for rows.Next() {
-		var account models.MediaAccount
-		err := rows.Scan(
-			&account.ID, &account.Platform, &account.AccountID, &account.AccountName,
-			&account.ExpiresAt, &account.UserID, &account.CreatedAt, &account.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		accounts = append(accounts, account)
-	}
-	
-	return accounts, nil
+func (s *MediaService) GetAccounts(actor *models.User) ([]models.MediaAccount, error) {
+	return s.store.MediaAccounts().List(actor.WorkspaceID, "")
 }
 
-func (s *MediaService) CreateAccount(userID string, req *models.ConnectPlatformRequest) (*models.MediaAccount, error) {
-	accountID := uuid.New().String()
-	now := time.Now()
-	
-	_, err := s.db.Exec(`
-		INSERT INTO media_accounts (id, platform, account_id, account_name, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, accountID, "twitter", req.Code, req.AccountName, userID, now, now)
-	
+func (s *MediaService) CreateAccount(actor *models.User, req *models.ConnectPlatformRequest) (*models.MediaAccount, error) {
+	return s.createAccount(actor, "twitter", req, nil)
+}
+
+func (s *MediaService) GetAccount(actor *models.User, accountID string) (*models.MediaAccount, error) {
+	account, err := s.store.MediaAccounts().Get(actor.WorkspaceID, accountID)
 	if err != nil {
-		return nil, err
+		return nil, notFound("media_account", err)
 	}
-	
-	return s.GetAccount(accountID)
+
+	return account, nil
 }
 
-func (s *MediaService) GetAccount(accountID string) (*models.MediaAccount, error) {
-	var account models.MediaAccount
-	err := s.db.QueryRow(`
-		SELECT id, platform, account_id, account_name, expires_at, user_id, created_at, updated_at
-		FROM media_accounts WHERE id = $1
-	`, accountID).Scan(
-		&account.ID, &account.Platform, &account.AccountID, &account.AccountName,
-		&account.ExpiresAt, &account.UserID, &account.CreatedAt, &account.UpdatedAt,
-	)
-	
+func (s *MediaService) UpdateAccount(actor *models.User, accountID string, req *models.ConnectPlatformRequest) (*models.MediaAccount, error) {
+	err := s.store.MediaAccounts().Update(&models.MediaAccount{
+		ID:          accountID,
+		AccountName: req.AccountName,
+		WorkspaceID: actor.WorkspaceID,
+		UpdatedAt:   time.Now(),
+	})
 	if err != nil {
-		return nil, err
+		return nil, notFound("media_account", err)
 	}
-	
-	return &account, nil
+
+	return s.GetAccount(actor, accountID)
 }
 
-func (s *MediaService) UpdateAccount(accountID string, req *models.ConnectPlatformRequest) (*models.MediaAccount, error) {
-	now := time.Now()
-	
-	_, err := s.db.Exec(`
-		UPDATE media_accounts 
-		SET account_name = $2, updated_at = $3
-		WHERE id = $1
-	`, accountID, req.AccountName, now)
-	
+func (s *MediaService) DeleteAccount(actor *models.User, accountID string) error {
+	account, err := s.GetAccount(actor, accountID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	
-	return s.GetAccount(accountID)
+
+	return notFound("media_account", deleteAudited(s.store, actorEvent(actor, AuditMediaAccountDeleted, "media_account", accountID), account,
+		func(tx repository.Store) error { return tx.MediaAccounts().Delete(actor.WorkspaceID, accountID) },
+	))
 }
 
-func (s *MediaService) DeleteAccount(accountID string) error {
-	_, err := s.db.Exec("DELETE FROM media_accounts WHERE id = $1", accountID)
-	return err
+func (s *MediaService) ConnectPlatform(actor *models.User, platform string, req *models.ConnectPlatformRequest) (*models.MediaAccount, error) {
+	return s.createAccount(actor, platform, req, &req.Code)
 }
 
-func (s *MediaService) ConnectPlatform(userID, platform string, req *models.ConnectPlatformRequest) (*models.MediaAccount, error) {
-	accountID := uuid.New().String()
-	now := time.Now()
-	
-	_, err := s.db.Exec(`
-		INSERT INTO media_accounts (id, platform, account_id, account_name, access_token, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, accountID, platform, req.Code, req.AccountName, &req.Code, userID, now, now)
-	
-	if err != nil {
-		return nil, err
-	}
-	
-	return s.GetAccount(accountID)
+func (s *MediaService) DisconnectAccount(actor *models.User, accountID string) error {
+	return s.DeleteAccount(actor, accountID)
 }
 
-func (s *MediaService) DisconnectAccount(accountID string) error {
-	return s.DeleteAccount(accountID)
+func (s *MediaService) GetPlatformAccounts(actor *models.User, platform string) ([]models.MediaAccount, error) {
+	return s.store.MediaAccounts().List(actor.WorkspaceID, platform)
 }
 
-func (s *MediaService) GetPlatformAccounts(userID, platform string) ([]models.MediaAccount, error) {
-	rows, err := s.db.Query(`
-		SELECT id, platform, account_id, account_name, expires_at, user_id, created_at, updated_at
-		FROM media_accounts 
-		WHERE user_id = $1 AND platform = $2
-		ORDER BY created_at DESC
-	`, userID, platform)
-	
-	if err != nil {
-		return nil, err
+func (s *MediaService) createAccount(actor *models.User, platform string, req *models.ConnectPlatformRequest, accessToken *string) (*models.MediaAccount, error) {
+	now := time.Now()
+	account := &models.MediaAccount{
+		ID:          uuid.New().String(),
+		Platform:    platform,
+		AccountID:   req.Code,
+		AccountName: req.AccountName,
+		AccessToken: accessToken,
+		WorkspaceID: actor.WorkspaceID,
+		UserID:      actor.ID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
-	defer rows.Close()
-	
-	var accounts []models.MediaAccount
-	for rows.Next() {
-		var account models.MediaAccount
-		err := rows.Scan(
-			&account.ID, &account.Platform, &account.AccountID, &account.AccountName,
-			&account.ExpiresAt, &account.UserID, &account.CreatedAt, &account.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		accounts = append(accounts, account)
+	if err := s.store.MediaAccounts().Create(account); err != nil {
+		return nil, err
 	}
-	
-	return accounts, nil
-}
\ No newline at end of file
+
+	return s.GetAccount(actor, account.ID)
+}
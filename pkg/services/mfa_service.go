@@ -0,0 +1,476 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+	"github.com/redis/go-redis/v9"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+)
+
+const (
+	totpIssuer = "SMG"
+	totpPeriod = 30
+	// totpSkew accepts codes from one period either side of now to allow
+	// for clock drift on the user's device.
+	totpSkew = 1
+
+	recoveryCodeCount = 10
+
+	mfaTokenTTL = time.Minute * 5
+	// mfaMaxAttempts bounds the codes that can be tried against one MFA
+	// token before the login has to start over.
+	mfaMaxAttempts = 5
+)
+
+// SettingRequireAdminMFA is the system setting that, when "true", makes
+// two-factor authentication mandatory for admin users.
+const SettingRequireAdminMFA = "security.require_admin_2fa"
+
+var (
+	ErrMFAAlreadyEnabled = apperr.Conflict("mfa_already_enabled", "Two-factor authentication is already enabled")
+	ErrMFANotEnabled     = apperr.Conflict("mfa_not_enabled", "Two-factor authentication is not enabled")
+	ErrMFANotSetUp       = apperr.Conflict("mfa_not_set_up", "Two-factor authentication has not been set up")
+	ErrInvalidMFACode    = apperr.BadRequest("mfa_code_invalid", "Invalid authentication code")
+	// ErrInvalidMFAToken is returned for unknown, expired or exhausted MFA
+	// tokens.
+	ErrInvalidMFAToken = apperr.Unauthorized("mfa_token_invalid", "Invalid or expired MFA token")
+	// ErrMFARequired is returned when an admin tries to turn off two-factor
+	// authentication while the system requires it.
+	ErrMFARequired = apperr.Forbidden("mfa_required", "Two-factor authentication is required for admin users")
+)
+
+// MFAService manages TOTP enrollment and recovery codes. The TOTP secret is
+// kept on the user; it only takes effect once two_factor_enabled_at is set,
+// which happens after the user proves the authenticator app works.
+type MFAService struct {
+	db          *sql.DB
+	redisClient *redis.Client
+}
+
+// pendingMFA is what an MFA token stands for: a login that passed the
+// password check and still needs a second factor.
+type pendingMFA struct {
+	UserID      string            `json:"user_id"`
+	LoginMethod string            `json:"login_method"`
+	Client      models.ClientInfo `json:"client"`
+}
+
+func NewMFAService(db *sql.DB, redisClient *redis.Client) *MFAService {
+	return &MFAService{
+		db:          db,
+		redisClient: redisClient,
+	}
+}
+
+// GetStatus reports the user's enrollment and whether it is mandatory for
+// them.
+func (s *MFAService) GetStatus(user *models.User) (*models.TwoFactorStatus, error) {
+	status := models.TwoFactorStatus{}
+	err := s.db.QueryRow(`
+		SELECT two_factor_enabled_at IS NOT NULL,
+			(SELECT COUNT(*) FROM two_factor_recovery_codes WHERE user_id = $1 AND used_at IS NULL)
+		FROM users WHERE id = $1
+	`, user.ID).Scan(&status.Enabled, &status.RecoveryCodesRemaining)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Required, err = s.Required(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// Required reports whether the user must have two-factor authentication.
+func (s *MFAService) Required(user *models.User) (bool, error) {
+	if !user.IsAdmin {
+		return false, nil
+	}
+
+	var value string
+	err := s.db.QueryRow("SELECT value FROM system_settings WHERE key = $1", SettingRequireAdminMFA).Scan(&value)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(strings.TrimSpace(value), "true"), nil
+}
+
+// Setup generates a new TOTP secret for the user. It replaces any earlier
+// secret that was never confirmed.
+func (s *MFAService) Setup(user *models.User) (*models.TwoFactorSetupResponse, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = requireRows(s.db.Exec(
+		"UPDATE users SET two_factor_secret = $2, updated_at = $3 WHERE id = $1 AND two_factor_enabled_at IS NULL",
+		user.ID, key.Secret(), time.Now(),
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	qrCode, err := qrCodeDataURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorSetupResponse{
+		Secret:     key.Secret(),
+		OTPAuthURI: key.URL(),
+		QRCode:     qrCode,
+	}, nil
+}
+
+// Enable turns two-factor authentication on once the user has entered a
+// code from the secret created by Setup, and returns a fresh set of
+// recovery codes. They are only ever shown this once.
+func (s *MFAService) Enable(user *models.User, code string) ([]string, error) {
+	var secret sql.NullString
+	var enabledAt *time.Time
+	err := s.db.QueryRow(
+		"SELECT two_factor_secret, two_factor_enabled_at FROM users WHERE id = $1", user.ID,
+	).Scan(&secret, &enabledAt)
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if !secret.Valid {
+		return nil, ErrMFANotSetUp
+	}
+
+	if err := s.validateTOTP(user.ID, secret.String, code); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec("UPDATE users SET two_factor_enabled_at = $2, updated_at = $2 WHERE id = $1", user.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(tx, actorEvent(user, AuditAuthMFAEnabled, "user", user.ID)); err != nil {
+		return nil, err
+	}
+
+	return codes, tx.Commit()
+}
+
+// Disable turns two-factor authentication off after checking a current
+// code or a recovery code.
+func (s *MFAService) Disable(user *models.User, code string) error {
+	required, err := s.Required(user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequired
+	}
+
+	if err := s.VerifyCode(user.ID, code); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE users SET two_factor_secret = NULL, two_factor_enabled_at = NULL, updated_at = $2
+		WHERE id = $1
+	`, user.ID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM two_factor_recovery_codes WHERE user_id = $1", user.ID); err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, actorEvent(user, AuditAuthMFADisabled, "user", user.ID)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not, after
+// checking a current code.
+func (s *MFAService) RegenerateRecoveryCodes(user *models.User, code string) ([]string, error) {
+	if err := s.VerifyCode(user.ID, code); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(tx, actorEvent(user, AuditAuthRecoveryCodesRenewed, "user", user.ID)); err != nil {
+		return nil, err
+	}
+
+	return codes, tx.Commit()
+}
+
+// VerifyCode checks a TOTP code, or failing that a recovery code, for a
+// user with two-factor authentication enabled. A recovery code is used up
+// by a successful check, and a TOTP code cannot be used twice.
+func (s *MFAService) VerifyCode(userID, code string) error {
+	var secret sql.NullString
+	var enabledAt *time.Time
+	err := s.db.QueryRow(
+		"SELECT two_factor_secret, two_factor_enabled_at FROM users WHERE id = $1", userID,
+	).Scan(&secret, &enabledAt)
+	if err != nil {
+		return err
+	}
+	if enabledAt == nil || !secret.Valid {
+		return ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == int(otp.DigitsSix) {
+		return s.validateTOTP(userID, secret.String, code)
+	}
+
+	err = requireRows(s.db.Exec(`
+		UPDATE two_factor_recovery_codes SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hashRecoveryCode(code), time.Now()))
+	if err == sql.ErrNoRows {
+		return ErrInvalidMFACode
+	}
+	return err
+}
+
+// createChallenge stores a login that still needs a second factor and
+// returns the MFA token that completes it.
+func (s *MFAService) createChallenge(userID, loginMethod string, client *models.ClientInfo) (*models.MFAChallenge, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := pendingMFA{UserID: userID, LoginMethod: loginMethod}
+	if client != nil {
+		pending.Client = *client
+	}
+	encoded, err := json.Marshal(pending)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redisClient.Set(context.Background(), mfaTokenKey(token), encoded, mfaTokenTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAChallenge{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(mfaTokenTTL.Seconds()),
+	}, nil
+}
+
+// completeChallenge checks the code for the login behind an MFA token. The
+// token is consumed on success or once too many codes were tried.
+func (s *MFAService) completeChallenge(token, code string) (*pendingMFA, error) {
+	ctx := context.Background()
+	encoded, err := s.redisClient.Get(ctx, mfaTokenKey(token)).Result()
+	if err == redis.Nil {
+		return nil, ErrInvalidMFAToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pending pendingMFA
+	if err := json.Unmarshal([]byte(encoded), &pending); err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	attempts, err := s.redisClient.Incr(ctx, mfaAttemptsKey(token)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if attempts == 1 {
+		s.redisClient.Expire(ctx, mfaAttemptsKey(token), mfaTokenTTL)
+	}
+	if attempts > mfaMaxAttempts {
+		s.redisClient.Del(ctx, mfaTokenKey(token), mfaAttemptsKey(token))
+		return nil, ErrInvalidMFAToken
+	}
+
+	if err := s.VerifyCode(pending.UserID, code); err != nil {
+		return nil, err
+	}
+
+	// Only one request may turn the token into a session.
+	consumed, err := s.redisClient.Del(ctx, mfaTokenKey(token)).Result()
+	if err != nil {
+		return nil, err
+	}
+	s.redisClient.Del(ctx, mfaAttemptsKey(token))
+	if consumed == 0 {
+		return nil, ErrInvalidMFAToken
+	}
+
+	return &pending, nil
+}
+
+// validateTOTP checks code against the secret and marks the matching time
+// step as used, so an observed code cannot be replayed.
+func (s *MFAService) validateTOTP(userID, secret, code string) error {
+	now := time.Now()
+	counter := now.Unix() / totpPeriod
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		ok, err := hotp.ValidateCustom(code, uint64(counter+offset), secret, hotp.ValidateOpts{
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return ErrInvalidMFACode
+		}
+		if !ok {
+			continue
+		}
+
+		fresh, err := s.redisClient.SetNX(
+			context.Background(), totpUsedKey(userID, counter+offset), 1, totpPeriod*(2*totpSkew+1)*time.Second,
+		).Result()
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	return ErrInvalidMFACode
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID string) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM two_factor_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO two_factor_recovery_codes (id, user_id, code_hash, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, uuid.New().String(), userID, hashRecoveryCode(code), now)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// newRecoveryCode returns a code like "3f9a2-c41be".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(b)
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashRecoveryCode normalises a recovery code as typed by the user before
+// hashing it. The codes are random enough that a fast hash is sufficient.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func qrCodeDataURL(key *otp.Key) (string, error) {
+	img, err := key.Image(256, 256)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func mfaTokenKey(token string) string {
+	return fmt.Sprintf("auth:mfa:%s", token)
+}
+
+func mfaAttemptsKey(token string) string {
+	return fmt.Sprintf("auth:mfa:attempts:%s", token)
+}
+
+func totpUsedKey(userID string, counter int64) string {
+	return fmt.Sprintf("auth:totp:used:%s:%d", userID, counter)
+}
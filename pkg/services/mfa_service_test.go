@@ -0,0 +1,218 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+	"smg/pkg/dbtest"
+	"smg/pkg/redistest"
+)
+
+// currentStep returns the TOTP time step, first waiting for the next one if
+// the current step ends too soon for a test to finish inside it.
+func currentStep(t *testing.T) int64 {
+	t.Helper()
+	if remaining := totpPeriod - time.Now().Unix()%totpPeriod; remaining < 3 {
+		time.Sleep(time.Duration(remaining) * time.Second)
+	}
+	return time.Now().Unix() / totpPeriod
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := hotp.GenerateCodeCustom(secret, uint64(step), hotp.ValidateOpts{
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func newTOTPSecret(t *testing.T) string {
+	t.Helper()
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: totpIssuer, AccountName: "user@example.com", Period: totpPeriod})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key.Secret()
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	secret := newTOTPSecret(t)
+
+	tests := []struct {
+		name   string
+		offset int64
+		valid  bool
+	}{
+		{"two steps behind", -2, false},
+		{"one step behind", -1, true},
+		{"current step", 0, true},
+		{"one step ahead", 1, true},
+		{"two steps ahead", 2, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mfa := NewMFAService(nil, redistest.New(t))
+			err := mfa.validateTOTP("user", secret, totpCode(t, secret, currentStep(t)+test.offset))
+			switch {
+			case test.valid && err != nil:
+				t.Fatalf("got %v, want the code accepted", err)
+			case !test.valid && !errors.Is(err, ErrInvalidMFACode):
+				t.Fatalf("got %v, want %v", err, ErrInvalidMFACode)
+			}
+		})
+	}
+
+	mfa := NewMFAService(nil, redistest.New(t))
+	for _, code := range []string{"", "12345", "abcdef"} {
+		if err := mfa.validateTOTP("user", secret, code); !errors.Is(err, ErrInvalidMFACode) {
+			t.Errorf("code %q: got %v, want %v", code, err, ErrInvalidMFACode)
+		}
+	}
+}
+
+func TestValidateTOTPRefusesReplay(t *testing.T) {
+	secret := newTOTPSecret(t)
+	mfa := NewMFAService(nil, redistest.New(t))
+	step := currentStep(t)
+	code := totpCode(t, secret, step)
+
+	if err := mfa.validateTOTP("user", secret, code); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfa.validateTOTP("user", secret, code); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("replayed code: got %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	// Only the used step is spent: the neighbouring steps still work once,
+	// and so does the same code for another user with the same secret.
+	if err := mfa.validateTOTP("user", secret, totpCode(t, secret, step+1)); err != nil {
+		t.Fatalf("next step: %v", err)
+	}
+	if err := mfa.validateTOTP("user", secret, totpCode(t, secret, step+1)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("replayed next step: got %v, want %v", err, ErrInvalidMFACode)
+	}
+	if err := mfa.validateTOTP("other", secret, code); err != nil {
+		t.Fatalf("other user: %v", err)
+	}
+}
+
+// mfaFixture enables two-factor authentication for the seeded editor and
+// returns the service, the TOTP secret, the recovery codes and the time step
+// whose code was used to enable it.
+func mfaFixture(t *testing.T) (*MFAService, *sql.DB, string, []string, int64) {
+	t.Helper()
+	db := dbtest.DB(t)
+	dbtest.Seed(t, db)
+	mfa := NewMFAService(db, redistest.New(t))
+	editor := seededUser(t, db, "user_editor")
+
+	setup, err := mfa.Setup(editor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := currentStep(t)
+	codes, err := mfa.Enable(editor, totpCode(t, setup.Secret, step))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+	return mfa, db, setup.Secret, codes, step
+}
+
+func TestVerifyCode(t *testing.T) {
+	mfa, db, secret, codes, step := mfaFixture(t)
+
+	if err := mfa.VerifyCode("user_editor", totpCode(t, secret, step)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("code used to enable: got %v, want %v", err, ErrInvalidMFACode)
+	}
+	if err := mfa.VerifyCode("user_editor", totpCode(t, secret, step-1)); err != nil {
+		t.Fatalf("previous step: %v", err)
+	}
+	if err := mfa.VerifyCode("user_editor", totpCode(t, secret, step-2)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("code two steps old: got %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	if err := mfa.VerifyCode("user_editor", codes[0]); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if err := mfa.VerifyCode("user_editor", codes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("used recovery code: got %v, want %v", err, ErrInvalidMFACode)
+	}
+	// Recovery codes are accepted however they are typed.
+	if err := mfa.VerifyCode("user_editor", " "+strings.ToUpper(strings.ReplaceAll(codes[1], "-", ""))+" "); err != nil {
+		t.Fatalf("recovery code typed without the dash: %v", err)
+	}
+	if err := mfa.VerifyCode("user_editor", "00000-00000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("unknown recovery code: got %v, want %v", err, ErrInvalidMFACode)
+	}
+	if n := countRows(t, db, "SELECT count(*) FROM two_factor_recovery_codes WHERE user_id = 'user_editor' AND used_at IS NULL"); n != recoveryCodeCount-2 {
+		t.Fatalf("unused recovery codes: got %d, want %d", n, recoveryCodeCount-2)
+	}
+
+	if err := mfa.VerifyCode("user_admin", totpCode(t, secret, step)); !errors.Is(err, ErrMFANotEnabled) {
+		t.Fatalf("user without 2FA: got %v, want %v", err, ErrMFANotEnabled)
+	}
+}
+
+func TestCompleteChallengeLimitsAttempts(t *testing.T) {
+	mfa, _, _, codes, _ := mfaFixture(t)
+	challenge := func() string {
+		c, err := mfa.createChallenge("user_editor", LoginMethodPassword, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.MFAToken
+	}
+
+	if _, err := mfa.completeChallenge("unknown", codes[0]); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("unknown token: got %v, want %v", err, ErrInvalidMFAToken)
+	}
+
+	// A token allows mfaMaxAttempts codes, the last of which may be right.
+	token := challenge()
+	for i := 1; i < mfaMaxAttempts; i++ {
+		if _, err := mfa.completeChallenge(token, "00000-00000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("wrong code %d: got %v, want %v", i, err, ErrInvalidMFACode)
+		}
+	}
+	pending, err := mfa.completeChallenge(token, codes[0])
+	if err != nil {
+		t.Fatalf("right code on attempt %d: %v", mfaMaxAttempts, err)
+	}
+	if pending.UserID != "user_editor" || pending.LoginMethod != LoginMethodPassword {
+		t.Fatalf("pending login: got %+v", pending)
+	}
+	if _, err := mfa.completeChallenge(token, codes[1]); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("completed token: got %v, want %v", err, ErrInvalidMFAToken)
+	}
+
+	// After mfaMaxAttempts wrong codes the token is gone, and a right code
+	// is refused without being checked.
+	token = challenge()
+	for i := 1; i <= mfaMaxAttempts; i++ {
+		if _, err := mfa.completeChallenge(token, "00000-00000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("wrong code %d: got %v, want %v", i, err, ErrInvalidMFACode)
+		}
+	}
+	if _, err := mfa.completeChallenge(token, codes[1]); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("exhausted token: got %v, want %v", err, ErrInvalidMFAToken)
+	}
+	if _, err := mfa.completeChallenge(token, codes[1]); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("exhausted token, again: got %v, want %v", err, ErrInvalidMFAToken)
+	}
+	if err := mfa.VerifyCode("user_editor", codes[1]); err != nil {
+		t.Fatalf("recovery code refused by the exhausted token was used up: %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/oidc"
+	"smg/pkg/rbac"
+)
+
+const (
+	oauthProviderGoogle = "google"
+	oauthStateTTL       = time.Minute * 10
+)
+
+var (
+	// ErrOAuthNotConfigured is returned when no OIDC client is configured.
+	ErrOAuthNotConfigured = apperr.New(apperr.KindNotFound, "google_login_not_configured", "Google login is not configured")
+	// ErrOAuthState is returned for unknown or expired login states.
+	ErrOAuthState = apperr.Unauthorized("oauth_state_invalid", "Invalid or expired login state")
+	// ErrEmailNotVerified is returned when the identity provider has not
+	// verified the email address, which is required to create or link an
+	// account.
+	ErrEmailNotVerified = apperr.Unauthorized("email_not_verified", "Email address is not verified by the identity provider")
+)
+
+// OAuthService signs users in through an OpenID Connect provider and keeps
+// the provider identities in the accounts table.
+type OAuthService struct {
+	db          *sql.DB
+	redisClient *redis.Client
+	authService *AuthService
+	provider    *oidc.Provider
+}
+
+type oauthState struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// NewOAuthService creates the service. provider may be nil, in which case
+// every login fails with ErrOAuthNotConfigured.
+func NewOAuthService(db *sql.DB, redisClient *redis.Client, authService *AuthService, provider *oidc.Provider) *OAuthService {
+	return &OAuthService{
+		db:          db,
+		redisClient: redisClient,
+		authService: authService,
+		provider:    provider,
+	}
+}
+
+// StartGoogleLogin returns the provider URL to send the user to. The state,
+// nonce and PKCE verifier are kept in Redis until the callback.
+func (s *OAuthService) StartGoogleLogin() (string, error) {
+	if s.provider == nil {
+		return "", ErrOAuthNotConfigured
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	pending := oauthState{}
+	if pending.Nonce, err = randomToken(); err != nil {
+		return "", err
+	}
+	if pending.CodeVerifier, err = randomToken(); err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(pending)
+	if err != nil {
+		return "", err
+	}
+	ctx := context.Background()
+	if err := s.redisClient.Set(ctx, oauthStateKey(state), encoded, oauthStateTTL).Err(); err != nil {
+		return "", err
+	}
+
+	return s.provider.AuthCodeURL(ctx, state, pending.Nonce, pending.CodeVerifier)
+}
+
+// CompleteGoogleLogin handles the provider callback: it exchanges the code,
+// verifies the ID token and signs in the linked user, linking by verified
+// email or creating the user on first login. Users with two-factor
+// authentication get an MFA challenge instead of tokens.
+func (s *OAuthService) CompleteGoogleLogin(code, state string, client *models.ClientInfo) (*models.AuthResponse, *models.MFAChallenge, error) {
+	if s.provider == nil {
+		return nil, nil, ErrOAuthNotConfigured
+	}
+
+	ctx := context.Background()
+	encoded, err := s.redisClient.GetDel(ctx, oauthStateKey(state)).Result()
+	if err == redis.Nil {
+		return nil, nil, ErrOAuthState
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pending oauthState
+	if err := json.Unmarshal([]byte(encoded), &pending); err != nil {
+		return nil, nil, ErrOAuthState
+	}
+
+	token, err := s.provider.Exchange(ctx, code, pending.CodeVerifier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claims, err := s.provider.VerifyIDToken(ctx, token.IDToken, pending.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userID, err := s.linkAccount(claims, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.authService.getUser(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.authService.completeLogin(user, LoginMethodGoogle, client)
+}
+
+// linkAccount finds or creates the user for the provider identity and
+// stores the latest provider tokens on the account.
+func (s *OAuthService) linkAccount(claims *oidc.IDClaims, token *oidc.Token) (string, error) {
+	var expiresAt *int64
+	if token.ExpiresIn > 0 {
+		expires := time.Now().Unix() + token.ExpiresIn
+		expiresAt = &expires
+	}
+
+	var userID string
+	err := s.db.QueryRow(
+		"SELECT user_id FROM accounts WHERE provider = $1 AND provider_account_id = $2",
+		oauthProviderGoogle, claims.Subject,
+	).Scan(&userID)
+	if err == nil {
+		_, err = s.db.Exec(`
+			UPDATE accounts
+			SET access_token = $3, refresh_token = COALESCE($4, refresh_token), expires_at = $5,
+				token_type = $6, scope = $7, id_token = $8
+			WHERE provider = $1 AND provider_account_id = $2
+		`, oauthProviderGoogle, claims.Subject, token.AccessToken, nullIfEmpty(token.RefreshToken),
+			expiresAt, nullIfEmpty(token.TokenType), nullIfEmpty(token.Scope), token.IDToken)
+		return userID, err
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	if !claims.EmailVerified || claims.Email == "" {
+		return "", ErrEmailNotVerified
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var created *models.User
+	err = tx.QueryRow("SELECT id FROM users WHERE lower(email) = lower($1)", claims.Email).Scan(&userID)
+	if err == sql.ErrNoRows {
+		created, err = createOAuthUser(tx, claims)
+		if err != nil {
+			return "", err
+		}
+		userID = created.ID
+	} else if err != nil {
+		return "", err
+	} else {
+		// The provider has verified the address, so the existing user owns it.
+		_, err = tx.Exec(
+			"UPDATE users SET email_verified = COALESCE(email_verified, $2), updated_at = $2 WHERE id = $1",
+			userID, time.Now(),
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO accounts (id, user_id, type, provider, provider_account_id, access_token, refresh_token,
+			expires_at, token_type, scope, id_token)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, uuid.New().String(), userID, "oidc", oauthProviderGoogle, claims.Subject, token.AccessToken,
+		nullIfEmpty(token.RefreshToken), expiresAt, nullIfEmpty(token.TokenType), nullIfEmpty(token.Scope), token.IDToken)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	if created != nil {
+		if err := NewWorkspaceService(s.db).EnsurePersonalWorkspace(created); err != nil {
+			return "", err
+		}
+	}
+
+	return userID, nil
+}
+
+func createOAuthUser(tx *sql.Tx, claims *oidc.IDClaims) (*models.User, error) {
+	now := time.Now()
+	user := &models.User{
+		ID:            uuid.New().String(),
+		Name:          nullIfEmpty(claims.Name),
+		Email:         claims.Email,
+		EmailVerified: &now,
+		Image:         nullIfEmpty(claims.Picture),
+		Role:          rbac.DefaultRole,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO users (id, name, email, email_verified, image, is_admin, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, user.ID, user.Name, user.Email, user.EmailVerified, user.Image, false, user.Role, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func oauthStateKey(state string) string {
+	return fmt.Sprintf("oauth:state:%s", state)
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"smg/pkg/dbtest"
+	"smg/pkg/jwtkeys"
+	"smg/pkg/models"
+	"smg/pkg/oidc"
+	"smg/pkg/oidc/oidctest"
+	"smg/pkg/redistest"
+)
+
+// oauthFixture returns an OAuth service that signs in through a fresh
+// oidctest issuer, the auth service completing its logins and the Redis
+// client holding the pending login states. db may be nil for tests that
+// must fail before an account is looked up.
+func oauthFixture(t *testing.T, db *sql.DB) (*OAuthService, *AuthService, *oidctest.Issuer, *redis.Client) {
+	t.Helper()
+	issuer := oidctest.NewIssuer("client-id", "client-secret")
+	t.Cleanup(issuer.Close)
+
+	keys, err := jwtkeys.Load(jwtkeys.Config{Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redisClient := redistest.NewWithScripts(t, allowRequests)
+	auth := NewAuthService(db, redisClient, keys)
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       issuer.URL,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://app.example.com/api/auth/google/callback",
+	})
+
+	return NewOAuthService(db, redisClient, auth, provider), auth, issuer, redisClient
+}
+
+// allowRequests stands in for the rate limit script, letting every request
+// through.
+func allowRequests(string, []string, []string) (interface{}, error) {
+	return int64(0), nil
+}
+
+// authorize starts a login and returns the code and state the issuer
+// redirects the browser back with.
+func authorize(t *testing.T, oauth *OAuthService, issuer *oidctest.Issuer) (code, state string) {
+	t.Helper()
+	authURL, err := oauth.StartGoogleLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err = issuer.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, state
+}
+
+// tamperState changes the stored nonce or PKCE verifier of a pending login,
+// as if the callback belonged to another login.
+func tamperState(t *testing.T, redisClient *redis.Client, state string, change func(*oauthState)) {
+	t.Helper()
+	ctx := context.Background()
+	encoded, err := redisClient.Get(ctx, oauthStateKey(state)).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pending oauthState
+	if err := json.Unmarshal([]byte(encoded), &pending); err != nil {
+		t.Fatal(err)
+	}
+	change(&pending)
+	changed, err := json.Marshal(pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := redisClient.Set(ctx, oauthStateKey(state), changed, oauthStateTTL).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGoogleLoginNotConfigured(t *testing.T) {
+	oauth := NewOAuthService(nil, nil, nil, nil)
+	if _, err := oauth.StartGoogleLogin(); !errors.Is(err, ErrOAuthNotConfigured) {
+		t.Fatalf("start: got %v, want %v", err, ErrOAuthNotConfigured)
+	}
+	if _, _, err := oauth.CompleteGoogleLogin("code", "state", nil); !errors.Is(err, ErrOAuthNotConfigured) {
+		t.Fatalf("callback: got %v, want %v", err, ErrOAuthNotConfigured)
+	}
+}
+
+// The callbacks below are rejected before any account is looked up: the
+// services have no database, so reaching it would panic.
+func TestGoogleLoginRejectsMismatchedCallbacks(t *testing.T) {
+	oauth, _, issuer, redisClient := oauthFixture(t, nil)
+	issuer.SetUser(oidctest.User{Subject: "google-1", Email: "ada@example.com", EmailVerified: true})
+
+	t.Run("unknown state", func(t *testing.T) {
+		code, _ := authorize(t, oauth, issuer)
+		if _, _, err := oauth.CompleteGoogleLogin(code, "forged", nil); !errors.Is(err, ErrOAuthState) {
+			t.Fatalf("got %v, want %v", err, ErrOAuthState)
+		}
+	})
+
+	t.Run("state used twice", func(t *testing.T) {
+		code, state := authorize(t, oauth, issuer)
+		tamperState(t, redisClient, state, func(pending *oauthState) { pending.Nonce = "another login" })
+		if _, _, err := oauth.CompleteGoogleLogin(code, state, nil); err == nil {
+			t.Fatal("callback with a tampered state succeeded")
+		}
+		if _, _, err := oauth.CompleteGoogleLogin(code, state, nil); !errors.Is(err, ErrOAuthState) {
+			t.Fatalf("replayed state: got %v, want %v", err, ErrOAuthState)
+		}
+	})
+
+	t.Run("PKCE verifier mismatch", func(t *testing.T) {
+		code, state := authorize(t, oauth, issuer)
+		tamperState(t, redisClient, state, func(pending *oauthState) { pending.CodeVerifier = "another verifier" })
+		_, _, err := oauth.CompleteGoogleLogin(code, state, nil)
+		if err == nil || errors.Is(err, ErrOAuthState) {
+			t.Fatalf("got %v, want the issuer to refuse the code exchange", err)
+		}
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		code, state := authorize(t, oauth, issuer)
+		tamperState(t, redisClient, state, func(pending *oauthState) { pending.Nonce = "another nonce" })
+		if _, _, err := oauth.CompleteGoogleLogin(code, state, nil); !errors.Is(err, oidc.ErrNonceMismatch) {
+			t.Fatalf("got %v, want %v", err, oidc.ErrNonceMismatch)
+		}
+	})
+
+	t.Run("issuer mismatch", func(t *testing.T) {
+		issuer.SetClaims(jwt.MapClaims{"iss": "https://issuer.example.com"})
+		defer issuer.SetClaims(nil)
+		code, state := authorize(t, oauth, issuer)
+		if _, _, err := oauth.CompleteGoogleLogin(code, state, nil); !errors.Is(err, oidc.ErrInvalidIDToken) {
+			t.Fatalf("got %v, want %v", err, oidc.ErrInvalidIDToken)
+		}
+	})
+
+	t.Run("audience mismatch", func(t *testing.T) {
+		issuer.SetClaims(jwt.MapClaims{"aud": "another-client"})
+		defer issuer.SetClaims(nil)
+		code, state := authorize(t, oauth, issuer)
+		if _, _, err := oauth.CompleteGoogleLogin(code, state, nil); !errors.Is(err, oidc.ErrInvalidIDToken) {
+			t.Fatalf("got %v, want %v", err, oidc.ErrInvalidIDToken)
+		}
+	})
+}
+
+func countRows(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+	var count int
+	if err := db.QueryRow(query, args...).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestGoogleLoginRequiresVerifiedEmail(t *testing.T) {
+	db := dbtest.DB(t)
+	dbtest.Seed(t, db)
+	oauth, _, issuer, _ := oauthFixture(t, db)
+
+	for _, user := range []oidctest.User{
+		{Subject: "google-editor", Email: "editor@example.com", EmailVerified: false},
+		{Subject: "google-new", Email: "new@example.com", EmailVerified: false},
+	} {
+		issuer.SetUser(user)
+		code, state := authorize(t, oauth, issuer)
+		if _, _, err := oauth.CompleteGoogleLogin(code, state, nil); !errors.Is(err, ErrEmailNotVerified) {
+			t.Fatalf("unverified %s: got %v, want %v", user.Email, err, ErrEmailNotVerified)
+		}
+	}
+
+	if n := countRows(t, db, "SELECT count(*) FROM accounts"); n != 0 {
+		t.Fatalf("unverified logins linked %d account(s)", n)
+	}
+	if n := countRows(t, db, "SELECT count(*) FROM users WHERE email = 'new@example.com'"); n != 0 {
+		t.Fatal("unverified login created a user")
+	}
+	if seededUser(t, db, "user_editor").EmailVerified != nil {
+		t.Fatal("unverified login verified the existing user's address")
+	}
+}
+
+func TestGoogleLoginLinksExistingUser(t *testing.T) {
+	db := dbtest.DB(t)
+	dbtest.Seed(t, db)
+	oauth, auth, issuer, _ := oauthFixture(t, db)
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE users SET password = $1 WHERE id = 'user_editor'", string(hashed)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The address matches the seeded editor case-insensitively.
+	issuer.SetUser(oidctest.User{Subject: "google-editor", Email: "Editor@Example.com", EmailVerified: true, Name: "Ed"})
+	code, state := authorize(t, oauth, issuer)
+	google, challenge, err := oauth.CompleteGoogleLogin(code, state, &models.ClientInfo{DeviceName: "Laptop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge != nil || google.User.ID != "user_editor" {
+		t.Fatalf("callback: got response %+v, challenge %+v", google, challenge)
+	}
+
+	var account struct {
+		userID, kind, tokenType, scope string
+		accessToken, idToken           sql.NullString
+		expiresAt                      sql.NullInt64
+	}
+	err = db.QueryRow(`
+		SELECT user_id, type, token_type, scope, access_token, id_token, expires_at
+		FROM accounts WHERE provider = 'google' AND provider_account_id = 'google-editor'
+	`).Scan(&account.userID, &account.kind, &account.tokenType, &account.scope,
+		&account.accessToken, &account.idToken, &account.expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.userID != "user_editor" || account.kind != "oidc" || account.tokenType != "Bearer" ||
+		account.scope != "openid email profile" || !account.accessToken.Valid || !account.idToken.Valid || !account.expiresAt.Valid {
+		t.Fatalf("linked account: got %+v", account)
+	}
+	if seededUser(t, db, "user_editor").EmailVerified == nil {
+		t.Fatal("linking by a verified address did not verify it")
+	}
+
+	// Later logins find the account by subject, whatever the address.
+	issuer.SetUser(oidctest.User{Subject: "google-editor", Email: "renamed@example.com", EmailVerified: true})
+	code, state = authorize(t, oauth, issuer)
+	again, _, err := oauth.CompleteGoogleLogin(code, state, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.User.ID != "user_editor" {
+		t.Fatalf("second login: got user %s", again.User.ID)
+	}
+	if n := countRows(t, db, "SELECT count(*) FROM accounts WHERE user_id = 'user_editor'"); n != 1 {
+		t.Fatalf("accounts of the editor: got %d, want 1", n)
+	}
+
+	password, _, err := auth.Login("editor@example.com", "password", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, response := range map[string]*models.AuthResponse{"google": google, "password": password} {
+		user, err := auth.ValidateToken(response.AccessToken)
+		if err != nil || user.ID != "user_editor" {
+			t.Fatalf("%s access token: got %+v, %v", name, user, err)
+		}
+		if _, err := auth.RefreshToken(response.RefreshToken); err != nil {
+			t.Fatalf("%s refresh token: %v", name, err)
+		}
+	}
+	googleUser, _ := json.Marshal(google.User)
+	passwordUser, _ := json.Marshal(password.User)
+	if string(googleUser) != string(passwordUser) || google.ExpiresIn != password.ExpiresIn {
+		t.Fatalf("google login response differs from password login:\n%s %d\n%s %d",
+			googleUser, google.ExpiresIn, passwordUser, password.ExpiresIn)
+	}
+
+	if n := countRows(t, db, "SELECT count(*) FROM sessions WHERE user_id = 'user_editor' AND login_method = $1", LoginMethodGoogle); n != 2 {
+		t.Fatalf("google sessions: got %d, want 2", n)
+	}
+}
+
+func TestGoogleLoginCreatesUser(t *testing.T) {
+	db := dbtest.DB(t)
+	dbtest.Seed(t, db)
+	oauth, _, issuer, _ := oauthFixture(t, db)
+
+	issuer.SetUser(oidctest.User{Subject: "google-new", Email: "new@example.com", EmailVerified: true, Name: "New"})
+	code, state := authorize(t, oauth, issuer)
+	response, _, err := oauth.CompleteGoogleLogin(code, state, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := response.User
+	if user.Email != "new@example.com" || user.EmailVerified == nil || user.IsAdmin || user.Name == nil || *user.Name != "New" {
+		t.Fatalf("created user: got %+v", user)
+	}
+	if n := countRows(t, db, "SELECT count(*) FROM accounts WHERE user_id = $1 AND provider_account_id = 'google-new'", user.ID); n != 1 {
+		t.Fatalf("accounts of the created user: got %d, want 1", n)
+	}
+	if n := countRows(t, db, "SELECT count(*) FROM workspaces WHERE id = $1 AND owner_id = $2", PersonalWorkspaceID(user.ID), user.ID); n != 1 {
+		t.Fatal("created user has no personal workspace")
+	}
+}
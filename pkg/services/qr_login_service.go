@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+)
+
+const qrLoginTTL = time.Minute * 2
+
+// QR login statuses. A session moves from pending to scanned when an
+// authenticated device opens it, then to confirmed or rejected by that
+// device, and from confirmed to completed when the requesting device
+// collects its tokens.
+const (
+	QRLoginPending   = "pending"
+	QRLoginScanned   = "scanned"
+	QRLoginConfirmed = "confirmed"
+	QRLoginRejected  = "rejected"
+	QRLoginCompleted = "completed"
+)
+
+var (
+	// ErrQRLoginNotFound is returned for unknown or expired QR sessions.
+	ErrQRLoginNotFound = apperr.NotFound("qr_login")
+	// ErrQRLoginState is returned when a QR session is not in the state the
+	// step requires, or belongs to another approving user.
+	ErrQRLoginState = apperr.Conflict("qr_login_state", "QR login cannot be changed in its current state")
+	// ErrQRLoginPollToken is returned when the poll token does not match.
+	ErrQRLoginPollToken = apperr.Unauthorized("qr_login_poll_token", "Invalid poll token")
+)
+
+// QRLoginService runs the QR login handshake. The device that wants to sign
+// in creates a session and shows its ID as a QR code; a device that is
+// already signed in scans it, sees the requesting device's details and
+// confirms; the requesting device then collects tokens with the poll token
+// only it knows. Sessions live in Redis and every change is published so
+// waiting clients are woken up.
+type QRLoginService struct {
+	redisClient *redis.Client
+	authService *AuthService
+}
+
+type qrLogin struct {
+	Status        string            `json:"status"`
+	PollTokenHash string            `json:"poll_token_hash"`
+	Requester     models.ClientInfo `json:"requester"`
+	UserID        string            `json:"user_id,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	ExpiresAt     time.Time         `json:"expires_at"`
+}
+
+func NewQRLoginService(redisClient *redis.Client, authService *AuthService) *QRLoginService {
+	return &QRLoginService{
+		redisClient: redisClient,
+		authService: authService,
+	}
+}
+
+// Create starts a QR login for the requesting device.
+func (s *QRLoginService) Create(client *models.ClientInfo) (*models.QRLoginSession, error) {
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	pollToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	record := qrLogin{
+		Status:        QRLoginPending,
+		PollTokenHash: hashPollToken(pollToken),
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(qrLoginTTL),
+	}
+	if client != nil {
+		record.Requester = *client
+	}
+
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisClient.Set(context.Background(), qrLoginKey(id), encoded, qrLoginTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	return &models.QRLoginSession{
+		ID:        id,
+		PollToken: pollToken,
+		QRCode:    "smg://qr-login?id=" + id,
+		ExpiresIn: int64(qrLoginTTL.Seconds()),
+	}, nil
+}
+
+// Scan claims a pending session for the scanning user and returns the
+// requesting device's details. Scanning again by the same user is allowed.
+func (s *QRLoginService) Scan(user *models.User, id string) (*models.QRLoginDetails, error) {
+	record, err := s.update(id, func(record *qrLogin) error {
+		switch {
+		case record.Status == QRLoginPending:
+			record.Status = QRLoginScanned
+			record.UserID = user.ID
+			return nil
+		case record.Status == QRLoginScanned && record.UserID == user.ID:
+			return nil
+		default:
+			return ErrQRLoginState
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.QRLoginDetails{
+		ID:         id,
+		Status:     record.Status,
+		DeviceName: record.Requester.DeviceName,
+		UserAgent:  record.Requester.UserAgent,
+		IPAddress:  record.Requester.IPAddress,
+		CreatedAt:  record.CreatedAt,
+		ExpiresAt:  record.ExpiresAt,
+	}, nil
+}
+
+// Confirm approves a session the user has scanned.
+func (s *QRLoginService) Confirm(user *models.User, id string) error {
+	_, err := s.update(id, func(record *qrLogin) error {
+		if record.Status != QRLoginScanned || record.UserID != user.ID {
+			return ErrQRLoginState
+		}
+		record.Status = QRLoginConfirmed
+		return nil
+	})
+	return err
+}
+
+// Reject declines a session the user has scanned.
+func (s *QRLoginService) Reject(user *models.User, id string) error {
+	_, err := s.update(id, func(record *qrLogin) error {
+		if record.Status != QRLoginScanned || record.UserID != user.ID {
+			return ErrQRLoginState
+		}
+		record.Status = QRLoginRejected
+		return nil
+	})
+	return err
+}
+
+// Poll returns the session status to the requesting device. The first poll
+// after confirmation completes the session and starts a login session for
+// the requesting device.
+func (s *QRLoginService) Poll(id, pollToken string) (*models.QRLoginStatus, error) {
+	var completed bool
+	record, err := s.update(id, func(record *qrLogin) error {
+		completed = false
+		if subtle.ConstantTimeCompare([]byte(record.PollTokenHash), []byte(hashPollToken(pollToken))) != 1 {
+			return ErrQRLoginPollToken
+		}
+		if record.Status == QRLoginConfirmed {
+			record.Status = QRLoginCompleted
+			completed = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.QRLoginStatus{Status: record.Status}
+	if !completed {
+		return status, nil
+	}
+
+	user, err := s.authService.getUser(record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	status.Auth, err = s.authService.startSession(user, LoginMethodQR, &record.Requester)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// Subscribe returns a subscription that receives the new status whenever
+// the session changes. The caller must close it.
+func (s *QRLoginService) Subscribe(ctx context.Context, id string) (*redis.PubSub, error) {
+	pubsub := s.redisClient.Subscribe(ctx, qrLoginChannel(id))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	return pubsub, nil
+}
+
+// QRLoginDone reports whether status is final.
+func QRLoginDone(status string) bool {
+	return status == QRLoginCompleted || status == QRLoginRejected
+}
+
+// update applies change to the session atomically, keeping its expiry, and
+// publishes the resulting status.
+func (s *QRLoginService) update(id string, change func(*qrLogin) error) (*qrLogin, error) {
+	ctx := context.Background()
+	key := qrLoginKey(id)
+
+	var record qrLogin
+	transaction := func(tx *redis.Tx) error {
+		encoded, err := tx.Get(ctx, key).Result()
+		if err == redis.Nil {
+			return ErrQRLoginNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		record = qrLogin{}
+		if err := json.Unmarshal([]byte(encoded), &record); err != nil {
+			return err
+		}
+		previous := record.Status
+		if err := change(&record); err != nil {
+			return err
+		}
+		if record.Status == previous {
+			return nil
+		}
+
+		updated, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, updated, redis.KeepTTL)
+			pipe.Publish(ctx, qrLoginChannel(id), record.Status)
+			return nil
+		})
+		return err
+	}
+
+	// Retry when another request changed the session concurrently.
+	for attempt := 0; attempt < 3; attempt++ {
+		err := s.redisClient.Watch(ctx, transaction, key)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &record, nil
+	}
+
+	return nil, ErrQRLoginState
+}
+
+func hashPollToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func qrLoginKey(id string) string {
+	return fmt.Sprintf("auth:qr:%s", id)
+}
+
+func qrLoginChannel(id string) string {
+	return fmt.Sprintf("auth:qr:events:%s", id)
+}
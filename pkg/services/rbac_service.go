@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/rbac"
+	"smg/pkg/repository"
+)
+
+// ErrOwnerRequired is returned when a non-owner tries to grant or revoke
+// the owner role.
+var ErrOwnerRequired = apperr.Forbidden("owner_required", "Only owners can grant or revoke the owner role")
+
+const permissionsCacheTTL = time.Minute * 10
+
+type RBACService struct {
+	store       repository.Store
+	redisClient *redis.Client
+}
+
+func NewRBACService(store repository.Store, redisClient *redis.Client) *RBACService {
+	return &RBACService{
+		store:       store,
+		redisClient: redisClient,
+	}
+}
+
+// Permissions returns the permissions granted to the user by their current
+// role. Results are cached in Redis and dropped whenever the role changes.
+func (s *RBACService) Permissions(userID string) ([]string, error) {
+	ctx := context.Background()
+	key := permissionsCacheKey(userID)
+
+	if cached, err := s.redisClient.Get(ctx, key).Result(); err == nil {
+		var permissions []string
+		if err := json.Unmarshal([]byte(cached), &permissions); err == nil {
+			return permissions, nil
+		}
+	}
+
+	user, err := s.store.Users().Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := rbac.Permissions(user.Role)
+
+	// A cache write failure only costs a database round trip next time.
+	if encoded, err := json.Marshal(permissions); err == nil {
+		s.redisClient.Set(ctx, key, encoded, permissionsCacheTTL)
+	}
+
+	return permissions, nil
+}
+
+func (s *RBACService) GetRoles() []models.RoleInfo {
+	var roles []models.RoleInfo
+	for _, role := range rbac.Roles() {
+		roles = append(roles, models.RoleInfo{
+			Name:        role,
+			Permissions: rbac.Permissions(role),
+		})
+	}
+	return roles
+}
+
+// SetRole changes a user's role and keeps is_admin in step with it.
+func (s *RBACService) SetRole(actor *models.User, userID, role string) (*models.User, error) {
+	err := s.store.InTx(func(store repository.Store) error {
+		current, err := store.Users().Get(userID)
+		if err != nil {
+			return notFound("user", err)
+		}
+
+		if (role == rbac.RoleOwner || current.Role == rbac.RoleOwner) && actor.Role != rbac.RoleOwner {
+			return ErrOwnerRequired
+		}
+
+		if err := store.Users().SetRole(userID, role, rbac.IsAdminRole(role), time.Now()); err != nil {
+			return err
+		}
+
+		if role != current.Role {
+			event := actorEvent(actor, AuditUserRoleChanged, "user", userID)
+			event.Before = map[string]interface{}{"role": current.Role}
+			event.After = map[string]interface{}{"role": role}
+			if err := store.Audit().Record(event); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.redisClient.Del(context.Background(), permissionsCacheKey(userID))
+
+	return NewUserService(s.store).GetProfile(userID)
+}
+
+func permissionsCacheKey(userID string) string {
+	return fmt.Sprintf("rbac:permissions:%s", userID)
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/rbac"
+	"smg/pkg/repository/memory"
+)
+
+// offlineRedis returns a client whose commands all fail, which the
+// services treat as an empty cache.
+func offlineRedis(t *testing.T) *redis.Client {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: time.Second})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// storedUser creates a user with the role in store and returns it as an
+// actor in a workspace of its own.
+func storedUser(t *testing.T, store *memory.Store, id, role string) *models.User {
+	t.Helper()
+	now := time.Now()
+	user := &models.User{
+		ID: id, Email: id + "@example.com", Role: role, IsAdmin: rbac.IsAdminRole(role), CreatedAt: now, UpdatedAt: now,
+	}
+	if err := store.Users().Create(user); err != nil {
+		t.Fatal(err)
+	}
+	actor := testActor(id, "ws_"+id)
+	actor.Role = role
+	actor.IsAdmin = user.IsAdmin
+	return actor
+}
+
+func TestRBACServiceSetRole(t *testing.T) {
+	store := memory.New()
+	roles := NewRBACService(store, offlineRedis(t))
+	admin := storedUser(t, store, "admin", rbac.RoleAdmin)
+	editor := storedUser(t, store, "editor", rbac.RoleEditor)
+
+	user, err := roles.SetRole(admin, editor.ID, rbac.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != rbac.RoleAdmin || !user.IsAdmin {
+		t.Fatalf("promoted user: got %+v", user)
+	}
+	permissions, err := roles.Permissions(editor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rbac.Has(permissions, rbac.RolesManage) {
+		t.Fatalf("promoted user's permissions: got %v", permissions)
+	}
+
+	events := store.AuditEvents()
+	if len(events) != 1 {
+		t.Fatalf("got %d audit events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Action != AuditUserRoleChanged || event.ResourceID != editor.ID || *event.ActorID != admin.ID ||
+		event.Before["role"] != rbac.RoleEditor || event.After["role"] != rbac.RoleAdmin {
+		t.Fatalf("unexpected audit event %+v", event)
+	}
+
+	if _, err := roles.SetRole(admin, editor.ID, rbac.RoleOwner); !errors.Is(err, ErrOwnerRequired) {
+		t.Fatalf("owner granted by an admin: got %v, want %v", err, ErrOwnerRequired)
+	}
+	if _, err := roles.SetRole(admin, "missing", rbac.RoleViewer); !errors.Is(err, apperr.NotFound("user")) {
+		t.Fatalf("role of a missing user: got %v, want user_not_found", err)
+	}
+	if user, _ := store.Users().Get(editor.ID); user.Role != rbac.RoleAdmin {
+		t.Fatalf("refused changes stored role %q", user.Role)
+	}
+	if events := store.AuditEvents(); len(events) != 1 {
+		t.Fatalf("refused changes recorded %d audit events", len(events)-1)
+	}
+}
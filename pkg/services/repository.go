@@ -0,0 +1,22 @@
+package services
+
+import (
+	"smg/pkg/models"
+	"smg/pkg/repository"
+)
+
+// pageOf selects the rows of a 1-based page.
+func pageOf(page, pageSize int) repository.Page {
+	return repository.Page{Limit: pageSize, Offset: (page - 1) * pageSize}
+}
+
+// paginated wraps one page of a list, of total rows in all, for the API.
+func paginated(data interface{}, total int64, page, pageSize int) *models.PaginatedResponse {
+	return &models.PaginatedResponse{
+		Data:       data,
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	}
+}
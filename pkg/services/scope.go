@@ -0,0 +1,62 @@
+package services
+
+import (
+	"database/sql"
+
+	"smg/pkg/models"
+	"smg/pkg/rbac"
+)
+
+// Content rows (topics, media accounts, articles and reposts) belong to a
+// workspace and are scoped with
+//
+//	AND workspace_id = $n
+//
+// bound to actor.WorkspaceID, the workspace selected for the request. Rows
+// that are still owned by a single user are scoped with
+//
+//	AND ($n OR user_id = $m)
+//
+// where $n is bound to actor.IsAdmin and $m to actor.ID; approval policies
+// are looked up with the owner policyOwner returns. Either way a row
+// outside the caller's reach is indistinguishable from a missing one: it
+// comes back as sql.ErrNoRows, which public methods turn into an
+// apperr.NotFound for the resource with notFound.
+
+// requireRows turns an UPDATE or DELETE that matched nothing into
+// sql.ErrNoRows.
+func requireRows(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// requireSelf returns sql.ErrNoRows unless actor is the user with the given
+// ID or an admin.
+func requireSelf(actor *models.User, userID string) error {
+	if actor.IsAdmin || actor.ID == userID {
+		return nil
+	}
+
+	return sql.ErrNoRows
+}
+
+// requireSelfOr returns sql.ErrNoRows unless actor is the user with the given
+// ID or actor's role grants permission.
+func requireSelfOr(actor *models.User, userID, permission string) error {
+	if actor.ID == userID || rbac.Has(rbac.Permissions(actor.Role), permission) {
+		return nil
+	}
+
+	return sql.ErrNoRows
+}
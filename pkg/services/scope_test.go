@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/rbac"
+	"smg/pkg/repository/memory"
+)
+
+// scopeFixture is content of one workspace: a topic with an article, a
+// media account, a repost awaiting approval and the approval policy that
+// covers it.
+type scopeFixture struct {
+	store *memory.Store
+	// actors by name: the owner of the content, an outsider in a workspace
+	// of their own, an admin who selected the owner's workspace, a support
+	// user who may read other users and the approver named by the policy.
+	actors  map[string]*models.User
+	topic   *models.Topic
+	article *models.Article
+	account *models.MediaAccount
+	repost  *models.Repost
+	policy  *models.ApprovalPolicy
+}
+
+func newScopeFixture(t *testing.T) *scopeFixture {
+	t.Helper()
+	store := memory.New()
+	owner := storedUser(t, store, "owner", rbac.RoleEditor)
+	admin := storedUser(t, store, "admin", rbac.RoleAdmin)
+	admin.WorkspaceID = owner.WorkspaceID
+	f := &scopeFixture{
+		store: store,
+		actors: map[string]*models.User{
+			"owner":    owner,
+			"outsider": storedUser(t, store, "outsider", rbac.RoleEditor),
+			"admin":    admin,
+			"support":  storedUser(t, store, "support", rbac.RoleSupport),
+			"approver": storedUser(t, store, "approver", rbac.RoleEditor),
+		},
+	}
+
+	f.article, f.account = articleFixture(t, store, owner)
+	topic, err := NewTopicService(store).GetTopic(owner, f.article.TopicID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.topic = topic
+
+	approvals := NewApprovalService(store)
+	f.policy, err = approvals.CreatePolicy(owner, &models.ApprovalPolicyRequest{
+		MediaAccountID: &f.account.ID, ApproverIDs: []string{"approver"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.repost, err = NewArticleService(store, approvals).RepostArticle(
+		context.Background(), owner, f.article.ID, &models.RepostRequest{MediaAccountID: f.account.ID},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+// TestScopedRoutes calls the service behind every route on a single
+// resource as each actor. Actors that may reach the resource succeed; the
+// outsider gets the resource's not found error, as if it did not exist.
+func TestScopedRoutes(t *testing.T) {
+	ctx := context.Background()
+	topics := func(f *scopeFixture) *TopicService { return NewTopicService(f.store) }
+	media := func(f *scopeFixture) *MediaService { return NewMediaService(f.store) }
+	articles := func(f *scopeFixture) *ArticleService {
+		return NewArticleService(f.store, NewApprovalService(f.store))
+	}
+	users := func(f *scopeFixture) *UserService { return NewUserService(f.store) }
+	approvals := func(f *scopeFixture) *ApprovalService { return NewApprovalService(f.store) }
+	name := "Renamed"
+
+	tests := []struct {
+		route    string
+		resource string
+		allowed  []string
+		call     func(f *scopeFixture, actor *models.User) error
+	}{
+		{"GET /topics/:id", "topic", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := topics(f).GetTopic(actor, f.topic.ID)
+			return err
+		}},
+		{"PUT /topics/:id", "topic", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := topics(f).UpdateTopic(actor, f.topic.ID, &models.CreateTopicRequest{Name: name})
+			return err
+		}},
+		{"DELETE /topics/:id", "topic", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			return topics(f).DeleteTopic(actor, f.topic.ID)
+		}},
+		{"GET /topics/:id/articles", "topic", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := topics(f).GetTopicArticles(actor, f.topic.ID, "", 1, 20)
+			return err
+		}},
+		{"GET /topics/:id/stats", "topic", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := topics(f).GetTopicStats(actor, f.topic.ID, 7, 5)
+			return err
+		}},
+
+		{"GET /media/accounts/:id", "media_account", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := media(f).GetAccount(actor, f.account.ID)
+			return err
+		}},
+		{"PUT /media/accounts/:id", "media_account", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := media(f).UpdateAccount(actor, f.account.ID, &models.ConnectPlatformRequest{AccountName: name})
+			return err
+		}},
+		{"DELETE /media/accounts/:id", "media_account", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			return media(f).DeleteAccount(actor, f.account.ID)
+		}},
+		{"POST /media/disconnect/:id", "media_account", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			return media(f).DisconnectAccount(actor, f.account.ID)
+		}},
+
+		{"GET /articles/:id", "article", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := articles(f).GetArticle(actor, f.article.ID)
+			return err
+		}},
+		{"PUT /articles/:id", "article", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := articles(f).UpdateArticle(actor, f.article.ID, &models.Article{Title: name})
+			return err
+		}},
+		{"DELETE /articles/:id", "article", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			return articles(f).DeleteArticle(actor, f.article.ID)
+		}},
+		{"POST /articles/:id/repost", "article", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := articles(f).RepostArticle(ctx, actor, f.article.ID, &models.RepostRequest{MediaAccountID: f.account.ID})
+			return err
+		}},
+
+		{"GET /users/:id", "user", []string{"owner", "admin", "support"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := users(f).GetUserByID(actor, "owner")
+			return err
+		}},
+		{"PUT /users/:id", "user", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := users(f).UpdateUser(actor, "owner", &models.UpdateProfileRequest{Name: &name})
+			return err
+		}},
+		{"DELETE /users/:id", "user", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			return users(f).DeleteUser(actor, "owner")
+		}},
+		{"GET /users/:id/stats", "user", []string{"owner", "admin", "support"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := users(f).GetUserStats(actor, "owner")
+			return err
+		}},
+
+		{"GET /reposts/:id/approvals", "repost", []string{"owner", "admin", "approver"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := approvals(f).GetRepostApprovals(actor, f.repost.ID)
+			return err
+		}},
+		{"POST /reposts/:id/approve", "repost", []string{"admin", "approver"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := approvals(f).ApproveRepost(ctx, f.repost.ID, actor, nil)
+			return err
+		}},
+		{"POST /reposts/:id/reject", "repost", []string{"admin", "approver"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := approvals(f).RejectRepost(ctx, f.repost.ID, actor, nil)
+			return err
+		}},
+
+		{"PUT /approval-policies/:id", "approval_policy", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			_, err := approvals(f).UpdatePolicy(actor, f.policy.ID, &models.ApprovalPolicyRequest{MediaAccountID: &f.account.ID})
+			return err
+		}},
+		{"DELETE /approval-policies/:id", "approval_policy", []string{"owner", "admin"}, func(f *scopeFixture, actor *models.User) error {
+			return approvals(f).DeletePolicy(actor, f.policy.ID)
+		}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.route, func(t *testing.T) {
+			f := newScopeFixture(t)
+			err := test.call(f, f.actors["outsider"])
+			if !errors.Is(err, apperr.NotFound(test.resource)) {
+				t.Fatalf("as outsider: got %v, want %s_not_found", err, test.resource)
+			}
+
+			for _, name := range test.allowed {
+				f := newScopeFixture(t)
+				if err := test.call(f, f.actors[name]); err != nil {
+					t.Fatalf("as %s: %v", name, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"smg/pkg/models"
+)
+
+// Login methods recorded on sessions.
+const (
+	LoginMethodPassword = "password"
+	LoginMethodQR       = "qr"
+	LoginMethodGoogle   = "google"
+)
+
+// lastSeenInterval throttles how often a session's last_seen_at is written.
+const lastSeenInterval = time.Minute
+
+// SessionService records one session per login. A session's session_token is
+// the family ID shared by every token issued since that login, so revoking a
+// session revokes its tokens.
+type SessionService struct {
+	db          *sql.DB
+	redisClient *redis.Client
+}
+
+func NewSessionService(db *sql.DB, redisClient *redis.Client) *SessionService {
+	return &SessionService{
+		db:          db,
+		redisClient: redisClient,
+	}
+}
+
+// CreateSession records a new login for the token family.
+func (s *SessionService) CreateSession(userID, familyID, loginMethod string, client *models.ClientInfo) error {
+	now := time.Now()
+	if client == nil {
+		client = &models.ClientInfo{}
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO sessions (id, session_token, user_id, expires, device_name, user_agent, ip_address,
+			login_method, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, uuid.New().String(), familyID, userID, now.Add(refreshTokenTTL),
+		nullIfEmpty(client.DeviceName), nullIfEmpty(client.UserAgent), nullIfEmpty(client.IPAddress),
+		loginMethod, now, now)
+	return err
+}
+
+// ExtendSession pushes the session's expiry out after its refresh token was
+// rotated.
+func (s *SessionService) ExtendSession(familyID string) error {
+	now := time.Now()
+	_, err := s.db.Exec(
+		"UPDATE sessions SET expires = $2, last_seen_at = $3 WHERE session_token = $1",
+		familyID, now.Add(refreshTokenTTL), now,
+	)
+	return err
+}
+
+// TouchSession updates last_seen_at at most once per lastSeenInterval.
+func (s *SessionService) TouchSession(familyID string) error {
+	ctx := context.Background()
+	first, err := s.redisClient.SetNX(ctx, sessionSeenKey(familyID), "1", lastSeenInterval).Result()
+	if err != nil || !first {
+		return err
+	}
+
+	_, err = s.db.Exec("UPDATE sessions SET last_seen_at = $2 WHERE session_token = $1", familyID, time.Now())
+	return err
+}
+
+// GetSessions lists the actor's active sessions, most recently used first.
+// The session the request was made with is marked as current.
+func (s *SessionService) GetSessions(actor *models.User) ([]models.Session, error) {
+	rows, err := s.db.Query(`
+		SELECT id, session_token, user_id, device_name, user_agent, ip_address, login_method,
+			created_at, last_seen_at, expires
+		FROM sessions
+		WHERE user_id = $1 AND expires > $2
+		ORDER BY last_seen_at DESC
+	`, actor.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		var familyID string
+		err := rows.Scan(
+			&session.ID, &familyID, &session.UserID, &session.DeviceName, &session.UserAgent,
+			&session.IPAddress, &session.LoginMethod, &session.CreatedAt, &session.LastSeenAt, &session.Expires,
+		)
+		if err != nil {
+			return nil, err
+		}
+		session.Current = familyID == actor.SessionID
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession signs out one of the actor's sessions.
+func (s *SessionService) RevokeSession(actor *models.User, sessionID string) error {
+	var familyID string
+	err := s.db.QueryRow(
+		"SELECT session_token FROM sessions WHERE id = $1 AND user_id = $2", sessionID, actor.ID,
+	).Scan(&familyID)
+	if err != nil {
+		return notFound("session", err)
+	}
+
+	if err := s.RevokeFamily(familyID); err != nil {
+		return err
+	}
+
+	return recordAudit(s.db, actorEvent(actor, AuditAuthSessionRevoked, "session", sessionID))
+}
+
+// RevokeFamily puts a token family on the revocation list and removes its
+// session. The revocation entry lives as long as the longest-lived token
+// that could have been issued in the family.
+func (s *SessionService) RevokeFamily(familyID string) error {
+	err := s.redisClient.Set(context.Background(), revokedFamilyKey(familyID), "1", refreshTokenTTL).Err()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("DELETE FROM sessions WHERE session_token = $1", familyID)
+	return err
+}
+
+// IsRevoked reports whether the token family is on the revocation list.
+func (s *SessionService) IsRevoked(familyID string) (bool, error) {
+	revoked, err := s.redisClient.Exists(context.Background(), revokedFamilyKey(familyID)).Result()
+	return revoked > 0, err
+}
+
+func revokedFamilyKey(familyID string) string {
+	return fmt.Sprintf("auth:revoked:family:%s", familyID)
+}
+
+func sessionSeenKey(familyID string) string {
+	return fmt.Sprintf("auth:session:seen:%s", familyID)
+}
+
+func nullIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
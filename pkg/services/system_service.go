@@ -1,185 +1,158 @@
 package services
 
 import (
-	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"smg/pkg/apperr"
 	"smg/pkg/models"
+	"smg/pkg/repository"
 )
 
+var ErrPlatformExists = apperr.Conflict("platform_exists", "A platform with this name already exists")
+
 type SystemService struct {
-	db *sql.DB
+	store repository.Store
 }
 
-func NewSystemService(db *sql.DB) *SystemService {
-	return &SystemService{db: db}
+func NewSystemService(store repository.Store) *SystemService {
+	return &SystemService{store: store}
 }
 
 func (s *SystemService) GetSettings() (map[string]string, error) {
-	rows, err := s.db.Query("SELECT key, value FROM system_settings")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	
-	settings := make(map[string]string)
-	for rows.Next() {
-		var key, value string
-		if err := rows.Scan(&key, &value); err != nil {
-			return nil, err
-		}
-		settings[key] = value
-	}
-	
-	return settings, nil
+	return s.store.Settings().All()
 }
 
-func (s *SystemService) UpdateSettings(settings map[string]string) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
+// UpdateSettings upserts the given settings and records the ones whose
+// value changed in the audit log.
+func (s *SystemService) UpdateSettings(actor *models.User, settings map[string]string) error {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
 	}
-	defer tx.Rollback()
-	
-	for key, value := range settings {
-		_, err := tx.Exec(`
-			INSERT INTO system_settings (id, key, value, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5)
-			ON CONFLICT (key) DO UPDATE SET
-			value = EXCLUDED.value,
-			updated_at = EXCLUDED.updated_at
-		`, uuid.New().String(), key, value, time.Now(), time.Now())
-		
+
+	return s.store.InTx(func(tx repository.Store) error {
+		current, err := tx.Settings().Lookup(keys)
 		if err != nil {
 			return err
 		}
-	}
-	
-	return tx.Commit()
+
+		before := make(map[string]interface{})
+		after := make(map[string]interface{})
+		for key, value := range settings {
+			previous, exists := current[key]
+			if exists && previous == value {
+				continue
+			}
+
+			if err := tx.Settings().Put(key, value); err != nil {
+				return err
+			}
+
+			if exists {
+				before[key] = previous
+			} else {
+				before[key] = nil
+			}
+			after[key] = value
+		}
+
+		if len(after) == 0 {
+			return nil
+		}
+
+		event := actorEvent(actor, AuditSystemSettingsUpdated, "system_settings", "")
+		event.Before = before
+		event.After = after
+		return tx.Audit().Record(event)
+	})
 }
 
 func (s *SystemService) GetStats() (*models.StatsResponse, error) {
 	var stats models.StatsResponse
-	
-	err := s.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&stats.TotalUsers)
-	if err != nil {
-		return nil, err
-	}
-	
-	err = s.db.QueryRow(`
-		SELECT COUNT(*) FROM users 
-		WHERE created_at > NOW() - INTERVAL '30 days'
-	`).Scan(&stats.ActiveUsers)
-	if err != nil {
-		return nil, err
-	}
-	
-	err = s.db.QueryRow("SELECT COUNT(*) FROM topics").Scan(&stats.TotalTopics)
-	if err != nil {
-		return nil, err
-	}
-	
-	err = s.db.QueryRow("SELECT COUNT(*) FROM articles").Scan(&stats.TotalArticles)
-	if err != nil {
-		return nil, err
-	}
-	
-	err = s.db.QueryRow("SELECT COUNT(*) FROM reposts").Scan(&stats.TotalReposts)
-	if err != nil {
-		return nil, err
-	}
-	
-	err = s.db.QueryRow(`
-		SELECT COUNT(*) FROM reposts 
-		WHERE created_at::date = CURRENT_DATE
-	`).Scan(&stats.RepostsToday)
-	if err != nil {
-		return nil, err
-	}
-	
-	return &stats, nil
-}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
-func (s *SystemService) GetPlatforms() ([]models.Platform, error) {
-	rows, err := s.db.Query(`
-		SELECT id, name, display_name, enabled, config, created_at, updated_at
-		FROM platforms
-		ORDER BY display_name
-	`)
-	if err != nil {
-		return nil, err
+	counts := []struct {
+		to    *int64
+		count func(repository.CountFilter) (int64, error)
+		since time.Time
+	}{
+		{&stats.TotalUsers, s.store.Users().Count, time.Time{}},
+		{&stats.ActiveUsers, s.store.Users().Count, now.AddDate(0, 0, -30)},
+		{&stats.TotalTopics, s.store.Topics().Count, time.Time{}},
+		{&stats.TotalArticles, s.store.Articles().Count, time.Time{}},
+		{&stats.TotalReposts, s.store.Reposts().Count, time.Time{}},
+		{&stats.RepostsToday, s.store.Reposts().Count, today},
 	}
-	defer rows.Close()
-	
-	var platforms []models.Platform
-	for rows.Next() {
-		var platform models.Platform
-		err := rows.Scan(
-			&platform.ID, &platform.Name, &platform.DisplayName,
-			&platform.Enabled, &platform.Config, &platform.CreatedAt, &platform.UpdatedAt,
-		)
+	for _, c := range counts {
+		count, err := c.count(repository.CountFilter{CreatedSince: c.since})
 		if err != nil {
 			return nil, err
 		}
-		platforms = append(platforms, platform)
+		*c.to = count
 	}
-	
-	return platforms, nil
+
+	return &stats, nil
+}
+
+func (s *SystemService) GetPlatforms() ([]models.Platform, error) {
+	return s.store.Platforms().List()
 }
 
 func (s *SystemService) CreatePlatform(platform *models.Platform) (*models.Platform, error) {
-	platformID := uuid.New().String()
 	now := time.Now()
-	
-	_, err := s.db.Exec(`
-		INSERT INTO platforms (id, name, display_name, enabled, config, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, platformID, platform.Name, platform.DisplayName, platform.Enabled, 
-		platform.Config, now, now)
-	
-	if err != nil {
-		return nil, err
+	row := &models.Platform{
+		ID:          uuid.New().String(),
+		Name:        platform.Name,
+		DisplayName: platform.DisplayName,
+		Enabled:     platform.Enabled,
+		Config:      platform.Config,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
-	
-	return s.GetPlatform(platformID)
+	if err := s.store.Platforms().Create(row); err != nil {
+		return nil, platformError(err)
+	}
+
+	return s.GetPlatform(row.ID)
 }
 
 func (s *SystemService) GetPlatform(platformID string) (*models.Platform, error) {
-	var platform models.Platform
-	err := s.db.QueryRow(`
-		SELECT id, name, display_name, enabled, config, created_at, updated_at
-		FROM platforms WHERE id = $1
-	`, platformID).Scan(
-		&platform.ID, &platform.Name, &platform.DisplayName,
-		&platform.Enabled, &platform.Config, &platform.CreatedAt, &platform.UpdatedAt,
-	)
-	
+	platform, err := s.store.Platforms().Get(platformID)
 	if err != nil {
-		return nil, err
+		return nil, notFound("platform", err)
 	}
-	
-	return &platform, nil
+
+	return platform, nil
 }
 
 func (s *SystemService) UpdatePlatform(platformID string, platform *models.Platform) (*models.Platform, error) {
-	now := time.Now()
-	
-	_, err := s.db.Exec(`
-		UPDATE platforms 
-		SET name = $2, display_name = $3, enabled = $4, config = $5, updated_at = $6
-		WHERE id = $1
-	`, platformID, platform.Name, platform.DisplayName, platform.Enabled, 
-		platform.Config, now)
-	
+	err := s.store.Platforms().Update(&models.Platform{
+		ID:          platformID,
+		Name:        platform.Name,
+		DisplayName: platform.DisplayName,
+		Enabled:     platform.Enabled,
+		Config:      platform.Config,
+		UpdatedAt:   time.Now(),
+	})
 	if err != nil {
-		return nil, err
+		return nil, platformError(err)
 	}
-	
+
 	return s.GetPlatform(platformID)
 }
 
 func (s *SystemService) DeletePlatform(platformID string) error {
-	_, err := s.db.Exec("DELETE FROM platforms WHERE id = $1", platformID)
-	return err
-}
\ No newline at end of file
+	return notFound("platform", s.store.Platforms().Delete(platformID))
+}
+
+// platformError turns a duplicate name into ErrPlatformExists and a
+// missing platform into a not found error.
+func platformError(err error) error {
+	if errors.Is(err, repository.ErrDuplicate) {
+		return ErrPlatformExists
+	}
+	return notFound("platform", err)
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"smg/pkg/models"
+	"smg/pkg/repository/memory"
+)
+
+func TestSystemServiceAuditsChangedSettings(t *testing.T) {
+	store := memory.New()
+	system := NewSystemService(store)
+	admin := testActor("admin", "workspace")
+
+	if err := system.UpdateSettings(admin, map[string]string{"site_name": "SMG", "signup": "open"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.UpdateSettings(admin, map[string]string{"site_name": "SMG", "signup": "closed", "theme": "dark"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.UpdateSettings(admin, map[string]string{"theme": "dark"}); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := system.GetSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != 3 || settings["signup"] != "closed" || settings["theme"] != "dark" {
+		t.Fatalf("unexpected settings %v", settings)
+	}
+
+	events := store.AuditEvents()
+	if len(events) != 2 {
+		t.Fatalf("got %d audit events, want one per update that changed something", len(events))
+	}
+	change := events[1]
+	if len(change.After) != 2 || change.After["signup"] != "closed" || change.After["theme"] != "dark" {
+		t.Fatalf("unexpected after %v", change.After)
+	}
+	if len(change.Before) != 2 || change.Before["signup"] != "open" || change.Before["theme"] != nil {
+		t.Fatalf("unexpected before %v", change.Before)
+	}
+}
+
+func TestSystemServicePlatformNamesAreUnique(t *testing.T) {
+	system := NewSystemService(memory.New())
+
+	weibo, err := system.CreatePlatform(&models.Platform{Name: "weibo", DisplayName: "Weibo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := system.CreatePlatform(&models.Platform{Name: "weibo", DisplayName: "Weibo"}); !errors.Is(err, ErrPlatformExists) {
+		t.Fatalf("CreatePlatform with a taken name: got %v, want %v", err, ErrPlatformExists)
+	}
+
+	twitter, err := system.CreatePlatform(&models.Platform{Name: "twitter", DisplayName: "Twitter"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := system.UpdatePlatform(twitter.ID, &models.Platform{Name: weibo.Name}); !errors.Is(err, ErrPlatformExists) {
+		t.Fatalf("UpdatePlatform to a taken name: got %v, want %v", err, ErrPlatformExists)
+	}
+}
@@ -1,169 +1,120 @@
 package services
 
 import (
-	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/lib/pq"
 	"smg/pkg/models"
+	"smg/pkg/repository"
 )
 
 type TopicService struct {
-	db *sql.DB
+	store repository.Store
 }
 
-func NewTopicService(db *sql.DB) *TopicService {
-	return &TopicService{db: db}
+func NewTopicService(store repository.Store) *TopicService {
+	return &TopicService{store: store}
 }
 
-func (s *TopicService) GetTopics(userID string, page, pageSize int) (*models.PaginatedResponse, error) {
-	offset := (page - 1) * pageSize
-	
-	var total int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM topics WHERE user_id = $1", userID).Scan(&total)
+func (s *TopicService) GetTopics(actor *models.User, page, pageSize int) (*models.PaginatedResponse, error) {
+	topics, total, err := s.store.Topics().List(actor.WorkspaceID, pageOf(page, pageSize))
 	if err != nil {
 		return nil, err
 	}
-	
-	rows, err := s.db.Query(`
-		SELECT id, name, description, keywords, platforms, user_id, created_at, updated_at
-		FROM topics 
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`, userID, pageSize, offset)
-	
-	if err != nil {
+
+	return paginated(topics, total, page, pageSize), nil
+}
+
+func (s *TopicService) CreateTopic(actor *models.User, req *models.CreateTopicRequest) (*models.Topic, error) {
+	now := time.Now()
+	topic := &models.Topic{
+		ID:          uuid.New().String(),
+		Name:        req.Name,
+		Description: req.Description,
+		Keywords:    req.Keywords,
+		Platforms:   req.Platforms,
+		WorkspaceID: actor.WorkspaceID,
+		UserID:      actor.ID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.store.Topics().Create(topic); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	
-	var topics []models.Topic
-	for rows.Next() {
-		var topic models.Topic
-		err := rows.Scan(
-			&topic.ID, &topic.Name, &topic.Description, 
-			pq.Array(&topic.Keywords), pq.Array(&topic.Platforms),
-			&topic.UserID, &topic.CreatedAt, &topic.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		topics = append(topics, topic)
+
+	return s.GetTopic(actor, topic.ID)
+}
+
+func (s *TopicService) GetTopic(actor *models.User, topicID string) (*models.Topic, error) {
+	topic, err := s.store.Topics().Get(actor.WorkspaceID, topicID)
+	if err != nil {
+		return nil, notFound("topic", err)
 	}
-	
-	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
-	
-	return &models.PaginatedResponse{
-		Data:       topics,
-		Page:       page,
-		PageSize:   pageSize,
-		Total:      total,
-		TotalPages: totalPages,
-	}, nil
+
+	return topic, nil
 }
 
-func (s *TopicService) CreateTopic(userID string, req *models.CreateTopicRequest) (*models.Topic, error) {
-	topicID := uuid.New().String()
-	now := time.Now()
-	
-	_, err := s.db.Exec(`
-		INSERT INTO topics (id, name, description, keywords, platforms, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, topicID, req.Name, req.Description, pq.Array(req.Keywords), pq.Array(req.Platforms), userID, now, now)
-	
+func (s *TopicService) UpdateTopic(actor *models.User, topicID string, req *models.CreateTopicRequest) (*models.Topic, error) {
+	err := s.store.Topics().Update(&models.Topic{
+		ID:          topicID,
+		Name:        req.Name,
+		Description: req.Description,
+		Keywords:    req.Keywords,
+		Platforms:   req.Platforms,
+		WorkspaceID: actor.WorkspaceID,
+		UpdatedAt:   time.Now(),
+	})
 	if err != nil {
-		return nil, err
+		return nil, notFound("topic", err)
 	}
-	
-	return s.GetTopic(topicID)
+
+	return s.GetTopic(actor, topicID)
 }
 
-func (s *TopicService) GetTopic(topicID string) (*models.Topic, error) {
-	var topic models.Topic
-	err := s.db.QueryRow(`
-		SELECT id, name, description, keywords, platforms, user_id, created_at, updated_at
-		FROM topics WHERE id = $1
-	`, topicID).Scan(
-		&topic.ID, &topic.Name, &topic.Description, 
-		pq.Array(&topic.Keywords), pq.Array(&topic.Platforms),
-		&topic.UserID, &topic.CreatedAt, &topic.UpdatedAt,
-	)
-	
+func (s *TopicService) DeleteTopic(actor *models.User, topicID string) error {
+	topic, err := s.GetTopic(actor, topicID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	
-	return &topic, nil
+
+	return notFound("topic", deleteAudited(s.store, actorEvent(actor, AuditTopicDeleted, "topic", topicID), topic,
+		func(tx repository.Store) error { return tx.Topics().Delete(actor.WorkspaceID, topicID) },
+	))
 }
 
-func (s *TopicService) UpdateTopic(topicID string, req *models.CreateTopicRequest) (*models.Topic, error) {
-	now := time.Now()
-	
-	_, err := s.db.Exec(`
-		UPDATE topics 
-		SET name = $2, description = $3, keywords = $4, platforms = $5, updated_at = $6
-		WHERE id = $1
-	`, topicID, req.Name, req.Description, pq.Array(req.Keywords), pq.Array(req.Platforms), now)
-	
+// GetTopicArticles lists a topic's articles, newest first. An empty state
+// returns articles in every state.
+func (s *TopicService) GetTopicArticles(actor *models.User, topicID, state string, page, pageSize int) (*models.PaginatedResponse, error) {
+	if _, err := s.GetTopic(actor, topicID); err != nil {
+		return nil, err
+	}
+
+	articles, total, err := s.store.Articles().List(actor.WorkspaceID,
+		repository.ArticleFilter{TopicID: topicID, State: state}, pageOf(page, pageSize))
 	if err != nil {
 		return nil, err
 	}
-	
-	return s.GetTopic(topicID)
-}
 
-func (s *TopicService) DeleteTopic(topicID string) error {
-	_, err := s.db.Exec("DELETE FROM topics WHERE id = $1", topicID)
-	return err
+	return paginated(articles, total, page, pageSize), nil
 }
 
-func (s *TopicService) GetTopicArticles(topicID string, page, pageSize int) (*models.PaginatedResponse, error) {
-	offset := (page - 1) * pageSize
-	
-	var total int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM articles WHERE topic_id = $1", topicID).Scan(&total)
-	if err != nil {
+// GetTopicStats aggregates a topic's activity over the last `days` days,
+// including today. Top sources are grouped by the host of the article URL.
+func (s *TopicService) GetTopicStats(actor *models.User, topicID string, days, limit int) (*models.TopicStatsResponse, error) {
+	if _, err := s.GetTopic(actor, topicID); err != nil {
 		return nil, err
 	}
-	
-	rows, err := s.db.Query(`
-		SELECT id, title, content, original_url, platform, author_name, author_id, 
-			   published_at, topic_id, user_id, created_at, updated_at
-		FROM articles 
-		WHERE topic_id = $1
-		ORDER BY published_at DESC
-		LIMIT $2 OFFSET $3
-	`, topicID, pageSize, offset)
-	
+
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day()-(days-1), 0, 0, 0, 0, now.Location())
+
+	stats, err := s.store.Topics().Activity(topicID, since, days, limit)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	
-	var articles []models.Article
-	for rows.Next() {
-		var article models.Article
-		err := rows.Scan(
-			&article.ID, &article.Title, &article.Content, &article.OriginalURL,
-			&article.Platform, &article.AuthorName, &article.AuthorID,
-			&article.PublishedAt, &article.TopicID, &article.UserID,
-			&article.CreatedAt, &article.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		articles = append(articles, article)
-	}
-	
-	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
-	
-	return &models.PaginatedResponse{
-		Data:       articles,
-		Page:       page,
-		PageSize:   pageSize,
-		Total:      total,
-		TotalPages: totalPages,
-	}, nil
-}
\ No newline at end of file
+
+	stats.TopicID = topicID
+	stats.Days = days
+	stats.Since = since
+	return stats, nil
+}
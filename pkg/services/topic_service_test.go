@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/repository/memory"
+)
+
+func testActor(id, workspaceID string) *models.User {
+	return &models.User{ID: id, Email: id + "@example.com", WorkspaceID: workspaceID, RequestID: "req-" + id}
+}
+
+func TestTopicServiceScopesTopicsToTheWorkspace(t *testing.T) {
+	store := memory.New()
+	topics := NewTopicService(store)
+	owner := testActor("owner", "workspace")
+	outsider := testActor("outsider", "other")
+
+	topic, err := topics.CreateTopic(owner, &models.CreateTopicRequest{Name: "Go", Keywords: []string{"go"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := topics.GetTopic(outsider, topic.ID); !errors.Is(err, apperr.NotFound("topic")) {
+		t.Fatalf("GetTopic from another workspace: got %v, want topic_not_found", err)
+	}
+	if err := topics.DeleteTopic(outsider, topic.ID); !errors.Is(err, apperr.NotFound("topic")) {
+		t.Fatalf("DeleteTopic from another workspace: got %v, want topic_not_found", err)
+	}
+	if events := store.AuditEvents(); len(events) != 0 {
+		t.Fatalf("failed delete recorded %d audit events", len(events))
+	}
+
+	if err := topics.DeleteTopic(owner, topic.ID); err != nil {
+		t.Fatal(err)
+	}
+	events := store.AuditEvents()
+	if len(events) != 1 {
+		t.Fatalf("got %d audit events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Action != AuditTopicDeleted || event.ResourceID != topic.ID || *event.ActorID != owner.ID ||
+		event.RequestID != owner.RequestID || event.Before["name"] != "Go" {
+		t.Fatalf("unexpected audit event %+v", event)
+	}
+}
+
+func TestTopicServiceStatsCoverTheLastDays(t *testing.T) {
+	store := memory.New()
+	topics := NewTopicService(store)
+	articles := NewArticleService(store, approvalsRequired(false))
+	owner := testActor("owner", "workspace")
+
+	topic, err := topics.CreateTopic(owner, &models.CreateTopicRequest{Name: "Go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, publishedAt := range []time.Time{now, now, now.AddDate(0, 0, -1), now.AddDate(0, 0, -30)} {
+		_, err := articles.CreateArticle(owner, &models.Article{
+			Title: "Post", OriginalURL: "https://blog.example.com/post", PublishedAt: publishedAt, TopicID: topic.ID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := topics.GetTopicStats(owner, topic.ID, 7, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats.ArticlesPerDay) != 7 {
+		t.Fatalf("got %d days, want 7", len(stats.ArticlesPerDay))
+	}
+	today := stats.ArticlesPerDay[6]
+	if today.Date != now.Format("2006-01-02") || today.Count != 2 || stats.ArticlesPerDay[5].Count != 1 {
+		t.Fatalf("unexpected articles per day %v", stats.ArticlesPerDay)
+	}
+	if stats.Since.Format("2006-01-02") != stats.ArticlesPerDay[0].Date || stats.Since.Hour() != 0 {
+		t.Fatalf("since %v does not start the first day %s", stats.Since, stats.ArticlesPerDay[0].Date)
+	}
+	if len(stats.TopSources) != 1 || stats.TopSources[0].Count != 3 {
+		t.Fatalf("unexpected top sources %v", stats.TopSources)
+	}
+
+	if _, err := topics.GetTopicStats(testActor("outsider", "other"), topic.ID, 7, 5); !errors.Is(err, apperr.NotFound("topic")) {
+		t.Fatalf("GetTopicStats from another workspace: got %v, want topic_not_found", err)
+	}
+}
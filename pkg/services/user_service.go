@@ -1,143 +1,102 @@
 package services
 
 import (
-	"database/sql"
-	"fmt"
 	"time"
 
 	"smg/pkg/models"
+	"smg/pkg/rbac"
+	"smg/pkg/repository"
 )
 
 type UserService struct {
-	db *sql.DB
+	store repository.Store
 }
 
-func NewUserService(db *sql.DB) *UserService {
-	return &UserService{db: db}
+func NewUserService(store repository.Store) *UserService {
+	return &UserService{store: store}
 }
 
 func (s *UserService) GetProfile(userID string) (*models.User, error) {
-	var user models.User
-	err := s.db.QueryRow(`
-		SELECT id, name, email, email_verified, image, is_admin, created_at, updated_at
-		FROM users WHERE id = $1
-	`, userID).Scan(
-		&user.ID, &user.Name, &user.Email, &user.EmailVerified, 
-		&user.Image, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt,
-	)
-	
+	user, err := s.store.Users().Get(userID)
 	if err != nil {
-		return nil, err
+		return nil, notFound("user", err)
 	}
-	
-	return &user, nil
+
+	return user, nil
 }
 
 func (s *UserService) UpdateProfile(userID string, req *models.UpdateProfileRequest) (*models.User, error) {
-	now := time.Now()
-	
-	_, err := s.db.Exec(`
-		UPDATE users 
-		SET name = COALESCE($2, name), 
-			image = COALESCE($3, image),
-			updated_at = $4
-		WHERE id = $1
-	`, userID, req.Name, req.Image, now)
-	
-	if err != nil {
-		return nil, err
+	if err := s.store.Users().UpdateProfile(userID, req.Name, req.Image, time.Now()); err != nil {
+		return nil, notFound("user", err)
 	}
-	
+
 	return s.GetProfile(userID)
 }
 
 func (s *UserService) GetUsers(page, pageSize int) (*models.PaginatedResponse, error) {
-	offset := (page - 1) * pageSize
-	
-	var total int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&total)
+	users, total, err := s.store.Users().List(pageOf(page, pageSize))
 	if err != nil {
 		return nil, err
 	}
-	
-	rows, err := s.db.Query(`
-		SELECT id, name, email, email_verified, image, is_admin, created_at, updated_at
-		FROM users 
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-	`, pageSize, offset)
-	
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		err := rows.Scan(
-			&user.ID, &user.Name, &user.Email, &user.EmailVerified, 
-			&user.Image, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, user)
-	}
-	
-	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
-	
-	return &models.PaginatedResponse{
-		Data:       users,
-		Page:       page,
-		PageSize:   pageSize,
-		Total:      total,
-		TotalPages: totalPages,
-	}, nil
+
+	return paginated(users, total, page, pageSize), nil
 }
 
-func (s *UserService) GetUserByID(userID string) (*models.User, error) {
+func (s *UserService) GetUserByID(actor *models.User, userID string) (*models.User, error) {
+	if err := requireSelfOr(actor, userID, rbac.UsersRead); err != nil {
+		return nil, notFound("user", err)
+	}
+
 	return s.GetProfile(userID)
 }
 
-func (s *UserService) UpdateUser(userID string, req *models.UpdateProfileRequest) (*models.User, error) {
+func (s *UserService) UpdateUser(actor *models.User, userID string, req *models.UpdateProfileRequest) (*models.User, error) {
+	if err := requireSelfOr(actor, userID, rbac.UsersWrite); err != nil {
+		return nil, notFound("user", err)
+	}
+
 	return s.UpdateProfile(userID, req)
 }
 
-func (s *UserService) DeleteUser(userID string) error {
-	_, err := s.db.Exec("DELETE FROM users WHERE id = $1", userID)
-	return err
-}
+func (s *UserService) DeleteUser(actor *models.User, userID string) error {
+	if err := requireSelfOr(actor, userID, rbac.UsersWrite); err != nil {
+		return notFound("user", err)
+	}
 
-func (s *UserService) GetUserStats(userID string) (map[string]interface{}, error) {
-	stats := make(map[string]interface{})
-	
-	var topicsCount, mediaAccountsCount, articlesCount, repostsCount int
-	
-	err := s.db.QueryRow("SELECT COUNT(*) FROM topics WHERE user_id = $1", userID).Scan(&topicsCount)
+	user, err := s.GetProfile(userID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	
-	err = s.db.QueryRow("SELECT COUNT(*) FROM media_accounts WHERE user_id = $1", userID).Scan(&mediaAccountsCount)
-	if err != nil {
-		return nil, err
+
+	return notFound("user", deleteAudited(s.store, actorEvent(actor, AuditUserDeleted, "user", userID), user,
+		func(tx repository.Store) error { return tx.Users().Delete(userID) },
+	))
+}
+
+func (s *UserService) GetUserStats(actor *models.User, userID string) (map[string]interface{}, error) {
+	if err := requireSelfOr(actor, userID, rbac.UsersRead); err != nil {
+		return nil, notFound("user", err)
 	}
-	
-	err = s.db.QueryRow("SELECT COUNT(*) FROM articles WHERE user_id = $1", userID).Scan(&articlesCount)
-	if err != nil {
-		return nil, err
+
+	filter := repository.CountFilter{UserID: userID}
+	counts := []struct {
+		key   string
+		count func(repository.CountFilter) (int64, error)
+	}{
+		{"topics_count", s.store.Topics().Count},
+		{"media_accounts_count", s.store.MediaAccounts().Count},
+		{"articles_count", s.store.Articles().Count},
+		{"reposts_count", s.store.Reposts().Count},
 	}
-	
-	err = s.db.QueryRow("SELECT COUNT(*) FROM reposts WHERE user_id = $1", userID).Scan(&repostsCount)
-	if err != nil {
-		return nil, err
+
+	stats := make(map[string]interface{})
+	for _, c := range counts {
+		count, err := c.count(filter)
+		if err != nil {
+			return nil, err
+		}
+		stats[c.key] = count
 	}
-	
-	stats["topics_count"] = topicsCount
-	stats["media_accounts_count"] = mediaAccountsCount
-	stats["articles_count"] = articlesCount
-	stats["reposts_count"] = repostsCount
-	
+
 	return stats, nil
-}
\ No newline at end of file
+}
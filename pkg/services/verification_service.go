@@ -0,0 +1,271 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"smg/pkg/apperr"
+	"smg/pkg/mailer"
+	"smg/pkg/models"
+)
+
+const (
+	verifyEmailTokenTTL   = time.Hour * 24
+	resetPasswordTokenTTL = time.Hour
+)
+
+// Token purposes, used as the prefix of verification_tokens.identifier so
+// they cannot be confused with each other or with tokens the web app
+// stores in the same table.
+const (
+	purposeVerifyEmail   = "verify-email"
+	purposeResetPassword = "reset-password"
+)
+
+// ErrVerificationToken is returned for unknown, expired or already used
+// verification and reset tokens.
+var ErrVerificationToken = apperr.BadRequest("verification_token_invalid", "Invalid or expired token")
+
+// VerificationService runs the email verification and password reset
+// flows. Tokens live in verification_tokens; only their SHA-256 hash is
+// stored, keyed by "<purpose>:<email>".
+type VerificationService struct {
+	db       *sql.DB
+	mailer   *mailer.Mailer
+	sessions *SessionService
+	appURL   string
+}
+
+func NewVerificationService(db *sql.DB, mailer *mailer.Mailer, sessions *SessionService, appURL string) *VerificationService {
+	return &VerificationService{
+		db:       db,
+		mailer:   mailer,
+		sessions: sessions,
+		appURL:   appURL,
+	}
+}
+
+// SendVerification emails the user a link to verify their address.
+func (s *VerificationService) SendVerification(user *models.User, locale string) error {
+	token, err := s.createToken(purposeVerifyEmail, user.Email, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.TemplateVerifyEmail, locale, user.Email, mailData(user, s.link("/verify-email", token), verifyEmailTokenTTL, locale))
+}
+
+// VerifyEmail marks the address the token was issued for as verified.
+func (s *VerificationService) VerifyEmail(token string) error {
+	email, err := s.consumeToken(purposeVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = requireRows(s.db.Exec(
+		"UPDATE users SET email_verified = COALESCE(email_verified, $2), updated_at = $2 WHERE email = $1",
+		email, now,
+	))
+	if err == sql.ErrNoRows {
+		return ErrVerificationToken
+	}
+	return err
+}
+
+// RequestPasswordReset emails a reset link if the address belongs to a user.
+// Unknown addresses are not reported, so the endpoint cannot be used to
+// probe for accounts.
+func (s *VerificationService) RequestPasswordReset(email, locale string) error {
+	user, err := s.userByEmail(email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := s.createToken(purposeResetPassword, user.Email, resetPasswordTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.TemplateResetPassword, locale, user.Email, mailData(user, s.link("/reset-password", token), resetPasswordTokenTTL, locale))
+}
+
+// ResetPassword sets a new password and signs the user out everywhere.
+// Completing a reset also proves ownership of the address, so it is marked
+// as verified.
+func (s *VerificationService) ResetPassword(token, password string) error {
+	email, err := s.consumeToken(purposeResetPassword, token)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var userID string
+	err = s.db.QueryRow(`
+		UPDATE users
+		SET password = $2, email_verified = COALESCE(email_verified, $3), updated_at = $3
+		WHERE email = $1
+		RETURNING id
+	`, email, string(hashedPassword), now).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return ErrVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.revokeAllSessions(userID); err != nil {
+		return err
+	}
+
+	event := models.AuditEvent{
+		Action:       AuditAuthPasswordReset,
+		ActorID:      &userID,
+		ActorEmail:   &email,
+		ResourceType: "user",
+		ResourceID:   userID,
+	}
+	return recordAudit(s.db, event)
+}
+
+// createToken replaces any outstanding token for the same purpose and email
+// with a new one and returns it.
+func (s *VerificationService) createToken(purpose, email string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	identifier := purpose + ":" + email
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM verification_tokens WHERE identifier = $1", identifier); err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO verification_tokens (identifier, token, expires) VALUES ($1, $2, $3)",
+		identifier, hashVerificationToken(token), time.Now().Add(ttl),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// consumeToken deletes a valid token and returns the email it was issued
+// for.
+func (s *VerificationService) consumeToken(purpose, token string) (string, error) {
+	var identifier string
+	var expires time.Time
+	err := s.db.QueryRow(
+		"DELETE FROM verification_tokens WHERE token = $1 RETURNING identifier, expires",
+		hashVerificationToken(token),
+	).Scan(&identifier, &expires)
+	if err == sql.ErrNoRows {
+		return "", ErrVerificationToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	email := strings.TrimPrefix(identifier, purpose+":")
+	if email == identifier || time.Now().After(expires) {
+		return "", ErrVerificationToken
+	}
+
+	return email, nil
+}
+
+func (s *VerificationService) userByEmail(email string) (*models.User, error) {
+	var user models.User
+	err := s.db.QueryRow(
+		"SELECT id, name, email FROM users WHERE lower(email) = lower($1)", email,
+	).Scan(&user.ID, &user.Name, &user.Email)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *VerificationService) revokeAllSessions(userID string) error {
+	rows, err := s.db.Query("SELECT session_token FROM sessions WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	var families []string
+	for rows.Next() {
+		var familyID string
+		if err := rows.Scan(&familyID); err != nil {
+			rows.Close()
+			return err
+		}
+		families = append(families, familyID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, familyID := range families {
+		if err := s.sessions.RevokeFamily(familyID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *VerificationService) link(path, token string) string {
+	return s.appURL + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+func mailData(user *models.User, link string, ttl time.Duration, locale string) map[string]string {
+	name := user.Email
+	if user.Name != nil && *user.Name != "" {
+		name = *user.Name
+	}
+
+	return map[string]string{
+		"Name":      name,
+		"Email":     user.Email,
+		"Link":      link,
+		"ExpiresIn": formatTTL(ttl, locale),
+	}
+}
+
+func formatTTL(ttl time.Duration, locale string) string {
+	hours := int(ttl.Hours())
+	if mailer.Locale(locale) == mailer.LocaleChinese {
+		return strconv.Itoa(hours) + " 小時"
+	}
+	if hours == 1 {
+		return "1 hour"
+	}
+	return strconv.Itoa(hours) + " hours"
+}
+
+func hashVerificationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
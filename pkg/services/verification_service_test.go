@@ -0,0 +1,194 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"smg/pkg/dbtest"
+	"smg/pkg/mailer"
+	"smg/pkg/mailer/smtptest"
+	"smg/pkg/models"
+	"smg/pkg/redistest"
+)
+
+// TestMain runs the tests that call dbtest.DB against a database of their
+// own; the rest of the package runs without one.
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
+
+// verificationFixture returns a verification service on the seeded test
+// database, mailing to the returned sink.
+func verificationFixture(t *testing.T) (*VerificationService, *SessionService, *smtptest.Sink, *sql.DB) {
+	t.Helper()
+	db := dbtest.DB(t)
+	dbtest.Seed(t, db)
+
+	sink, err := smtptest.NewSink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sink.Close() })
+
+	sessions := NewSessionService(db, redistest.New(t))
+	m := mailer.New(mailer.Config{Host: sink.Host, Port: sink.Port, From: "noreply@example.com"})
+	return NewVerificationService(db, m, sessions, "https://app.example.com"), sessions, sink, db
+}
+
+var mailedToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// tokenFrom returns the token in the link of the next email the sink
+// received.
+func tokenFrom(t *testing.T, sink *smtptest.Sink) string {
+	t.Helper()
+	msg, err := sink.WaitForMessage(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := mailedToken.FindStringSubmatch(msg.Body)
+	if match == nil {
+		t.Fatalf("no token link in email:\n%s", msg.Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func seededUser(t *testing.T, db *sql.DB, id string) *models.User {
+	t.Helper()
+	var user models.User
+	err := db.QueryRow("SELECT id, name, email, email_verified FROM users WHERE id = $1", id).
+		Scan(&user.ID, &user.Name, &user.Email, &user.EmailVerified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+func TestVerifyEmail(t *testing.T) {
+	verification, _, sink, db := verificationFixture(t)
+	editor := seededUser(t, db, "user_editor")
+
+	if err := verification.SendVerification(editor, "en"); err != nil {
+		t.Fatal(err)
+	}
+	token := tokenFrom(t, sink)
+
+	if err := verification.VerifyEmail(token); err != nil {
+		t.Fatal(err)
+	}
+	if seededUser(t, db, editor.ID).EmailVerified == nil {
+		t.Fatal("email_verified not set")
+	}
+	if err := verification.VerifyEmail(token); !errors.Is(err, ErrVerificationToken) {
+		t.Fatalf("reused token: got %v, want %v", err, ErrVerificationToken)
+	}
+
+	if err := verification.SendVerification(editor, "en"); err != nil {
+		t.Fatal(err)
+	}
+	expired := tokenFrom(t, sink)
+	if _, err := db.Exec("UPDATE verification_tokens SET expires = $1", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := verification.VerifyEmail(expired); !errors.Is(err, ErrVerificationToken) {
+		t.Fatalf("expired token: got %v, want %v", err, ErrVerificationToken)
+	}
+
+	// A reset token does not verify the address.
+	if err := verification.RequestPasswordReset(editor.Email, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := verification.VerifyEmail(tokenFrom(t, sink)); !errors.Is(err, ErrVerificationToken) {
+		t.Fatalf("reset token: got %v, want %v", err, ErrVerificationToken)
+	}
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	verification, _, sink, _ := verificationFixture(t)
+
+	if err := verification.RequestPasswordReset("nobody@example.com", "en"); err != nil {
+		t.Fatalf("unknown address: %v", err)
+	}
+	if messages := sink.Messages(); len(messages) != 0 {
+		t.Fatalf("unknown address was sent %d email(s)", len(messages))
+	}
+
+	// Addresses match case-insensitively; the email goes to the stored one.
+	if err := verification.RequestPasswordReset("Editor@Example.com", "zh-TW"); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := sink.WaitForMessage(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.To) != 1 || msg.To[0] != "editor@example.com" {
+		t.Fatalf("recipients: got %v", msg.To)
+	}
+	if msg.Subject != "重設您的密碼" {
+		t.Fatalf("subject: got %q", msg.Subject)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	verification, sessions, sink, db := verificationFixture(t)
+	for _, session := range []struct{ userID, familyID string }{
+		{"user_editor", "family-laptop"},
+		{"user_editor", "family-phone"},
+		{"user_admin", "family-admin"},
+	} {
+		if err := sessions.CreateSession(session.userID, session.familyID, LoginMethodPassword, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := verification.RequestPasswordReset("editor@example.com", "en"); err != nil {
+		t.Fatal(err)
+	}
+	token := tokenFrom(t, sink)
+	if err := verification.ResetPassword(token, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	var hashed string
+	var verified *time.Time
+	if err := db.QueryRow("SELECT password, email_verified FROM users WHERE id = 'user_editor'").Scan(&hashed, &verified); err != nil {
+		t.Fatal(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hashed), []byte("correct horse")) != nil {
+		t.Fatal("password not changed")
+	}
+	if verified == nil {
+		t.Fatal("completing a reset did not verify the address")
+	}
+
+	for familyID, want := range map[string]bool{"family-laptop": true, "family-phone": true, "family-admin": false} {
+		revoked, err := sessions.IsRevoked(familyID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked != want {
+			t.Errorf("family %s: revoked %v, want %v", familyID, revoked, want)
+		}
+	}
+	for userID, want := range map[string]int{"user_editor": 0, "user_admin": 1} {
+		var count int
+		if err := db.QueryRow("SELECT count(*) FROM sessions WHERE user_id = $1", userID).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Errorf("sessions of %s: got %d, want %d", userID, count, want)
+		}
+	}
+
+	if err := verification.ResetPassword(token, "another password"); !errors.Is(err, ErrVerificationToken) {
+		t.Fatalf("reused token: got %v, want %v", err, ErrVerificationToken)
+	}
+}
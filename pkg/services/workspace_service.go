@@ -0,0 +1,504 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"smg/pkg/apperr"
+	"smg/pkg/models"
+	"smg/pkg/rbac"
+)
+
+var (
+	// ErrWorkspaceManager is returned when a member without the owner or
+	// admin workspace role tries to manage the workspace.
+	ErrWorkspaceManager = apperr.Forbidden("workspace_manager", "Workspace owner or admin role required")
+	// ErrPersonalWorkspace is returned when deleting a personal workspace or
+	// removing its owner.
+	ErrPersonalWorkspace = apperr.Conflict("personal_workspace", "Personal workspaces cannot be deleted or lose their owner")
+	// ErrLastOwner is returned when a change would leave a workspace without
+	// an owner.
+	ErrLastOwner = apperr.Conflict("last_owner", "A workspace must keep at least one owner")
+	// ErrInvitationInvalid is returned for unknown, expired or already
+	// accepted invitation tokens.
+	ErrInvitationInvalid = apperr.BadRequest("invitation_invalid", "Invalid or expired invitation")
+	// ErrInvitationEmail is returned when an invitation is accepted by a user
+	// whose email differs from the invited address.
+	ErrInvitationEmail = apperr.Forbidden("invitation_email", "Invitation was sent to a different email address")
+)
+
+const invitationTTL = time.Hour * 24 * 7
+
+type WorkspaceService struct {
+	db *sql.DB
+}
+
+func NewWorkspaceService(db *sql.DB) *WorkspaceService {
+	return &WorkspaceService{db: db}
+}
+
+// PersonalWorkspaceID returns the ID of the user's personal workspace. It is
+// derived from the user ID so existing rows could be migrated into it.
+func PersonalWorkspaceID(userID string) string {
+	return "ws_" + userID
+}
+
+// EnsurePersonalWorkspace creates the user's personal workspace and owner
+// membership if they do not exist yet.
+func (s *WorkspaceService) EnsurePersonalWorkspace(user *models.User) error {
+	workspaceID := PersonalWorkspaceID(user.ID)
+	name := user.Email
+	if user.Name != nil && *user.Name != "" {
+		name = *user.Name
+	}
+	now := time.Now()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO workspaces (id, name, personal, owner_id, created_at, updated_at)
+		VALUES ($1, $2, true, $3, $4, $5)
+		ON CONFLICT (id) DO NOTHING
+	`, workspaceID, name, user.ID, now, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO workspace_members (id, workspace_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (workspace_id, user_id) DO NOTHING
+	`, uuid.New().String(), workspaceID, user.ID, rbac.RoleOwner, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MemberRole returns the user's role in the workspace, or sql.ErrNoRows if
+// they are not a member.
+func (s *WorkspaceService) MemberRole(workspaceID, userID string) (string, error) {
+	var role string
+	err := s.db.QueryRow(
+		"SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2", workspaceID, userID,
+	).Scan(&role)
+	return role, err
+}
+
+func (s *WorkspaceService) GetWorkspaces(userID string) ([]models.Workspace, error) {
+	rows, err := s.db.Query(`
+		SELECT w.id, w.name, w.personal, w.owner_id, m.role, w.created_at, w.updated_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY w.personal DESC, w.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []models.Workspace{}
+	for rows.Next() {
+		var workspace models.Workspace
+		err := rows.Scan(
+			&workspace.ID, &workspace.Name, &workspace.Personal, &workspace.OwnerID,
+			&workspace.Role, &workspace.CreatedAt, &workspace.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+
+	return workspaces, rows.Err()
+}
+
+func (s *WorkspaceService) CreateWorkspace(actor *models.User, req *models.WorkspaceRequest) (*models.Workspace, error) {
+	workspaceID := uuid.New().String()
+	now := time.Now()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO workspaces (id, name, personal, owner_id, created_at, updated_at)
+		VALUES ($1, $2, false, $3, $4, $5)
+	`, workspaceID, req.Name, actor.ID, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO workspace_members (id, workspace_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, uuid.New().String(), workspaceID, actor.ID, rbac.RoleOwner, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetWorkspace(actor, workspaceID)
+}
+
+// GetWorkspace returns a workspace the actor belongs to. Global admins can
+// read any workspace.
+func (s *WorkspaceService) GetWorkspace(actor *models.User, workspaceID string) (*models.Workspace, error) {
+	var workspace models.Workspace
+	var role sql.NullString
+	err := s.db.QueryRow(`
+		SELECT w.id, w.name, w.personal, w.owner_id, m.role, w.created_at, w.updated_at
+		FROM workspaces w
+		LEFT JOIN workspace_members m ON m.workspace_id = w.id AND m.user_id = $2
+		WHERE w.id = $1 AND ($3 OR m.id IS NOT NULL)
+	`, workspaceID, actor.ID, actor.IsAdmin).Scan(
+		&workspace.ID, &workspace.Name, &workspace.Personal, &workspace.OwnerID,
+		&role, &workspace.CreatedAt, &workspace.UpdatedAt,
+	)
+	if err != nil {
+		return nil, notFound("workspace", err)
+	}
+	workspace.Role = role.String
+
+	return &workspace, nil
+}
+
+func (s *WorkspaceService) UpdateWorkspace(actor *models.User, workspaceID string, req *models.WorkspaceRequest) (*models.Workspace, error) {
+	if err := s.requireManager(actor, workspaceID); err != nil {
+		return nil, err
+	}
+
+	err := requireRows(s.db.Exec(
+		"UPDATE workspaces SET name = $2, updated_at = $3 WHERE id = $1", workspaceID, req.Name, time.Now(),
+	))
+	if err != nil {
+		return nil, notFound("workspace", err)
+	}
+
+	return s.GetWorkspace(actor, workspaceID)
+}
+
+// DeleteWorkspace deletes a shared workspace together with its content. Only
+// workspace owners and global admins may delete it.
+func (s *WorkspaceService) DeleteWorkspace(actor *models.User, workspaceID string) error {
+	workspace, err := s.GetWorkspace(actor, workspaceID)
+	if err != nil {
+		return err
+	}
+	if workspace.Role != rbac.RoleOwner && !actor.IsAdmin {
+		return ErrWorkspaceManager
+	}
+	if workspace.Personal {
+		return ErrPersonalWorkspace
+	}
+
+	return notFound("workspace", requireRows(s.db.Exec("DELETE FROM workspaces WHERE id = $1", workspaceID)))
+}
+
+func (s *WorkspaceService) GetMembers(actor *models.User, workspaceID string) ([]models.WorkspaceMember, error) {
+	if _, err := s.GetWorkspace(actor, workspaceID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT m.id, m.workspace_id, m.user_id, u.name, u.email, m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		ORDER BY m.created_at
+	`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.WorkspaceMember{}
+	for rows.Next() {
+		var member models.WorkspaceMember
+		err := rows.Scan(
+			&member.ID, &member.WorkspaceID, &member.UserID, &member.Name,
+			&member.Email, &member.Role, &member.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// SetMemberRole changes a member's workspace role. Only owners may grant or
+// revoke the owner role, and the last owner cannot be demoted.
+func (s *WorkspaceService) SetMemberRole(actor *models.User, workspaceID, userID, role string) error {
+	if err := s.requireManager(actor, workspaceID); err != nil {
+		return err
+	}
+
+	current, err := s.MemberRole(workspaceID, userID)
+	if err != nil {
+		return notFound("member", err)
+	}
+
+	if role == current {
+		return nil
+	}
+	if role == rbac.RoleOwner || current == rbac.RoleOwner {
+		if err := s.requireOwner(actor, workspaceID); err != nil {
+			return err
+		}
+	}
+	if current == rbac.RoleOwner {
+		if err := s.requireOtherOwner(workspaceID, userID); err != nil {
+			return err
+		}
+	}
+
+	return notFound("member", requireRows(s.db.Exec(
+		"UPDATE workspace_members SET role = $3 WHERE workspace_id = $1 AND user_id = $2", workspaceID, userID, role,
+	)))
+}
+
+// RemoveMember removes a member from the workspace. Members may always
+// remove themselves; removing anyone else requires the manager role.
+func (s *WorkspaceService) RemoveMember(actor *models.User, workspaceID, userID string) error {
+	workspace, err := s.GetWorkspace(actor, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	if userID != actor.ID {
+		if err := s.requireManager(actor, workspaceID); err != nil {
+			return err
+		}
+	}
+
+	current, err := s.MemberRole(workspaceID, userID)
+	if err != nil {
+		return notFound("member", err)
+	}
+
+	if current == rbac.RoleOwner {
+		if workspace.Personal && userID == workspace.OwnerID {
+			return ErrPersonalWorkspace
+		}
+		if userID != actor.ID {
+			if err := s.requireOwner(actor, workspaceID); err != nil {
+				return err
+			}
+		}
+		if err := s.requireOtherOwner(workspaceID, userID); err != nil {
+			return err
+		}
+	}
+
+	return notFound("member", requireRows(s.db.Exec(
+		"DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2", workspaceID, userID,
+	)))
+}
+
+// CreateInvitation invites an email address to the workspace. Only a hash of
+// the token is stored, so the returned invitation is the only place the
+// token can be read from.
+func (s *WorkspaceService) CreateInvitation(actor *models.User, workspaceID string, req *models.InvitationRequest) (*models.WorkspaceInvitation, error) {
+	if err := s.requireManager(actor, workspaceID); err != nil {
+		return nil, err
+	}
+
+	token, err := newInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	invitation := models.WorkspaceInvitation{
+		ID:          uuid.New().String(),
+		WorkspaceID: workspaceID,
+		Email:       strings.ToLower(req.Email),
+		Role:        req.Role,
+		Token:       token,
+		InvitedBy:   actor.ID,
+		ExpiresAt:   now.Add(invitationTTL),
+		CreatedAt:   now,
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO workspace_invitations (id, workspace_id, email, role, token_hash, invited_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, invitation.ID, workspaceID, invitation.Email, invitation.Role, hashInvitationToken(token),
+		actor.ID, invitation.ExpiresAt, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// GetInvitations lists the workspace's invitations that have not been
+// accepted yet.
+func (s *WorkspaceService) GetInvitations(actor *models.User, workspaceID string) ([]models.WorkspaceInvitation, error) {
+	if err := s.requireManager(actor, workspaceID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, workspace_id, email, role, invited_by, expires_at, accepted_at, created_at
+		FROM workspace_invitations
+		WHERE workspace_id = $1 AND accepted_at IS NULL
+		ORDER BY created_at DESC
+	`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []models.WorkspaceInvitation{}
+	for rows.Next() {
+		var invitation models.WorkspaceInvitation
+		err := rows.Scan(
+			&invitation.ID, &invitation.WorkspaceID, &invitation.Email, &invitation.Role,
+			&invitation.InvitedBy, &invitation.ExpiresAt, &invitation.AcceptedAt, &invitation.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+func (s *WorkspaceService) RevokeInvitation(actor *models.User, workspaceID, invitationID string) error {
+	if err := s.requireManager(actor, workspaceID); err != nil {
+		return err
+	}
+
+	return notFound("invitation", requireRows(s.db.Exec(
+		"DELETE FROM workspace_invitations WHERE id = $1 AND workspace_id = $2 AND accepted_at IS NULL",
+		invitationID, workspaceID,
+	)))
+}
+
+// AcceptInvitation adds the actor to the workspace the token was issued for.
+// The actor's email must match the invited address.
+func (s *WorkspaceService) AcceptInvitation(actor *models.User, token string) (*models.Workspace, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var invitationID, workspaceID, email, role string
+	err = tx.QueryRow(`
+		SELECT id, workspace_id, email, role
+		FROM workspace_invitations
+		WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > $2
+		FOR UPDATE
+	`, hashInvitationToken(token), time.Now()).Scan(&invitationID, &workspaceID, &email, &role)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(email, actor.Email) {
+		return nil, ErrInvitationEmail
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO workspace_members (id, workspace_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (workspace_id, user_id) DO NOTHING
+	`, uuid.New().String(), workspaceID, actor.ID, role, now)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE workspace_invitations SET accepted_at = $2 WHERE id = $1", invitationID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetWorkspace(actor, workspaceID)
+}
+
+// requireManager returns a workspace not found error if the workspace is
+// not visible to the actor and ErrWorkspaceManager if they may not manage it.
+func (s *WorkspaceService) requireManager(actor *models.User, workspaceID string) error {
+	workspace, err := s.GetWorkspace(actor, workspaceID)
+	if err != nil {
+		return err
+	}
+	if !actor.IsAdmin && !rbac.CanManageWorkspace(workspace.Role) {
+		return ErrWorkspaceManager
+	}
+
+	return nil
+}
+
+func (s *WorkspaceService) requireOwner(actor *models.User, workspaceID string) error {
+	if actor.IsAdmin {
+		return nil
+	}
+
+	role, err := s.MemberRole(workspaceID, actor.ID)
+	if err != nil {
+		return err
+	}
+	if role != rbac.RoleOwner {
+		return ErrOwnerRequired
+	}
+
+	return nil
+}
+
+func (s *WorkspaceService) requireOtherOwner(workspaceID, userID string) error {
+	var owners int
+	err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM workspace_members WHERE workspace_id = $1 AND role = $2 AND user_id <> $3",
+		workspaceID, rbac.RoleOwner, userID,
+	).Scan(&owners)
+	if err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
+
+func newInvitationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}